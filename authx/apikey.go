@@ -0,0 +1,237 @@
+package authx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiKeyHashPrefix marks SHA-256 key hashes in configuration
+const apiKeyHashPrefix = "sha256:"
+
+// ErrAPIKeyNotFound is returned by stores when no key matches
+var ErrAPIKeyNotFound = errors.New("authx: api key not found")
+
+// ErrAuthUnavailable wraps credential store failures, which are answered with 503
+var ErrAuthUnavailable = errors.New("authx: credential store unavailable")
+
+// ErrRateLimited is returned when a rate limit hook rejects a credential
+var ErrRateLimited = errors.New("authx: rate limit exceeded")
+
+// APIKeyConfig contains API key authentication configuration
+type APIKeyConfig struct {
+	// Header is the request header carrying the key
+	Header string `mapstructure:"header" default:"X-API-Key"`
+
+	// QueryParam is a query parameter carrying the key; empty disables it.
+	// Query strings end up in access logs and proxies, so prefer the header.
+	QueryParam string `mapstructure:"query_param"`
+
+	// Keys are the hashed keys of the built-in config store
+	Keys []APIKeyEntry `mapstructure:"keys"`
+}
+
+// APIKeyEntry is a hashed API key in configuration
+type APIKeyEntry struct {
+	// ID names the key in logs, rate limiting and last-used tracking
+	ID string `mapstructure:"id"`
+
+	// Hash is the key hash as produced by HashAPIKey ("sha256:<hex>")
+	Hash string `mapstructure:"hash"`
+
+	// Subject is the principal subject; defaults to ID
+	Subject string `mapstructure:"subject"`
+
+	// Scopes are granted to requests using the key
+	Scopes []string `mapstructure:"scopes"`
+
+	// Roles are granted to requests using the key
+	Roles []string `mapstructure:"roles"`
+}
+
+// APIKey is a resolved API key
+type APIKey struct {
+	ID      string
+	Subject string
+	Scopes  []string
+	Roles   []string
+}
+
+// principal builds the request principal for the key
+func (k *APIKey) principal() *Principal {
+	sub := k.Subject
+	if sub == "" {
+		sub = k.ID
+	}
+	return &Principal{
+		Subject: sub,
+		Scopes:  k.Scopes,
+		Roles:   k.Roles,
+		Method:  "api_key",
+		Claims:  map[string]any{"key_id": k.ID},
+	}
+}
+
+// APIKeyStore resolves presented API keys. Lookup returns ErrAPIKeyNotFound
+// when the key is unknown; other errors are treated as store failures.
+type APIKeyStore interface {
+	Lookup(ctx context.Context, key string) (*APIKey, error)
+}
+
+// HashAPIKey returns the configuration hash for a raw API key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return apiKeyHashPrefix + hex.EncodeToString(sum[:])
+}
+
+// StaticAPIKeyStore holds hashed keys from configuration
+type StaticAPIKeyStore struct {
+	keys map[string]*APIKey
+}
+
+// NewStaticAPIKeyStore creates a store from hashed key entries
+func NewStaticAPIKeyStore(entries []APIKeyEntry) (*StaticAPIKeyStore, error) {
+	s := &StaticAPIKeyStore{keys: make(map[string]*APIKey, len(entries))}
+	for _, e := range entries {
+		if e.ID == "" {
+			return nil, errors.New("authx: api key id is required")
+		}
+		hash := strings.ToLower(strings.TrimSpace(e.Hash))
+		digest, ok := strings.CutPrefix(hash, apiKeyHashPrefix)
+		if b, err := hex.DecodeString(digest); !ok || err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("authx: api key %q: hash must be %s<64 hex chars>", e.ID, apiKeyHashPrefix)
+		}
+		if _, dup := s.keys[hash]; dup {
+			return nil, fmt.Errorf("authx: api key %q: duplicate hash", e.ID)
+		}
+		s.keys[hash] = &APIKey{ID: e.ID, Subject: e.Subject, Scopes: e.Scopes, Roles: e.Roles}
+	}
+	return s, nil
+}
+
+// Lookup hashes the presented key and returns the matching entry
+func (s *StaticAPIKeyStore) Lookup(_ context.Context, key string) (*APIKey, error) {
+	k, ok := s.keys[HashAPIKey(key)]
+	if !ok {
+		return nil, ErrAPIKeyNotFound
+	}
+	return k, nil
+}
+
+// APIKeyOption configures APIKeyMiddleware and APIKeyAuthenticator
+type APIKeyOption func(*apiKeyOptions)
+
+type apiKeyOptions struct {
+	allow    func(ctx context.Context, key *APIKey) bool
+	lastUsed func(ctx context.Context, key *APIKey, at time.Time)
+}
+
+// WithAPIKeyRateLimit sets a per-key rate limit hook. Requests for which allow
+// returns false are rejected with 429.
+func WithAPIKeyRateLimit(allow func(ctx context.Context, key *APIKey) bool) APIKeyOption {
+	return func(o *apiKeyOptions) {
+		o.allow = allow
+	}
+}
+
+// WithAPIKeyLastUsed sets a callback invoked after each successful authentication.
+// It runs on the request path, so slow persistence should be done asynchronously.
+func WithAPIKeyLastUsed(fn func(ctx context.Context, key *APIKey, at time.Time)) APIKeyOption {
+	return func(o *apiKeyOptions) {
+		o.lastUsed = fn
+	}
+}
+
+// resolve looks up the presented key and applies the hooks. It returns
+// ErrAPIKeyNotFound for unknown keys, ErrRateLimited when the rate limit hook
+// rejects the key and an error wrapping ErrAuthUnavailable when the store fails.
+func (o *apiKeyOptions) resolve(ctx context.Context, store APIKeyStore, raw string) (*APIKey, error) {
+	key, err := store.Lookup(ctx, raw)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrAuthUnavailable, err)
+	}
+	if o.allow != nil && !o.allow(ctx, key) {
+		return nil, fmt.Errorf("%w for api key %q", ErrRateLimited, key.ID)
+	}
+	if o.lastUsed != nil {
+		o.lastUsed(ctx, key, time.Now())
+	}
+	return key, nil
+}
+
+// presentedAPIKey returns the key from the header or, if configured, the query parameter
+func presentedAPIKey(c *gin.Context, header string, cfg APIKeyConfig) string {
+	raw := c.GetHeader(header)
+	if raw == "" && cfg.QueryParam != "" {
+		raw = c.Query(cfg.QueryParam)
+	}
+	return raw
+}
+
+// APIKeyMiddleware authenticates requests with an API key from the configured
+// header or query parameter. The key's principal is stored on the request context;
+// missing or unknown keys get a 401 error envelope.
+func APIKeyMiddleware(store APIKeyStore, cfg APIKeyConfig, opts ...APIKeyOption) gin.HandlerFunc {
+	var o apiKeyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	header := cfg.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+
+	return func(c *gin.Context) {
+		raw := presentedAPIKey(c, header, cfg)
+		if raw == "" {
+			Unauthorized(c, "missing api key")
+			return
+		}
+
+		key, err := o.resolve(c.Request.Context(), store, raw)
+		switch {
+		case errors.Is(err, ErrAPIKeyNotFound):
+			Unauthorized(c, "invalid api key")
+			return
+		case err != nil:
+			AuthenticationFailed(c, err)
+			return
+		}
+
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), key.principal()))
+		c.Next()
+	}
+}
+
+// APIKeyAuthenticator authenticates API keys from the configured header or query
+// parameter, applying the same rate limit and last-used hooks as APIKeyMiddleware
+func APIKeyAuthenticator(store APIKeyStore, cfg APIKeyConfig, opts ...APIKeyOption) Authenticator {
+	var o apiKeyOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	header := cfg.Header
+	if header == "" {
+		header = "X-API-Key"
+	}
+	return func(c *gin.Context) (*Principal, error) {
+		raw := presentedAPIKey(c, header, cfg)
+		if raw == "" {
+			return nil, nil
+		}
+		key, err := o.resolve(c.Request.Context(), store, raw)
+		if err != nil {
+			return nil, err
+		}
+		return key.principal(), nil
+	}
+}
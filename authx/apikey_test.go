@@ -0,0 +1,142 @@
+package authx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewStaticAPIKeyStore(t *testing.T) {
+	t.Run("resolves keys by hash", func(t *testing.T) {
+		store, err := NewStaticAPIKeyStore([]APIKeyEntry{
+			{ID: "billing", Hash: HashAPIKey("secret-1"), Scopes: []string{"invoices:read"}},
+		})
+		require.NoError(t, err)
+
+		key, err := store.Lookup(context.Background(), "secret-1")
+		require.NoError(t, err)
+		assert.Equal(t, "billing", key.ID)
+
+		_, err = store.Lookup(context.Background(), "secret-2")
+		assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	})
+
+	t.Run("rejects invalid entries", func(t *testing.T) {
+		for name, entry := range map[string]APIKeyEntry{
+			"missing id":     {Hash: HashAPIKey("k")},
+			"missing prefix": {ID: "a", Hash: HashAPIKey("k")[len(apiKeyHashPrefix):]},
+			"short digest":   {ID: "a", Hash: "sha256:abcd"},
+			"raw key":        {ID: "a", Hash: "k"},
+		} {
+			_, err := NewStaticAPIKeyStore([]APIKeyEntry{entry})
+			assert.Error(t, err, name)
+		}
+
+		_, err := NewStaticAPIKeyStore([]APIKeyEntry{
+			{ID: "a", Hash: HashAPIKey("k")},
+			{ID: "b", Hash: HashAPIKey("k")},
+		})
+		assert.Error(t, err, "duplicate hash")
+	})
+}
+
+type failingStore struct{}
+
+func (failingStore) Lookup(context.Context, string) (*APIKey, error) {
+	return nil, errors.New("database down")
+}
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	store, err := NewStaticAPIKeyStore([]APIKeyEntry{
+		{ID: "billing", Hash: HashAPIKey("secret-1"), Subject: "billing-service", Scopes: []string{"invoices:read"}},
+		{ID: "reports", Hash: HashAPIKey("secret-2"), Scopes: []string{"reports:read"}},
+	})
+	require.NoError(t, err)
+
+	newEngine := func(store APIKeyStore, cfg APIKeyConfig, opts ...APIKeyOption) *gin.Engine {
+		engine := gin.New()
+		engine.GET("/invoices", APIKeyMiddleware(store, cfg, opts...), RequireScopes("invoices:read"), func(c *gin.Context) {
+			p, _ := PrincipalFromContext(c.Request.Context())
+			c.String(http.StatusOK, p.Subject)
+		})
+		return engine
+	}
+
+	do := func(engine *gin.Engine, target, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("authenticates keys from the header", func(t *testing.T) {
+		w := do(newEngine(store, APIKeyConfig{}), "/invoices", "secret-1")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "billing-service", w.Body.String())
+	})
+
+	t.Run("authenticates keys from the query parameter", func(t *testing.T) {
+		engine := newEngine(store, APIKeyConfig{QueryParam: "api_key"})
+		assert.Equal(t, http.StatusOK, do(engine, "/invoices?api_key=secret-1", "").Code)
+
+		engine = newEngine(store, APIKeyConfig{})
+		assert.Equal(t, http.StatusUnauthorized, do(engine, "/invoices?api_key=secret-1", "").Code)
+	})
+
+	t.Run("rejects missing and unknown keys with 401", func(t *testing.T) {
+		engine := newEngine(store, APIKeyConfig{})
+		for _, key := range []string{"", "wrong"} {
+			w := do(engine, "/invoices", key)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"unauthorized"`)
+		}
+	})
+
+	t.Run("rejects keys without the required scope with 403", func(t *testing.T) {
+		w := do(newEngine(store, APIKeyConfig{}), "/invoices", "secret-2")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"forbidden"`)
+	})
+
+	t.Run("reports store failures with 503", func(t *testing.T) {
+		w := do(newEngine(failingStore{}, APIKeyConfig{}), "/invoices", "secret-1")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("applies the rate limit hook per key", func(t *testing.T) {
+		calls := map[string]int{}
+		engine := newEngine(store, APIKeyConfig{}, WithAPIKeyRateLimit(func(_ context.Context, key *APIKey) bool {
+			calls[key.ID]++
+			return calls[key.ID] <= 1
+		}))
+
+		assert.Equal(t, http.StatusOK, do(engine, "/invoices", "secret-1").Code)
+		w := do(engine, "/invoices", "secret-1")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"rate_limited"`)
+	})
+
+	t.Run("reports last use of successful keys", func(t *testing.T) {
+		used := map[string]time.Time{}
+		engine := newEngine(store, APIKeyConfig{}, WithAPIKeyLastUsed(func(_ context.Context, key *APIKey, at time.Time) {
+			used[key.ID] = at
+		}))
+
+		do(engine, "/invoices", "secret-1")
+		do(engine, "/invoices", "wrong")
+		assert.Len(t, used, 1)
+		assert.False(t, used["billing"].IsZero())
+	})
+}
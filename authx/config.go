@@ -0,0 +1,13 @@
+package authx
+
+// Config contains authentication configuration, bound under http.auth
+type Config struct {
+	// JWT contains bearer token validation configuration
+	JWT JWTConfig `mapstructure:"jwt"`
+
+	// APIKey contains API key authentication configuration
+	APIKey APIKeyConfig `mapstructure:"api_key"`
+
+	// Webhooks maps receiver names to HMAC webhook verification settings
+	Webhooks map[string]WebhookConfig `mapstructure:"webhooks"`
+}
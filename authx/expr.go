@@ -0,0 +1,321 @@
+package authx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy expressions are a small boolean language:
+//
+//	expr    := or
+//	or      := and ("||" and)*
+//	and     := unary ("&&" unary)*
+//	unary   := "!" unary | compare
+//	compare := operand (("==" | "!=") operand)?
+//	operand := "(" expr ")" | string | "true" | "false" | call | path
+//	call    := ("has_scope" | "has_role") "(" string ")"
+//	path    := "subject" | "claims" ("." name)+ | "params." name
+//
+// Comparing against a list claim tests membership. Scalars compare by their
+// string form, so a numeric claim matches the equivalent path parameter.
+
+// exprEnv is the evaluation environment of a policy expression
+type exprEnv struct {
+	principal *Principal
+	params    gin.Params
+}
+
+type exprNode func(env exprEnv) any
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+// tokenize splits an expression into identifiers, quoted strings and operators
+func tokenize(src string) ([]token, error) {
+	var toks []token
+	for i := 0; i < len(src); {
+		ch := rune(src[i])
+		switch {
+		case unicode.IsSpace(ch):
+			i++
+		case ch == '\'' || ch == '"':
+			end := strings.IndexByte(src[i+1:], src[i])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated string at %d", i)
+			}
+			toks = append(toks, token{tokString, src[i+1 : i+1+end], i})
+			i += end + 2
+		case ch == '_' || unicode.IsLetter(ch):
+			start := i
+			for i < len(src) && (src[i] == '_' || src[i] == '.' || src[i] == '-' ||
+				unicode.IsLetter(rune(src[i])) || unicode.IsDigit(rune(src[i]))) {
+				i++
+			}
+			toks = append(toks, token{tokIdent, src[start:i], start})
+		default:
+			op := ""
+			for _, candidate := range []string{"==", "!=", "&&", "||", "!", "(", ")"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected %q at %d", ch, i)
+			}
+			toks = append(toks, token{tokOp, op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, token{kind: tokEOF, pos: len(src)}), nil
+}
+
+type exprParser struct {
+	toks []token
+	pos  int
+}
+
+// parseExpr compiles a policy expression
+func parseExpr(src string) (exprNode, error) {
+	toks, err := tokenize(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{toks: toks}
+	node, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+	}
+	return node, nil
+}
+
+func (p *exprParser) peek() token { return p.toks[p.pos] }
+
+func (p *exprParser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) accept(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.accept(op) {
+		t := p.peek()
+		return fmt.Errorf("expected %q at %d", op, t.pos)
+	}
+	return nil
+}
+
+func (p *exprParser) or() (exprNode, error) {
+	left, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("||") {
+		right, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env exprEnv) any { return truthy(l(env)) || truthy(right(env)) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) and() (exprNode, error) {
+	left, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.accept("&&") {
+		right, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l := left
+		left = func(env exprEnv) any { return truthy(l(env)) && truthy(right(env)) }
+	}
+	return left, nil
+}
+
+func (p *exprParser) unary() (exprNode, error) {
+	if p.accept("!") {
+		inner, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env exprEnv) any { return !truthy(inner(env)) }, nil
+	}
+	return p.compare()
+}
+
+func (p *exprParser) compare() (exprNode, error) {
+	left, err := p.operand()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.accept("=="):
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(env exprEnv) any { return equal(left(env), right(env)) }, nil
+	case p.accept("!="):
+		right, err := p.operand()
+		if err != nil {
+			return nil, err
+		}
+		return func(env exprEnv) any { return !equal(left(env), right(env)) }, nil
+	}
+	return left, nil
+}
+
+func (p *exprParser) operand() (exprNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokString:
+		s := t.text
+		return func(exprEnv) any { return s }, nil
+	case tokOp:
+		if t.text != "(" {
+			return nil, fmt.Errorf("unexpected %q at %d", t.text, t.pos)
+		}
+		inner, err := p.or()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case tokIdent:
+		return p.ident(t)
+	}
+	return nil, fmt.Errorf("unexpected end of expression")
+}
+
+func (p *exprParser) ident(t token) (exprNode, error) {
+	switch t.text {
+	case "true":
+		return func(exprEnv) any { return true }, nil
+	case "false":
+		return func(exprEnv) any { return false }, nil
+	case "subject":
+		return func(env exprEnv) any { return env.principal.Subject }, nil
+	case "has_scope", "has_role":
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		arg := p.next()
+		if arg.kind != tokString {
+			return nil, fmt.Errorf("%s expects a string argument at %d", t.text, arg.pos)
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		if t.text == "has_scope" {
+			return func(env exprEnv) any { return env.principal.HasScope(arg.text) }, nil
+		}
+		return func(env exprEnv) any { return env.principal.HasRole(arg.text) }, nil
+	}
+
+	root, rest, _ := strings.Cut(t.text, ".")
+	switch {
+	case root == "params" && rest != "" && !strings.Contains(rest, "."):
+		return func(env exprEnv) any { return env.params.ByName(rest) }, nil
+	case root == "claims" && rest != "":
+		path := strings.Split(rest, ".")
+		return func(env exprEnv) any { return lookupClaim(env.principal.Claims, path) }, nil
+	}
+	return nil, fmt.Errorf("unknown identifier %q at %d", t.text, t.pos)
+}
+
+// lookupClaim resolves a dotted path into nested claim objects
+func lookupClaim(claims map[string]any, path []string) any {
+	var cur any = claims
+	for _, name := range path {
+		m, ok := cur.(map[string]any)
+		if !ok {
+			return nil
+		}
+		cur = m[name]
+	}
+	return cur
+}
+
+// truthy converts an expression value to a boolean
+func truthy(v any) bool {
+	switch t := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return t
+	case string:
+		return t != ""
+	case []any:
+		return len(t) > 0
+	case []string:
+		return len(t) > 0
+	case float64:
+		return t != 0
+	}
+	return true
+}
+
+// equal compares two expression values; a list on either side tests membership
+func equal(a, b any) bool {
+	if list, ok := a.([]any); ok {
+		return contains(list, b)
+	}
+	if list, ok := b.([]any); ok {
+		return contains(list, a)
+	}
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+	return exprString(a) == exprString(b)
+}
+
+// exprString renders a value for comparison. JSON numbers decode as float64,
+// which fmt prints in exponent form (1e+06), so they are formatted without one.
+func exprString(v any) string {
+	if f, ok := v.(float64); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return fmt.Sprint(v)
+}
+
+func contains(list []any, v any) bool {
+	for _, e := range list {
+		if equal(e, v) {
+			return true
+		}
+	}
+	return false
+}
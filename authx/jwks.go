@@ -0,0 +1,304 @@
+package authx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Key is a verification key resolved from a key source
+type Key struct {
+	// ID is the key ID ("kid"), empty if the key has none
+	ID string
+
+	// Algorithm restricts the key to one JWS algorithm, empty allows any compatible one
+	Algorithm string
+
+	// Public is *rsa.PublicKey, *ecdsa.PublicKey, ed25519.PublicKey or []byte for HMAC
+	Public any
+}
+
+// KeySource resolves verification keys. When kid is empty all keys are returned.
+type KeySource interface {
+	Keys(ctx context.Context, kid string) ([]Key, error)
+}
+
+// StaticKeys is a fixed set of verification keys
+type StaticKeys []Key
+
+// Keys returns the keys matching kid
+func (s StaticKeys) Keys(_ context.Context, kid string) ([]Key, error) {
+	return filterKeys(s, kid), nil
+}
+
+func filterKeys(keys []Key, kid string) []Key {
+	if kid == "" {
+		return keys
+	}
+	var out []Key
+	for _, k := range keys {
+		if k.ID == kid {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// jwk is a single JSON Web Key
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Use string `json:"use"`
+	Crv string `json:"crv"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseJWKS parses a JSON Web Key Set. Keys for other uses than signing and
+// unsupported key types are skipped.
+func ParseJWKS(data []byte) ([]Key, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("authx: invalid jwks: %w", err)
+	}
+
+	keys := make([]Key, 0, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.public()
+		if err != nil {
+			return nil, fmt.Errorf("authx: invalid jwk %q: %w", k.Kid, err)
+		}
+		if pub == nil {
+			continue
+		}
+		keys = append(keys, Key{ID: k.Kid, Algorithm: k.Alg, Public: pub})
+	}
+	return keys, nil
+}
+
+func (k jwk) public() (any, error) {
+	dec := base64.RawURLEncoding.DecodeString
+	switch k.Kty {
+	case "RSA":
+		n, err := dec(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := dec(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, nil
+		}
+		x, err := dec(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := dec(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	case "OKP":
+		if k.Crv != "Ed25519" {
+			return nil, nil
+		}
+		x, err := dec(k.X)
+		if err != nil {
+			return nil, err
+		}
+		if len(x) != ed25519.PublicKeySize {
+			return nil, errors.New("invalid Ed25519 key size")
+		}
+		return ed25519.PublicKey(x), nil
+	case "oct":
+		return dec(k.K)
+	}
+	return nil, nil
+}
+
+// NewJWKSFile loads a key set from a local JWKS file
+func NewJWKSFile(path string) (StaticKeys, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("authx: read jwks file: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+// jwksMaxBackoff caps the delay between fetch attempts after failures
+const jwksMaxBackoff = 5 * time.Minute
+
+// JWKSURL fetches a key set over HTTP and caches it. The set is refreshed after
+// the refresh interval, and early when a token references an unknown key ID,
+// at most once per minimum refresh interval.
+//
+// Concurrent requests share a single fetch, which runs without holding the
+// cache lock. After a failed fetch further attempts back off exponentially up
+// to five minutes; meanwhile the cached keys are served, or the last error when
+// none were ever fetched.
+type JWKSURL struct {
+	url        string
+	client     *http.Client
+	refresh    time.Duration
+	minRefresh time.Duration
+	backoff    time.Duration
+
+	mu       sync.Mutex
+	keys     []Key
+	fetched  time.Time
+	failures int
+	retryAt  time.Time
+	lastErr  error
+	inflight *jwksFetch
+}
+
+// jwksFetch is a fetch in progress; done is closed once err is set
+type jwksFetch struct {
+	done chan struct{}
+	err  error
+}
+
+// NewJWKSURL creates a key source that fetches keys from url.
+// A nil client uses a client with a 10s timeout.
+func NewJWKSURL(url string, client *http.Client, refresh time.Duration) *JWKSURL {
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &JWKSURL{
+		url:        url,
+		client:     client,
+		refresh:    refresh,
+		minRefresh: 10 * time.Second,
+		backoff:    time.Second,
+	}
+}
+
+// Keys returns the cached keys matching kid, fetching the set when stale
+func (s *JWKSURL) Keys(ctx context.Context, kid string) ([]Key, error) {
+	s.mu.Lock()
+	stale := s.keys == nil || (s.refresh > 0 && time.Since(s.fetched) > s.refresh)
+	s.mu.Unlock()
+	if stale {
+		if err := s.await(ctx); err != nil {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			if s.keys == nil {
+				return nil, err
+			}
+			return filterKeys(s.keys, kid), nil
+		}
+	}
+
+	s.mu.Lock()
+	keys := filterKeys(s.keys, kid)
+	unknown := len(keys) == 0 && kid != "" && time.Since(s.fetched) > s.minRefresh
+	s.mu.Unlock()
+	if unknown {
+		// Unknown key ID: the issuer may have rotated keys
+		if err := s.await(ctx); err != nil {
+			return nil, err
+		}
+		s.mu.Lock()
+		keys = filterKeys(s.keys, kid)
+		s.mu.Unlock()
+	}
+	return keys, nil
+}
+
+// await joins the fetch in progress or starts one, unless failures are backing off
+func (s *JWKSURL) await(ctx context.Context) error {
+	s.mu.Lock()
+	f := s.inflight
+	if f == nil {
+		if time.Now().Before(s.retryAt) {
+			err := s.lastErr
+			s.mu.Unlock()
+			return err
+		}
+		f = &jwksFetch{done: make(chan struct{})}
+		s.inflight = f
+		// The fetch outlives a caller whose request is cancelled, since others may be waiting
+		go s.run(context.WithoutCancel(ctx), f)
+	}
+	s.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// run performs the fetch and records its outcome
+func (s *JWKSURL) run(ctx context.Context, f *jwksFetch) {
+	keys, err := s.fetch(ctx)
+
+	s.mu.Lock()
+	s.fetched = time.Now()
+	if err != nil {
+		s.failures++
+		delay := s.backoff << min(s.failures-1, 16)
+		if delay <= 0 || delay > jwksMaxBackoff {
+			delay = jwksMaxBackoff
+		}
+		s.retryAt = s.fetched.Add(delay)
+		s.lastErr = err
+	} else {
+		s.keys = keys
+		s.failures = 0
+		s.retryAt = time.Time{}
+		s.lastErr = nil
+	}
+	s.inflight = nil
+	f.err = err
+	s.mu.Unlock()
+	close(f.done)
+}
+
+// fetch downloads and parses the key set
+func (s *JWKSURL) fetch(ctx context.Context) ([]Key, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("authx: fetch jwks: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("authx: fetch jwks: unexpected status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("authx: fetch jwks: %w", err)
+	}
+	return ParseJWKS(data)
+}
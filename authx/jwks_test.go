@@ -0,0 +1,245 @@
+package authx
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testJWKS encodes the test public keys as a JSON Web Key Set
+func testJWKS(t *testing.T, rsaKid string, rsaKey *rsa.PublicKey) []byte {
+	t.Helper()
+
+	enc := base64.RawURLEncoding.EncodeToString
+	set := map[string]any{"keys": []map[string]any{
+		{
+			"kty": "RSA", "kid": rsaKid, "alg": AlgRS256, "use": "sig",
+			"n": enc(rsaKey.N.Bytes()), "e": enc(big.NewInt(int64(rsaKey.E)).Bytes()),
+		},
+		{
+			"kty": "EC", "kid": "ec", "crv": "P-256",
+			"x": enc(testECKey.X.Bytes()), "y": enc(testECKey.Y.Bytes()),
+		},
+		{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": enc(testEdPub)},
+		{"kty": "RSA", "kid": "enc", "use": "enc", "n": "AQAB", "e": "AQAB"},
+	}}
+	data, err := json.Marshal(set)
+	require.NoError(t, err)
+	return data
+}
+
+func TestParseJWKS(t *testing.T) {
+	keys, err := ParseJWKS(testJWKS(t, "rsa", &testRSAKey.PublicKey))
+	require.NoError(t, err)
+	require.Len(t, keys, 3, "encryption keys are skipped")
+
+	v, err := NewJWTVerifierWithKeys(JWTConfig{}, StaticKeys(keys))
+	require.NoError(t, err)
+	for _, tt := range []struct {
+		alg, kid string
+		key      any
+	}{
+		{AlgRS256, "rsa", testRSAKey},
+		{AlgES256, "ec", testECKey},
+		{AlgEdDSA, "ed", testEdKey},
+	} {
+		_, err := v.Verify(context.Background(), signToken(t, tt.alg, tt.kid, tt.key, testClaims()))
+		assert.NoError(t, err, tt.alg)
+	}
+
+	_, err = ParseJWKS([]byte("{"))
+	assert.Error(t, err)
+}
+
+func TestNewJWKSFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	require.NoError(t, os.WriteFile(path, testJWKS(t, "rsa", &testRSAKey.PublicKey), 0o600))
+
+	v, err := NewJWTVerifier(JWTConfig{JWKSFile: path})
+	require.NoError(t, err)
+
+	p, err := v.Verify(context.Background(), signToken(t, AlgRS256, "rsa", testRSAKey, testClaims()))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1", p.Subject)
+
+	_, err = NewJWTVerifier(JWTConfig{JWKSFile: filepath.Join(t.TempDir(), "missing.json")})
+	assert.Error(t, err)
+}
+
+// jwksServer serves a key set that tests can rotate
+type jwksServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	body     []byte
+	requests atomic.Int32
+}
+
+func newJWKSServer(t *testing.T, body []byte) *jwksServer {
+	s := &jwksServer{body: body}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.requests.Add(1)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(s.body)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) rotate(body []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.body = body
+}
+
+func TestJWKSURL(t *testing.T) {
+	t.Run("caches keys between refreshes", func(t *testing.T) {
+		srv := newJWKSServer(t, testJWKS(t, "rsa", &testRSAKey.PublicKey))
+		v, err := NewJWTVerifier(JWTConfig{JWKSURL: srv.URL, JWKSRefresh: time.Hour})
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := v.Verify(context.Background(), signToken(t, AlgRS256, "rsa", testRSAKey, testClaims()))
+			require.NoError(t, err)
+		}
+		assert.Equal(t, int32(1), srv.requests.Load())
+	})
+
+	t.Run("refetches on an unknown key ID after rotation", func(t *testing.T) {
+		srv := newJWKSServer(t, testJWKS(t, "rsa", &testRSAKey.PublicKey))
+		source := NewJWKSURL(srv.URL, srv.Client(), time.Hour)
+		source.minRefresh = 0
+		v, err := NewJWTVerifierWithKeys(JWTConfig{}, source)
+		require.NoError(t, err)
+
+		_, err = v.Verify(context.Background(), signToken(t, AlgRS256, "rsa", testRSAKey, testClaims()))
+		require.NoError(t, err)
+
+		rotated, _ := rsa.GenerateKey(rand.Reader, 2048)
+		srv.rotate(testJWKS(t, "rsa-2", &rotated.PublicKey))
+
+		_, err = v.Verify(context.Background(), signToken(t, AlgRS256, "rsa-2", rotated, testClaims()))
+		require.NoError(t, err)
+		assert.Equal(t, int32(2), srv.requests.Load())
+	})
+
+	t.Run("rate limits refetches for unknown key IDs", func(t *testing.T) {
+		srv := newJWKSServer(t, testJWKS(t, "rsa", &testRSAKey.PublicKey))
+		v, err := NewJWTVerifier(JWTConfig{JWKSURL: srv.URL, JWKSRefresh: time.Hour})
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			_, err := v.Verify(context.Background(), signToken(t, AlgRS256, "unknown", testRSAKey, testClaims()))
+			assert.ErrorIs(t, err, ErrTokenSignature)
+		}
+		assert.Equal(t, int32(1), srv.requests.Load())
+	})
+
+	t.Run("reports fetch failures", func(t *testing.T) {
+		srv := httptest.NewServer(http.NotFoundHandler())
+		defer srv.Close()
+
+		_, err := NewJWKSURL(srv.URL, nil, time.Hour).Keys(context.Background(), "")
+		assert.Error(t, err)
+	})
+
+	t.Run("shares one fetch between concurrent requests", func(t *testing.T) {
+		release := make(chan struct{})
+		var requests atomic.Int32
+		body := testJWKS(t, "rsa", &testRSAKey.PublicKey)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			<-release
+			_, _ = w.Write(body)
+		}))
+		defer srv.Close()
+
+		source := NewJWKSURL(srv.URL, srv.Client(), time.Hour)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				keys, err := source.Keys(context.Background(), "rsa")
+				assert.NoError(t, err)
+				assert.Len(t, keys, 1)
+			}()
+		}
+		require.Eventually(t, func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+
+		// A waiter giving up does not hold the cache lock or cancel the shared fetch
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := source.Keys(ctx, "rsa")
+		assert.ErrorIs(t, err, context.Canceled)
+
+		close(release)
+		wg.Wait()
+		assert.Equal(t, int32(1), requests.Load())
+	})
+
+	t.Run("backs off after failed fetches", func(t *testing.T) {
+		var requests atomic.Int32
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(http.StatusBadGateway)
+		}))
+		defer srv.Close()
+
+		source := NewJWKSURL(srv.URL, srv.Client(), time.Hour)
+		source.backoff = 50 * time.Millisecond
+		for i := 0; i < 5; i++ {
+			_, err := source.Keys(context.Background(), "rsa")
+			assert.ErrorContains(t, err, "unexpected status 502")
+		}
+		assert.Equal(t, int32(1), requests.Load())
+
+		time.Sleep(60 * time.Millisecond)
+		_, err := source.Keys(context.Background(), "rsa")
+		assert.Error(t, err)
+		assert.Equal(t, int32(2), requests.Load())
+
+		// The delay doubles after each consecutive failure
+		time.Sleep(60 * time.Millisecond)
+		_, _ = source.Keys(context.Background(), "rsa")
+		assert.Equal(t, int32(2), requests.Load())
+	})
+
+	t.Run("serves cached keys while refreshes fail", func(t *testing.T) {
+		var fail atomic.Bool
+		body := testJWKS(t, "rsa", &testRSAKey.PublicKey)
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if fail.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write(body)
+		}))
+		defer srv.Close()
+
+		source := NewJWKSURL(srv.URL, srv.Client(), time.Millisecond)
+		_, err := source.Keys(context.Background(), "rsa")
+		require.NoError(t, err)
+
+		fail.Store(true)
+		time.Sleep(5 * time.Millisecond)
+		keys, err := source.Keys(context.Background(), "rsa")
+		require.NoError(t, err)
+		assert.Len(t, keys, 1)
+	})
+}
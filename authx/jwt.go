@@ -0,0 +1,321 @@
+package authx
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Supported JWS algorithms
+const (
+	AlgRS256 = "RS256"
+	AlgES256 = "ES256"
+	AlgEdDSA = "EdDSA"
+	AlgHS256 = "HS256"
+)
+
+var (
+	// ErrTokenMalformed is returned when a token cannot be parsed
+	ErrTokenMalformed = errors.New("authx: malformed token")
+
+	// ErrTokenSignature is returned when no key verifies the signature
+	ErrTokenSignature = errors.New("authx: invalid token signature")
+
+	// ErrTokenExpired is returned when the token is expired or not yet valid
+	ErrTokenExpired = errors.New("authx: token expired or not yet valid")
+
+	// ErrTokenClaims is returned when the issuer or audience does not match or exp is missing
+	ErrTokenClaims = errors.New("authx: invalid token claims")
+)
+
+// JWTConfig contains JWT bearer token validation configuration
+type JWTConfig struct {
+	// Issuer is the required "iss" claim; empty skips the check
+	Issuer string `mapstructure:"issuer"`
+
+	// Audience lists accepted "aud" values; empty skips the check
+	Audience []string `mapstructure:"audience"`
+
+	// Algorithms lists accepted JWS algorithms; empty derives them from the key source
+	Algorithms []string `mapstructure:"algorithms"`
+
+	// AllowMissingExpiry accepts tokens without an "exp" claim; by default they are rejected
+	AllowMissingExpiry bool `mapstructure:"allow_missing_expiry"`
+
+	// ClockSkew is the tolerance applied to exp, nbf and iat
+	ClockSkew time.Duration `mapstructure:"clock_skew" default:"30s"`
+
+	// JWKSFile is a local JWKS file with verification keys
+	JWKSFile string `mapstructure:"jwks_file"`
+
+	// JWKSURL is a remote JWKS endpoint with verification keys
+	JWKSURL string `mapstructure:"jwks_url"`
+
+	// JWKSRefresh is how often the remote key set is refreshed
+	JWKSRefresh time.Duration `mapstructure:"jwks_refresh" default:"10m"`
+
+	// HMACSecret is the shared secret for HS256 tokens
+	HMACSecret string `mapstructure:"hmac_secret"`
+}
+
+// Enabled reports whether a key source is configured
+func (c JWTConfig) Enabled() bool {
+	return c.JWKSFile != "" || c.JWKSURL != "" || c.HMACSecret != ""
+}
+
+// JWTVerifier validates JWT bearer tokens
+type JWTVerifier struct {
+	cfg        JWTConfig
+	keys       KeySource
+	algorithms []string
+	now        func() time.Time
+}
+
+// NewJWTVerifier creates a verifier using the key sources described by configuration
+func NewJWTVerifier(cfg JWTConfig) (*JWTVerifier, error) {
+	var sources chainKeys
+	if cfg.JWKSURL != "" {
+		sources = append(sources, NewJWKSURL(cfg.JWKSURL, nil, cfg.JWKSRefresh))
+	}
+	if cfg.JWKSFile != "" {
+		keys, err := NewJWKSFile(cfg.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, keys)
+	}
+	if cfg.HMACSecret != "" {
+		sources = append(sources, StaticKeys{{Algorithm: AlgHS256, Public: []byte(cfg.HMACSecret)}})
+	}
+	if len(sources) == 0 {
+		return nil, errors.New("authx: no jwt key source configured")
+	}
+
+	if len(cfg.Algorithms) == 0 {
+		if cfg.JWKSURL != "" || cfg.JWKSFile != "" {
+			cfg.Algorithms = append(cfg.Algorithms, AlgRS256, AlgES256, AlgEdDSA)
+		}
+		if cfg.HMACSecret != "" {
+			cfg.Algorithms = append(cfg.Algorithms, AlgHS256)
+		}
+	}
+	return NewJWTVerifierWithKeys(cfg, sources)
+}
+
+// chainKeys combines key sources, returning keys from all of them
+type chainKeys []KeySource
+
+func (c chainKeys) Keys(ctx context.Context, kid string) ([]Key, error) {
+	var out []Key
+	for _, s := range c {
+		keys, err := s.Keys(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, keys...)
+	}
+	return out, nil
+}
+
+// NewJWTVerifierWithKeys creates a verifier with a custom key source
+func NewJWTVerifierWithKeys(cfg JWTConfig, keys KeySource) (*JWTVerifier, error) {
+	algs := cfg.Algorithms
+	if len(algs) == 0 {
+		algs = []string{AlgRS256, AlgES256, AlgEdDSA}
+	}
+	for _, a := range algs {
+		switch a {
+		case AlgRS256, AlgES256, AlgEdDSA, AlgHS256:
+		default:
+			return nil, fmt.Errorf("authx: unsupported jwt algorithm %q", a)
+		}
+	}
+	return &JWTVerifier{cfg: cfg, keys: keys, algorithms: algs, now: time.Now}, nil
+}
+
+// Verify validates the token signature and claims and returns the principal
+func (v *JWTVerifier) Verify(ctx context.Context, token string) (*Principal, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if !slices.Contains(v.algorithms, header.Alg) {
+		return nil, fmt.Errorf("%w: algorithm %q not allowed", ErrTokenSignature, header.Alg)
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	keys, err := v.keys.Keys(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	signed := []byte(parts[0] + "." + parts[1])
+	verified := false
+	for _, k := range keys {
+		if k.Algorithm != "" && k.Algorithm != header.Alg {
+			continue
+		}
+		if verifySignature(header.Alg, k.Public, signed, sig) {
+			verified = true
+			break
+		}
+	}
+	if !verified {
+		return nil, ErrTokenSignature
+	}
+
+	var claims map[string]any
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	return v.principal(claims)
+}
+
+// principal validates registered claims and builds the principal
+func (v *JWTVerifier) principal(claims map[string]any) (*Principal, error) {
+	now := v.now()
+	skew := v.cfg.ClockSkew
+
+	exp, hasExp := numericDate(claims["exp"])
+	if !hasExp && !v.cfg.AllowMissingExpiry {
+		return nil, fmt.Errorf("%w: missing exp", ErrTokenClaims)
+	}
+	if hasExp && now.After(exp.Add(skew)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := numericDate(claims["nbf"]); ok && now.Before(nbf.Add(-skew)) {
+		return nil, ErrTokenExpired
+	}
+	if iat, ok := numericDate(claims["iat"]); ok && now.Before(iat.Add(-skew)) {
+		return nil, ErrTokenExpired
+	}
+
+	iss, _ := claims["iss"].(string)
+	if v.cfg.Issuer != "" && iss != v.cfg.Issuer {
+		return nil, fmt.Errorf("%w: issuer %q", ErrTokenClaims, iss)
+	}
+
+	aud := stringList(claims["aud"])
+	if len(v.cfg.Audience) > 0 && !slices.ContainsFunc(aud, func(a string) bool {
+		return slices.Contains(v.cfg.Audience, a)
+	}) {
+		return nil, fmt.Errorf("%w: audience", ErrTokenClaims)
+	}
+
+	sub, _ := claims["sub"].(string)
+	p := &Principal{
+		Subject:  sub,
+		Issuer:   iss,
+		Audience: aud,
+		Roles:    stringList(claims["roles"]),
+		Method:   "jwt",
+		Claims:   claims,
+	}
+	if hasExp {
+		p.ExpiresAt = exp
+	}
+	if scope, ok := claims["scope"].(string); ok {
+		p.Scopes = strings.Fields(scope)
+	} else if scp, ok := claims["scp"]; ok {
+		p.Scopes = stringList(scp)
+	}
+	return p, nil
+}
+
+// verifySignature checks sig over signed with the key for the algorithm
+func verifySignature(alg string, key any, signed, sig []byte) bool {
+	switch alg {
+	case AlgRS256:
+		pub, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		sum := sha256.Sum256(signed)
+		return rsa.VerifyPKCS1v15(pub, crypto.SHA256, sum[:], sig) == nil
+	case AlgES256:
+		pub, ok := key.(*ecdsa.PublicKey)
+		if !ok || len(sig) != 64 {
+			return false
+		}
+		sum := sha256.Sum256(signed)
+		r := new(big.Int).SetBytes(sig[:32])
+		s := new(big.Int).SetBytes(sig[32:])
+		return ecdsa.Verify(pub, sum[:], r, s)
+	case AlgEdDSA:
+		pub, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return false
+		}
+		return ed25519.Verify(pub, signed, sig)
+	case AlgHS256:
+		secret, ok := key.([]byte)
+		if !ok || len(secret) == 0 {
+			return false
+		}
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(signed)
+		return hmac.Equal(mac.Sum(nil), sig)
+	}
+	return false
+}
+
+func decodeSegment(seg string, v any) error {
+	data, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// numericDate converts a JWT NumericDate claim
+func numericDate(v any) (time.Time, bool) {
+	f, ok := v.(float64)
+	if !ok {
+		return time.Time{}, false
+	}
+	sec, frac := int64(f), f-float64(int64(f))
+	return time.Unix(sec, int64(frac*1e9)), true
+}
+
+// stringList converts a claim that may be a string or an array of strings
+func stringList(v any) []string {
+	switch t := v.(type) {
+	case string:
+		if t == "" {
+			return nil
+		}
+		return []string{t}
+	case []any:
+		out := make([]string, 0, len(t))
+		for _, e := range t {
+			if s, ok := e.(string); ok {
+				out = append(out, s)
+			}
+		}
+		return out
+	}
+	return nil
+}
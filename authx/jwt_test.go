@@ -0,0 +1,213 @@
+package authx
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testRSAKey, _           = rsa.GenerateKey(rand.Reader, 2048)
+	testECKey, _            = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	testEdPub, testEdKey, _ = ed25519.GenerateKey(rand.Reader)
+)
+
+// signToken builds a compact JWS for the claims; key is a private key or an HMAC secret
+func signToken(t *testing.T, alg, kid string, key any, claims map[string]any) string {
+	t.Helper()
+
+	header := map[string]any{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	enc := func(v any) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := enc(header) + "." + enc(claims)
+	sum := sha256.Sum256([]byte(signed))
+
+	var sig []byte
+	switch alg {
+	case AlgRS256:
+		s, err := rsa.SignPKCS1v15(rand.Reader, key.(*rsa.PrivateKey), crypto.SHA256, sum[:])
+		require.NoError(t, err)
+		sig = s
+	case AlgES256:
+		r, s, err := ecdsa.Sign(rand.Reader, key.(*ecdsa.PrivateKey), sum[:])
+		require.NoError(t, err)
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	case AlgEdDSA:
+		sig = ed25519.Sign(key.(ed25519.PrivateKey), []byte(signed))
+	case AlgHS256:
+		mac := hmac.New(sha256.New, key.([]byte))
+		mac.Write([]byte(signed))
+		sig = mac.Sum(nil)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func testClaims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"sub":   "user-1",
+		"iss":   "https://issuer.example.com",
+		"aud":   "api",
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"scope": "read write",
+		"roles": []string{"admin"},
+	}
+}
+
+func testKeys() StaticKeys {
+	return StaticKeys{
+		{ID: "rsa", Algorithm: AlgRS256, Public: &testRSAKey.PublicKey},
+		{ID: "ec", Algorithm: AlgES256, Public: &testECKey.PublicKey},
+		{ID: "ed", Algorithm: AlgEdDSA, Public: testEdPub},
+	}
+}
+
+func TestJWTVerifierAlgorithms(t *testing.T) {
+	tests := []struct {
+		alg string
+		kid string
+		key any
+	}{
+		{AlgRS256, "rsa", testRSAKey},
+		{AlgES256, "ec", testECKey},
+		{AlgEdDSA, "ed", testEdKey},
+	}
+
+	v, err := NewJWTVerifierWithKeys(JWTConfig{}, testKeys())
+	require.NoError(t, err)
+
+	for _, tt := range tests {
+		t.Run(tt.alg, func(t *testing.T) {
+			p, err := v.Verify(context.Background(), signToken(t, tt.alg, tt.kid, tt.key, testClaims()))
+			require.NoError(t, err)
+			assert.Equal(t, "user-1", p.Subject)
+			assert.Equal(t, "jwt", p.Method)
+			assert.Equal(t, []string{"api"}, p.Audience)
+			assert.True(t, p.HasScope("write"))
+			assert.True(t, p.HasRole("admin"))
+			assert.False(t, p.ExpiresAt.IsZero())
+		})
+	}
+
+	t.Run("HS256 with configured secret", func(t *testing.T) {
+		v, err := NewJWTVerifier(JWTConfig{HMACSecret: "s3cret"})
+		require.NoError(t, err)
+
+		_, err = v.Verify(context.Background(), signToken(t, AlgHS256, "", []byte("s3cret"), testClaims()))
+		require.NoError(t, err)
+
+		_, err = v.Verify(context.Background(), signToken(t, AlgHS256, "", []byte("other"), testClaims()))
+		assert.ErrorIs(t, err, ErrTokenSignature)
+	})
+
+	t.Run("rejects algorithms that are not allowed", func(t *testing.T) {
+		keys := append(testKeys(), Key{Public: []byte("s3cret")})
+		v, err := NewJWTVerifierWithKeys(JWTConfig{Algorithms: []string{AlgRS256}}, keys)
+		require.NoError(t, err)
+
+		_, err = v.Verify(context.Background(), signToken(t, AlgHS256, "", []byte("s3cret"), testClaims()))
+		assert.ErrorIs(t, err, ErrTokenSignature)
+	})
+
+	t.Run("rejects a signature from the wrong key", func(t *testing.T) {
+		other, _ := rsa.GenerateKey(rand.Reader, 2048)
+		_, err := v.Verify(context.Background(), signToken(t, AlgRS256, "rsa", other, testClaims()))
+		assert.ErrorIs(t, err, ErrTokenSignature)
+	})
+
+	t.Run("rejects malformed tokens", func(t *testing.T) {
+		_, err := v.Verify(context.Background(), "not-a-token")
+		assert.ErrorIs(t, err, ErrTokenMalformed)
+	})
+
+	t.Run("rejects unsupported configured algorithms", func(t *testing.T) {
+		_, err := NewJWTVerifierWithKeys(JWTConfig{Algorithms: []string{"none"}}, testKeys())
+		assert.Error(t, err)
+	})
+}
+
+func TestJWTVerifierClaims(t *testing.T) {
+	cfg := JWTConfig{
+		Issuer:    "https://issuer.example.com",
+		Audience:  []string{"api", "admin-api"},
+		ClockSkew: 30 * time.Second,
+	}
+	v, err := NewJWTVerifierWithKeys(cfg, testKeys())
+	require.NoError(t, err)
+
+	verify := func(t *testing.T, mutate func(map[string]any)) error {
+		claims := testClaims()
+		mutate(claims)
+		_, err := v.Verify(context.Background(), signToken(t, AlgRS256, "rsa", testRSAKey, claims))
+		return err
+	}
+
+	t.Run("accepts any configured audience", func(t *testing.T) {
+		assert.NoError(t, verify(t, func(c map[string]any) { c["aud"] = []string{"other", "admin-api"} }))
+	})
+
+	t.Run("rejects a wrong audience", func(t *testing.T) {
+		assert.ErrorIs(t, verify(t, func(c map[string]any) { c["aud"] = "other" }), ErrTokenClaims)
+	})
+
+	t.Run("rejects a wrong issuer", func(t *testing.T) {
+		assert.ErrorIs(t, verify(t, func(c map[string]any) { c["iss"] = "https://evil.example.com" }), ErrTokenClaims)
+	})
+
+	t.Run("rejects expired tokens", func(t *testing.T) {
+		err := verify(t, func(c map[string]any) { c["exp"] = time.Now().Add(-time.Minute).Unix() })
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("tolerates expiry within the clock skew", func(t *testing.T) {
+		assert.NoError(t, verify(t, func(c map[string]any) { c["exp"] = time.Now().Add(-10 * time.Second).Unix() }))
+	})
+
+	t.Run("rejects tokens without expiry", func(t *testing.T) {
+		assert.ErrorIs(t, verify(t, func(c map[string]any) { delete(c, "exp") }), ErrTokenClaims)
+
+		lenient, err := NewJWTVerifierWithKeys(JWTConfig{AllowMissingExpiry: true}, testKeys())
+		require.NoError(t, err)
+		claims := testClaims()
+		delete(claims, "exp")
+		p, err := lenient.Verify(context.Background(), signToken(t, AlgRS256, "rsa", testRSAKey, claims))
+		require.NoError(t, err)
+		assert.True(t, p.ExpiresAt.IsZero())
+	})
+
+	t.Run("rejects tokens not yet valid", func(t *testing.T) {
+		err := verify(t, func(c map[string]any) { c["nbf"] = time.Now().Add(time.Minute).Unix() })
+		assert.ErrorIs(t, err, ErrTokenExpired)
+	})
+
+	t.Run("reads scopes from scp", func(t *testing.T) {
+		claims := testClaims()
+		delete(claims, "scope")
+		claims["scp"] = []string{"read"}
+		p, err := v.Verify(context.Background(), signToken(t, AlgRS256, "rsa", testRSAKey, claims))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"read"}, p.Scopes)
+	})
+}
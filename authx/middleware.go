@@ -0,0 +1,110 @@
+package authx
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/responsex"
+)
+
+// bearerToken extracts the token from an "Authorization: Bearer" header
+func bearerToken(c *gin.Context) string {
+	scheme, token, ok := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return ""
+	}
+	return strings.TrimSpace(token)
+}
+
+// JWTMiddleware authenticates requests with a JWT bearer token. The verified
+// principal is stored on the request context; failures get a 401 error envelope.
+func JWTMiddleware(v *JWTVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := bearerToken(c)
+		if token == "" {
+			c.Header("WWW-Authenticate", `Bearer`)
+			Unauthorized(c, "missing bearer token")
+			return
+		}
+
+		p, err := v.Verify(c.Request.Context(), token)
+		if err != nil {
+			c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+			msg := "invalid bearer token"
+			if errors.Is(err, ErrTokenExpired) {
+				msg = "bearer token expired"
+			}
+			Unauthorized(c, msg)
+			return
+		}
+
+		c.Request = c.Request.WithContext(WithPrincipal(c.Request.Context(), p))
+		c.Next()
+	}
+}
+
+// Authenticator resolves the principal from a request's credentials. It
+// returns nil without error when the request carries no credential it handles,
+// and an error when the credential is invalid.
+type Authenticator func(c *gin.Context) (*Principal, error)
+
+// JWTAuthenticator authenticates "Authorization: Bearer" tokens with the verifier
+func JWTAuthenticator(v *JWTVerifier) Authenticator {
+	return func(c *gin.Context) (*Principal, error) {
+		token := bearerToken(c)
+		if token == "" {
+			return nil, nil
+		}
+		return v.Verify(c.Request.Context(), token)
+	}
+}
+
+// Unauthorized aborts the request with a 401 error envelope
+func Unauthorized(c *gin.Context, message string) {
+	responsex.Error(c, http.StatusUnauthorized, "unauthorized", message, nil)
+	c.Abort()
+}
+
+// AuthenticationFailed aborts the request with the error envelope for an
+// authentication error: 503 when the credential store is unavailable, 429 with
+// Retry-After when a rate limit rejected the credential, and 401 otherwise
+func AuthenticationFailed(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ErrAuthUnavailable):
+		responsex.Error(c, http.StatusServiceUnavailable, "auth_unavailable", "credential store unavailable", nil)
+		c.Abort()
+	case errors.Is(err, ErrRateLimited):
+		c.Header("Retry-After", "1")
+		responsex.Error(c, http.StatusTooManyRequests, "rate_limited", "rate limit exceeded", nil)
+		c.Abort()
+	default:
+		Unauthorized(c, "invalid credentials")
+	}
+}
+
+// Forbidden aborts the request with a 403 error envelope
+func Forbidden(c *gin.Context, message string) {
+	responsex.Error(c, http.StatusForbidden, "forbidden", message, nil)
+	c.Abort()
+}
+
+// RequireScopes rejects requests whose principal lacks any of the scopes with 403.
+// Requests without a principal get 401, so it must run after an auth middleware.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p, ok := PrincipalFromContext(c.Request.Context())
+		if !ok {
+			Unauthorized(c, "authentication required")
+			return
+		}
+		for _, s := range scopes {
+			if !p.HasScope(s) {
+				Forbidden(c, "missing required scope: "+s)
+				return
+			}
+		}
+		c.Next()
+	}
+}
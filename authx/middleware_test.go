@@ -0,0 +1,139 @@
+package authx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJWTMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	v, err := NewJWTVerifierWithKeys(JWTConfig{}, testKeys())
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.Use(JWTMiddleware(v))
+	engine.GET("/me", func(c *gin.Context) {
+		p, ok := PrincipalFromContext(c.Request.Context())
+		require.True(t, ok)
+		c.String(http.StatusOK, p.Subject)
+	})
+
+	do := func(auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	errorCode := func(t *testing.T, w *httptest.ResponseRecorder) string {
+		var body struct {
+			Error struct {
+				Code    string `json:"code"`
+				Message string `json:"message"`
+			} `json:"error"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		return body.Error.Code
+	}
+
+	t.Run("stores the principal for valid tokens", func(t *testing.T) {
+		w := do("Bearer " + signToken(t, AlgRS256, "rsa", testRSAKey, testClaims()))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "user-1", w.Body.String())
+	})
+
+	t.Run("rejects missing tokens", func(t *testing.T) {
+		w := do("")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, "Bearer", w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, "unauthorized", errorCode(t, w))
+	})
+
+	t.Run("rejects other schemes", func(t *testing.T) {
+		w := do("Basic dXNlcjpwYXNz")
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+	})
+
+	t.Run("rejects expired tokens", func(t *testing.T) {
+		claims := testClaims()
+		claims["exp"] = time.Now().Add(-time.Hour).Unix()
+		w := do("Bearer " + signToken(t, AlgRS256, "rsa", testRSAKey, claims))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Equal(t, `Bearer error="invalid_token"`, w.Header().Get("WWW-Authenticate"))
+		assert.Equal(t, "unauthorized", errorCode(t, w))
+	})
+}
+
+func TestAuthenticators(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	v, err := NewJWTVerifierWithKeys(JWTConfig{}, testKeys())
+	require.NoError(t, err)
+	store, err := NewStaticAPIKeyStore([]APIKeyEntry{{ID: "reports", Hash: HashAPIKey("secret-2")}})
+	require.NoError(t, err)
+
+	authenticate := func(auth Authenticator, header, value string) (*Principal, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "/", nil)
+		if value != "" {
+			c.Request.Header.Set(header, value)
+		}
+		return auth(c)
+	}
+
+	t.Run("JWT bearer tokens", func(t *testing.T) {
+		auth := JWTAuthenticator(v)
+		p, err := authenticate(auth, "Authorization", "Bearer "+signToken(t, AlgRS256, "rsa", testRSAKey, testClaims()))
+		require.NoError(t, err)
+		assert.Equal(t, "user-1", p.Subject)
+
+		p, err = authenticate(auth, "Authorization", "")
+		assert.NoError(t, err)
+		assert.Nil(t, p, "requests without a token are left to other authenticators")
+
+		_, err = authenticate(auth, "Authorization", "Bearer not-a-token")
+		assert.ErrorIs(t, err, ErrTokenMalformed)
+	})
+
+	t.Run("API keys", func(t *testing.T) {
+		auth := APIKeyAuthenticator(store, APIKeyConfig{})
+		p, err := authenticate(auth, "X-API-Key", "secret-2")
+		require.NoError(t, err)
+		assert.Equal(t, "reports", p.Subject)
+
+		p, err = authenticate(auth, "X-API-Key", "")
+		assert.NoError(t, err)
+		assert.Nil(t, p)
+
+		_, err = authenticate(auth, "X-API-Key", "wrong")
+		assert.ErrorIs(t, err, ErrAPIKeyNotFound)
+	})
+
+	t.Run("API key hooks and store failures", func(t *testing.T) {
+		var used []string
+		auth := APIKeyAuthenticator(store, APIKeyConfig{},
+			WithAPIKeyRateLimit(func(_ context.Context, key *APIKey) bool { return len(used) < 1 }),
+			WithAPIKeyLastUsed(func(_ context.Context, key *APIKey, _ time.Time) { used = append(used, key.ID) }),
+		)
+		_, err := authenticate(auth, "X-API-Key", "secret-2")
+		require.NoError(t, err)
+		_, err = authenticate(auth, "X-API-Key", "secret-2")
+		assert.ErrorIs(t, err, ErrRateLimited)
+		assert.Equal(t, []string{"reports"}, used, "rejected requests are not reported as used")
+
+		_, err = authenticate(APIKeyAuthenticator(failingStore{}, APIKeyConfig{}), "X-API-Key", "secret-2")
+		assert.ErrorIs(t, err, ErrAuthUnavailable)
+	})
+}
@@ -0,0 +1,90 @@
+package authx
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Policy declares the requirements a principal must meet to access a route
+type Policy struct {
+	// Name identifies the policy in route listings and denial logs
+	Name string `mapstructure:"name"`
+
+	// Scopes must all be granted to the principal
+	Scopes []string `mapstructure:"scopes"`
+
+	// Roles grants access when the principal has any of them; empty skips the check
+	Roles []string `mapstructure:"roles"`
+
+	// Expr is a boolean expression over the principal's claims and path parameters,
+	// e.g. "claims.tenant == params.tenant && !claims.suspended"
+	Expr string `mapstructure:"expr"`
+}
+
+// Describe returns the policy name, or a summary of its requirements when unnamed
+func (p Policy) Describe() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	var parts []string
+	if len(p.Scopes) > 0 {
+		parts = append(parts, "scopes="+strings.Join(p.Scopes, ","))
+	}
+	if len(p.Roles) > 0 {
+		parts = append(parts, "roles="+strings.Join(p.Roles, ","))
+	}
+	if p.Expr != "" {
+		parts = append(parts, "expr="+p.Expr)
+	}
+	if len(parts) == 0 {
+		return "authenticated"
+	}
+	return strings.Join(parts, " ")
+}
+
+// CompiledPolicy is a policy with its expression parsed, ready for evaluation
+type CompiledPolicy struct {
+	Policy
+	expr exprNode
+}
+
+// Compile validates the policy and parses its expression
+func (p Policy) Compile() (*CompiledPolicy, error) {
+	cp := &CompiledPolicy{Policy: p}
+	if strings.TrimSpace(p.Expr) != "" {
+		node, err := parseExpr(p.Expr)
+		if err != nil {
+			return nil, fmt.Errorf("authx: policy %q: %w", p.Describe(), err)
+		}
+		cp.expr = node
+	}
+	return cp, nil
+}
+
+// Evaluate returns nil when the principal satisfies the policy, or an error
+// naming the first requirement it fails
+func (cp *CompiledPolicy) Evaluate(p *Principal, params gin.Params) error {
+	for _, s := range cp.Scopes {
+		if !p.HasScope(s) {
+			return fmt.Errorf("missing scope %q", s)
+		}
+	}
+	if len(cp.Roles) > 0 && !anyRole(p, cp.Roles) {
+		return fmt.Errorf("missing role, need one of %s", strings.Join(cp.Roles, ","))
+	}
+	if cp.expr != nil && !truthy(cp.expr(exprEnv{principal: p, params: params})) {
+		return fmt.Errorf("expression %q not satisfied", cp.Expr)
+	}
+	return nil
+}
+
+func anyRole(p *Principal, roles []string) bool {
+	for _, r := range roles {
+		if p.HasRole(r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package authx
+
+import (
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyEvaluate(t *testing.T) {
+	principal := &Principal{
+		Subject: "user-1",
+		Scopes:  []string{"orders:read", "orders:write"},
+		Roles:   []string{"support"},
+		Claims: map[string]any{
+			"tenant":    "acme",
+			"account":   float64(42),
+			"customer":  float64(1000000),
+			"ratio":     0.5,
+			"groups":    []any{"eu", "beta"},
+			"org":       map[string]any{"plan": "enterprise"},
+			"suspended": false,
+		},
+	}
+	params := gin.Params{{Key: "tenant", Value: "acme"}, {Key: "account", Value: "42"}, {Key: "customer", Value: "1000000"}}
+
+	tests := []struct {
+		name    string
+		policy  Policy
+		allowed bool
+	}{
+		{"no requirements", Policy{}, true},
+		{"all scopes granted", Policy{Scopes: []string{"orders:read", "orders:write"}}, true},
+		{"scope missing", Policy{Scopes: []string{"orders:read", "orders:delete"}}, false},
+		{"any role granted", Policy{Roles: []string{"admin", "support"}}, true},
+		{"role missing", Policy{Roles: []string{"admin"}}, false},
+		{"claim equals param", Policy{Expr: "claims.tenant == params.tenant"}, true},
+		{"numeric claim equals param", Policy{Expr: "claims.account == params.account"}, true},
+		{"large numeric claim equals param", Policy{Expr: "claims.customer == params.customer"}, true},
+		{"fractional claim", Policy{Expr: "claims.ratio == '0.5'"}, true},
+		{"claim differs", Policy{Expr: "claims.tenant != 'acme'"}, false},
+		{"list membership", Policy{Expr: `claims.groups == "beta"`}, true},
+		{"nested claim", Policy{Expr: "claims.org.plan == 'enterprise'"}, true},
+		{"negation", Policy{Expr: "!claims.suspended"}, true},
+		{"missing claim is falsy", Policy{Expr: "claims.missing"}, false},
+		{"precedence", Policy{Expr: "false && true || subject == 'user-1'"}, true},
+		{"parentheses", Policy{Expr: "false && (true || subject == 'user-1')"}, false},
+		{"functions", Policy{Expr: "has_scope('orders:read') && !has_role('admin')"}, true},
+		{"combined requirements", Policy{Scopes: []string{"orders:read"}, Expr: "claims.tenant == 'other'"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cp, err := tt.policy.Compile()
+			require.NoError(t, err)
+			err = cp.Evaluate(principal, params)
+			if tt.allowed {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestPolicyCompileErrors(t *testing.T) {
+	for _, expr := range []string{
+		"claims.tenant ==",
+		"(claims.tenant == 'a'",
+		"'unterminated",
+		"unknown == 'a'",
+		"params.a.b",
+		"has_scope(claims.x)",
+		"claims.a = 'b'",
+		"a b",
+	} {
+		_, err := Policy{Expr: expr}.Compile()
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestPolicyDescribe(t *testing.T) {
+	assert.Equal(t, "admin-only", Policy{Name: "admin-only", Roles: []string{"admin"}}.Describe())
+	assert.Equal(t, "scopes=a,b roles=r", Policy{Scopes: []string{"a", "b"}, Roles: []string{"r"}}.Describe())
+	assert.Equal(t, "authenticated", Policy{}.Describe())
+}
@@ -0,0 +1,59 @@
+// Package authx provides authentication middleware for httpx applications.
+// Verified identities are stored as a Principal on the request context.
+package authx
+
+import (
+	"context"
+	"slices"
+	"time"
+)
+
+// Principal is the authenticated identity of a request
+type Principal struct {
+	// Subject identifies the caller (JWT "sub" claim or API key owner)
+	Subject string `json:"sub"`
+
+	// Issuer is the token issuer, empty for non-token credentials
+	Issuer string `json:"iss,omitempty"`
+
+	// Audience lists the audiences the credential was issued for
+	Audience []string `json:"aud,omitempty"`
+
+	// Scopes are the OAuth-style scopes granted to the caller
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Roles are the roles granted to the caller
+	Roles []string `json:"roles,omitempty"`
+
+	// Method is the authentication method, e.g. "jwt"
+	Method string `json:"method"`
+
+	// ExpiresAt is when the credential expires, zero if it does not
+	ExpiresAt time.Time `json:"exp,omitempty"`
+
+	// Claims holds all verified claims
+	Claims map[string]any `json:"claims,omitempty"`
+}
+
+// HasScope reports whether the principal was granted the scope
+func (p *Principal) HasScope(scope string) bool {
+	return p != nil && slices.Contains(p.Scopes, scope)
+}
+
+// HasRole reports whether the principal was granted the role
+func (p *Principal) HasRole(role string) bool {
+	return p != nil && slices.Contains(p.Roles, role)
+}
+
+type principalKey struct{}
+
+// WithPrincipal returns a new context carrying the principal
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored on the context, if any
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
@@ -0,0 +1,311 @@
+package authx
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1" //nolint:gosec // required by providers still signing with HMAC-SHA1
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/responsex"
+)
+
+// WebhookConfig contains HMAC webhook signature verification configuration.
+// Fields left empty are filled from the preset, then from built-in defaults.
+type WebhookConfig struct {
+	// Preset selects a provider format: "github", "stripe" or "slack"
+	Preset string `mapstructure:"preset"`
+
+	// Secrets are the active signing secrets; several may be active during rotation
+	Secrets []string `mapstructure:"secrets"`
+
+	// Header is the request header carrying the signature
+	Header string `mapstructure:"header"`
+
+	// Prefix is stripped from the signature before decoding, e.g. "sha256="
+	Prefix string `mapstructure:"prefix"`
+
+	// SignatureKey parses the header as comma-separated key=value pairs and
+	// reads signatures from this key, e.g. "v1" for "t=...,v1=..."
+	SignatureKey string `mapstructure:"signature_key"`
+
+	// TimestampKey reads the timestamp from this key of a key=value header
+	TimestampKey string `mapstructure:"timestamp_key"`
+
+	// TimestampHeader reads the timestamp (unix seconds) from a separate header
+	TimestampHeader string `mapstructure:"timestamp_header"`
+
+	// Payload is the signed content template with {timestamp} and {body} placeholders
+	Payload string `mapstructure:"payload"`
+
+	// Algorithm is the HMAC hash: sha1, sha256 or sha512
+	Algorithm string `mapstructure:"algorithm"`
+
+	// Encoding is the signature encoding: hex or base64
+	Encoding string `mapstructure:"encoding"`
+
+	// Tolerance is the accepted timestamp age for replay protection; zero disables the check
+	Tolerance time.Duration `mapstructure:"tolerance"`
+
+	// MaxBodyBytes caps the body read for verification
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes"`
+}
+
+// WebhookPresets holds the built-in provider formats
+var WebhookPresets = map[string]WebhookConfig{
+	// GitHub: X-Hub-Signature-256: sha256=<hex>
+	"github": {
+		Header:    "X-Hub-Signature-256",
+		Prefix:    "sha256=",
+		Algorithm: "sha256",
+		Encoding:  "hex",
+	},
+	// Stripe: Stripe-Signature: t=<unix>,v1=<hex>[,v1=<hex>] over "<t>.<body>"
+	"stripe": {
+		Header:       "Stripe-Signature",
+		SignatureKey: "v1",
+		TimestampKey: "t",
+		Payload:      "{timestamp}.{body}",
+		Algorithm:    "sha256",
+		Encoding:     "hex",
+		Tolerance:    5 * time.Minute,
+	},
+	// Slack: X-Slack-Signature: v0=<hex> over "v0:<ts>:<body>"
+	"slack": {
+		Header:          "X-Slack-Signature",
+		Prefix:          "v0=",
+		TimestampHeader: "X-Slack-Request-Timestamp",
+		Payload:         "v0:{timestamp}:{body}",
+		Algorithm:       "sha256",
+		Encoding:        "hex",
+		Tolerance:       5 * time.Minute,
+	},
+}
+
+// minWebhookSecretBytes rejects empty secrets, e.g. from an unset environment
+// variable, and secrets short enough to guess
+const minWebhookSecretBytes = 16
+
+// resolve fills unset fields from the preset and defaults
+func (c WebhookConfig) resolve() (WebhookConfig, error) {
+	if c.Preset != "" {
+		p, ok := WebhookPresets[strings.ToLower(c.Preset)]
+		if !ok {
+			return c, fmt.Errorf("authx: unknown webhook preset %q", c.Preset)
+		}
+		fill := func(dst *string, v string) {
+			if *dst == "" {
+				*dst = v
+			}
+		}
+		fill(&c.Header, p.Header)
+		fill(&c.Prefix, p.Prefix)
+		fill(&c.SignatureKey, p.SignatureKey)
+		fill(&c.TimestampKey, p.TimestampKey)
+		fill(&c.TimestampHeader, p.TimestampHeader)
+		fill(&c.Payload, p.Payload)
+		fill(&c.Algorithm, p.Algorithm)
+		fill(&c.Encoding, p.Encoding)
+		if c.Tolerance == 0 {
+			c.Tolerance = p.Tolerance
+		}
+	}
+	if c.Payload == "" {
+		c.Payload = "{body}"
+	}
+	if c.Algorithm == "" {
+		c.Algorithm = "sha256"
+	}
+	if c.Encoding == "" {
+		c.Encoding = "hex"
+	}
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = 1 << 20
+	}
+
+	switch {
+	case c.Header == "":
+		return c, errors.New("authx: webhook signature header is required")
+	case len(c.Secrets) == 0:
+		return c, errors.New("authx: webhook requires at least one secret")
+	case slices.ContainsFunc(c.Secrets, func(s string) bool { return len(s) < minWebhookSecretBytes }):
+		return c, fmt.Errorf("authx: webhook secrets must be at least %d bytes", minWebhookSecretBytes)
+	case c.Encoding != "hex" && c.Encoding != "base64":
+		return c, fmt.Errorf("authx: unsupported webhook signature encoding %q", c.Encoding)
+	case c.Tolerance > 0 && c.TimestampKey == "" && c.TimestampHeader == "":
+		return c, errors.New("authx: webhook tolerance requires a timestamp key or header")
+	}
+	if _, err := webhookHash(c.Algorithm); err != nil {
+		return c, err
+	}
+	return c, nil
+}
+
+func webhookHash(alg string) (func() hash.Hash, error) {
+	switch strings.ToLower(alg) {
+	case "sha1":
+		return sha1.New, nil
+	case "sha256":
+		return sha256.New, nil
+	case "sha512":
+		return sha512.New, nil
+	}
+	return nil, fmt.Errorf("authx: unsupported webhook algorithm %q", alg)
+}
+
+// errWebhookSignature is the internal verification failure
+var errWebhookSignature = errors.New("invalid webhook signature")
+
+// WebhookVerifier verifies HMAC-signed webhook requests
+type WebhookVerifier struct {
+	cfg     WebhookConfig
+	newHash func() hash.Hash
+	now     func() time.Time
+}
+
+// NewWebhookVerifier creates a verifier from configuration
+func NewWebhookVerifier(cfg WebhookConfig) (*WebhookVerifier, error) {
+	cfg, err := cfg.resolve()
+	if err != nil {
+		return nil, err
+	}
+	h, _ := webhookHash(cfg.Algorithm)
+	return &WebhookVerifier{cfg: cfg, newHash: h, now: time.Now}, nil
+}
+
+// Verify checks the signature and timestamp of a request with the given raw body
+func (v *WebhookVerifier) Verify(header http.Header, body []byte) error {
+	raw := header.Get(v.cfg.Header)
+	if raw == "" {
+		return errWebhookSignature
+	}
+
+	var sigs []string
+	var ts string
+	if v.cfg.SignatureKey != "" {
+		for _, part := range strings.Split(raw, ",") {
+			k, val, _ := strings.Cut(strings.TrimSpace(part), "=")
+			switch k {
+			case v.cfg.SignatureKey:
+				sigs = append(sigs, val)
+			case v.cfg.TimestampKey:
+				ts = val
+			}
+		}
+	} else {
+		sigs = append(sigs, strings.TrimPrefix(strings.TrimSpace(raw), v.cfg.Prefix))
+	}
+	if v.cfg.TimestampHeader != "" {
+		ts = header.Get(v.cfg.TimestampHeader)
+	}
+
+	if v.cfg.Tolerance > 0 {
+		sec, err := strconv.ParseInt(ts, 10, 64)
+		if err != nil {
+			return errWebhookSignature
+		}
+		age := v.now().Sub(time.Unix(sec, 0))
+		if age > v.cfg.Tolerance || age < -v.cfg.Tolerance {
+			return errWebhookSignature
+		}
+	}
+
+	before, after, _ := strings.Cut(strings.ReplaceAll(v.cfg.Payload, "{timestamp}", ts), "{body}")
+	for _, secret := range v.cfg.Secrets {
+		mac := hmac.New(v.newHash, []byte(secret))
+		mac.Write([]byte(before))
+		mac.Write(body)
+		mac.Write([]byte(after))
+		expected := mac.Sum(nil)
+		for _, s := range sigs {
+			if got, err := v.decode(s); err == nil && hmac.Equal(got, expected) {
+				return nil
+			}
+		}
+	}
+	return errWebhookSignature
+}
+
+func (v *WebhookVerifier) decode(sig string) ([]byte, error) {
+	if v.cfg.Encoding == "base64" {
+		return base64.StdEncoding.DecodeString(sig)
+	}
+	return hex.DecodeString(sig)
+}
+
+// Sign computes the signature header value for body, as a provider would send it.
+// It is intended for tests and for forwarding signed requests.
+func (v *WebhookVerifier) Sign(body []byte, ts time.Time) http.Header {
+	stamp := strconv.FormatInt(ts.Unix(), 10)
+	payload := strings.ReplaceAll(strings.ReplaceAll(v.cfg.Payload, "{timestamp}", stamp), "{body}", string(body))
+	mac := hmac.New(v.newHash, []byte(v.cfg.Secrets[0]))
+	mac.Write([]byte(payload))
+	sig := hex.EncodeToString(mac.Sum(nil))
+	if v.cfg.Encoding == "base64" {
+		sig = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	}
+
+	h := http.Header{}
+	if v.cfg.SignatureKey != "" {
+		value := v.cfg.SignatureKey + "=" + sig
+		if v.cfg.TimestampKey != "" {
+			value = v.cfg.TimestampKey + "=" + stamp + "," + value
+		}
+		h.Set(v.cfg.Header, value)
+	} else {
+		h.Set(v.cfg.Header, v.cfg.Prefix+sig)
+	}
+	if v.cfg.TimestampHeader != "" {
+		h.Set(v.cfg.TimestampHeader, stamp)
+	}
+	return h
+}
+
+// WebhookMiddleware verifies HMAC-signed webhook requests over the raw body.
+// The body is restored afterwards so handlers can still read it. Requests with
+// a missing, invalid or stale signature get a 401 error envelope.
+func WebhookMiddleware(cfg WebhookConfig) (gin.HandlerFunc, error) {
+	v, err := NewWebhookVerifier(cfg)
+	if err != nil {
+		return nil, err
+	}
+	limit := v.cfg.MaxBodyBytes
+
+	return func(c *gin.Context) {
+		var body []byte
+		if c.Request.Body != nil {
+			data, err := io.ReadAll(io.LimitReader(c.Request.Body, limit+1))
+			if err != nil {
+				responsex.Error(c, http.StatusBadRequest, "invalid_body", "request body could not be read", nil)
+				c.Abort()
+				return
+			}
+			if int64(len(data)) > limit {
+				responsex.Error(c, http.StatusRequestEntityTooLarge, "payload_too_large", "request body too large", nil)
+				c.Abort()
+				return
+			}
+			body = data
+		}
+
+		if err := v.Verify(c.Request.Header, body); err != nil {
+			Unauthorized(c, err.Error())
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Next()
+	}, nil
+}
@@ -0,0 +1,156 @@
+package authx
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func hmacHex(secret, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestWebhookVerifierPresets(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	now := time.Now()
+	stamp := strconv.FormatInt(now.Unix(), 10)
+
+	t.Run("github", func(t *testing.T) {
+		v, err := NewWebhookVerifier(WebhookConfig{Preset: "github", Secrets: []string{"gh-webhook-secret-1"}})
+		require.NoError(t, err)
+
+		h := http.Header{}
+		h.Set("X-Hub-Signature-256", "sha256="+hmacHex("gh-webhook-secret-1", string(body)))
+		assert.NoError(t, v.Verify(h, body))
+		assert.Error(t, v.Verify(h, []byte(`{"action":"closed"}`)))
+	})
+
+	t.Run("stripe", func(t *testing.T) {
+		v, err := NewWebhookVerifier(WebhookConfig{Preset: "stripe", Secrets: []string{"whsec_test_0123456789"}})
+		require.NoError(t, err)
+
+		h := http.Header{}
+		sig := hmacHex("whsec_test_0123456789", stamp+"."+string(body))
+		h.Set("Stripe-Signature", "t="+stamp+",v1=deadbeef,v1="+sig+",v0=ignored")
+		assert.NoError(t, v.Verify(h, body))
+
+		old := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+		h.Set("Stripe-Signature", "t="+old+",v1="+hmacHex("whsec_test_0123456789", old+"."+string(body)))
+		assert.Error(t, v.Verify(h, body), "outside tolerance")
+	})
+
+	t.Run("slack", func(t *testing.T) {
+		v, err := NewWebhookVerifier(WebhookConfig{Preset: "slack", Secrets: []string{"slack-signing-secret"}})
+		require.NoError(t, err)
+
+		h := http.Header{}
+		h.Set("X-Slack-Request-Timestamp", stamp)
+		h.Set("X-Slack-Signature", "v0="+hmacHex("slack-signing-secret", "v0:"+stamp+":"+string(body)))
+		assert.NoError(t, v.Verify(h, body))
+
+		h.Del("X-Slack-Request-Timestamp")
+		assert.Error(t, v.Verify(h, body), "missing timestamp")
+	})
+
+	t.Run("accepts every active secret during rotation", func(t *testing.T) {
+		v, err := NewWebhookVerifier(WebhookConfig{Preset: "github", Secrets: []string{"new-webhook-secret", "old-webhook-secret"}})
+		require.NoError(t, err)
+
+		for _, secret := range []string{"new-webhook-secret", "old-webhook-secret"} {
+			h := http.Header{}
+			h.Set("X-Hub-Signature-256", "sha256="+hmacHex(secret, string(body)))
+			assert.NoError(t, v.Verify(h, body), secret)
+		}
+		h := http.Header{}
+		h.Set("X-Hub-Signature-256", "sha256="+hmacHex("retired", string(body)))
+		assert.Error(t, v.Verify(h, body))
+	})
+
+	t.Run("custom base64 format round-trips through Sign", func(t *testing.T) {
+		v, err := NewWebhookVerifier(WebhookConfig{
+			Header:          "X-Signature",
+			TimestampHeader: "X-Timestamp",
+			Payload:         "{timestamp}:{body}",
+			Algorithm:       "sha512",
+			Encoding:        "base64",
+			Tolerance:       time.Minute,
+			Secrets:         []string{"custom-webhook-secret"},
+		})
+		require.NoError(t, err)
+		assert.NoError(t, v.Verify(v.Sign(body, now), body))
+		assert.Error(t, v.Verify(v.Sign(body, now.Add(-2*time.Minute)), body))
+	})
+}
+
+func TestWebhookConfigErrors(t *testing.T) {
+	for name, cfg := range map[string]WebhookConfig{
+		"unknown preset":       {Preset: "acme", Secrets: []string{"valid-webhook-secret"}},
+		"missing header":       {Secrets: []string{"valid-webhook-secret"}},
+		"missing secrets":      {Preset: "github"},
+		"empty secret":         {Preset: "github", Secrets: []string{""}},
+		"short secret":         {Preset: "github", Secrets: []string{"valid-webhook-secret", "short"}},
+		"unknown algorithm":    {Preset: "github", Algorithm: "md5", Secrets: []string{"valid-webhook-secret"}},
+		"unknown encoding":     {Preset: "github", Encoding: "base32", Secrets: []string{"valid-webhook-secret"}},
+		"tolerance without ts": {Preset: "github", Tolerance: time.Minute, Secrets: []string{"valid-webhook-secret"}},
+	} {
+		_, err := WebhookMiddleware(cfg)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestWebhookMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mw, err := WebhookMiddleware(WebhookConfig{Preset: "github", Secrets: []string{"gh-webhook-secret-1"}, MaxBodyBytes: 64})
+	require.NoError(t, err)
+
+	engine := gin.New()
+	engine.POST("/hooks/github", mw, func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		c.String(http.StatusOK, string(data))
+	})
+
+	do := func(body, sig string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/hooks/github", strings.NewReader(body))
+		if sig != "" {
+			req.Header.Set("X-Hub-Signature-256", sig)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("keeps the body readable for the handler", func(t *testing.T) {
+		body := `{"zen":"keep it simple"}`
+		w := do(body, "sha256="+hmacHex("gh-webhook-secret-1", body))
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, body, w.Body.String())
+	})
+
+	t.Run("rejects missing and invalid signatures", func(t *testing.T) {
+		for _, sig := range []string{"", "sha256=00", "sha256=" + hmacHex("other", "{}")} {
+			w := do("{}", sig)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Contains(t, w.Body.String(), `"code":"unauthorized"`)
+		}
+	})
+
+	t.Run("rejects bodies over the limit", func(t *testing.T) {
+		body := strings.Repeat("x", 65)
+		w := do(body, "sha256="+hmacHex("gh-webhook-secret-1", body))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	})
+}
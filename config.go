@@ -1,9 +1,14 @@
 package httpx
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/gostratum/core/configx"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/gostratum/httpx/requestidx"
+	"github.com/gostratum/httpx/responsex"
 )
 
 // Config contains configuration for the HTTP server module
@@ -14,11 +19,64 @@ type Config struct {
 	// BasePath is the base path for all routes (e.g., "/api/v1")
 	BasePath string `mapstructure:"base_path"`
 
+	// ClientIPConfig controls trusted proxies and client IP resolution
+	ClientIPConfig `mapstructure:",squash"`
+
 	// Health contains health check endpoint configuration
 	Health HealthConfig `mapstructure:"health"`
 
 	// Request contains request-specific configuration
 	Request RequestConfig `mapstructure:"request"`
+
+	// Recovery contains panic recovery configuration
+	Recovery RecoveryConfig `mapstructure:"recovery"`
+
+	// IPFilter contains client IP allow and deny lists
+	IPFilter IPFilterConfig `mapstructure:"ip_filter"`
+
+	// Concurrency contains adaptive concurrency limiting configuration
+	Concurrency ConcurrencyConfig `mapstructure:"concurrency"`
+
+	// Compression contains response compression configuration
+	Compression CompressionConfig `mapstructure:"compression"`
+
+	// SecurityHeaders contains security response header configuration
+	SecurityHeaders SecurityHeadersConfig `mapstructure:"security_headers"`
+
+	// ETag contains automatic ETag configuration
+	ETag ETagConfig `mapstructure:"etag"`
+
+	// Cache contains server-side response cache configuration
+	Cache CacheConfig `mapstructure:"cache"`
+
+	// Coalesce contains concurrent identical request coalescing configuration
+	Coalesce CoalesceConfig `mapstructure:"coalesce"`
+
+	// Idempotency contains Idempotency-Key handling configuration
+	Idempotency IdempotencyConfig `mapstructure:"idempotency"`
+
+	// Auth contains authentication and route authorization configuration
+	Auth AuthConfig `mapstructure:"auth"`
+}
+
+// ETagConfig contains automatic ETag configuration for responsex envelopes
+type ETagConfig struct {
+	// Enabled turns on automatic ETags and 304 responses
+	Enabled bool `mapstructure:"enabled"`
+
+	// Weak emits weak validators, recommended together with response compression
+	Weak bool `mapstructure:"weak"`
+}
+
+// mode returns the responsex ETag mode for the configuration
+func (c ETagConfig) mode() responsex.ETagMode {
+	switch {
+	case !c.Enabled:
+		return responsex.ETagNone
+	case c.Weak:
+		return responsex.ETagWeak
+	}
+	return responsex.ETagStrong
 }
 
 // Prefix enables configx.Bind
@@ -35,20 +93,60 @@ type HealthConfig struct {
 	// InfoPath is the path for info endpoint (default: /actuator/info)
 	InfoPath string `mapstructure:"info_path" default:"/actuator/info"`
 
+	// RoutesPath is the path for the route authorization listing; empty disables it
+	RoutesPath string `mapstructure:"routes_path"`
+
 	// Timeout is the maximum duration for health checks
 	Timeout time.Duration `mapstructure:"timeout" default:"300ms"`
 }
 
 // RequestConfig contains request-specific configuration
 type RequestConfig struct {
+	// ID contains request ID header, generator and validation configuration
+	ID requestidx.Config `mapstructure:"id"`
+
 	// Logging contains request logging configuration
 	Logging LoggingConfig `mapstructure:"logging"`
+
+	// Timeout contains request deadline configuration
+	Timeout TimeoutConfig `mapstructure:"timeout"`
+
+	// Slow contains slow request detection configuration
+	Slow SlowRequestConfig `mapstructure:"slow"`
+
+	// BodyLimitConfig contains request body size limits (max_body_bytes, body_limits)
+	BodyLimitConfig `mapstructure:",squash"`
+
+	// Decompression contains request body decompression configuration
+	Decompression DecompressionConfig `mapstructure:"decompression"`
 }
 
 // LoggingConfig contains request logging configuration
 type LoggingConfig struct {
-	// DisabledURLs are URL patterns to skip in request logging
+	// DisabledURLs are URL patterns to skip in request logging, metrics or tracing (by target)
 	DisabledURLs []DisabledURL `mapstructure:"disabled_urls"`
+
+	// Sampling logs only a fraction of successful requests on matching routes
+	Sampling []LogSampling `mapstructure:"sampling"`
+
+	// MaxPerSecond caps access log lines per second; excess lines are dropped and counted
+	MaxPerSecond int `mapstructure:"max_per_second"`
+
+	// Fields selects optional access log fields: ip, user_agent, query, referer,
+	// request_bytes, response_bytes, raw_path, proto, tls and sub
+	Fields []string `mapstructure:"fields" default:"[\"ip\",\"sub\"]"`
+
+	// StatusLevels logs 4xx responses at warn and 5xx responses at error
+	StatusLevels bool `mapstructure:"status_levels"`
+
+	// Format is structured (default), combined or common (Apache log formats)
+	Format string `mapstructure:"format" default:"structured"`
+
+	// Output writes access logs to stdout, stderr or a file instead of the application logger
+	Output string `mapstructure:"output"`
+
+	// Capture adds redacted request and response payloads to structured access log entries
+	Capture BodyCaptureConfig `mapstructure:"capture"`
 }
 
 // NewConfig creates a new Config from the configuration loader
@@ -57,16 +155,89 @@ func NewConfig(loader configx.Loader) (Config, error) {
 	if err := loader.Bind(&cfg); err != nil {
 		return cfg, err
 	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, err
+	}
 	return cfg, nil
 }
 
+// Validate checks settings that struct tags cannot express, such as route patterns
+func (c Config) Validate() error {
+	if c.Concurrency.Enabled {
+		if _, err := ConcurrencyLimitMiddleware(c.Concurrency, nil); err != nil {
+			return err
+		}
+	}
+	if _, err := requestidx.New(c.Request.ID); err != nil {
+		return err
+	}
+	if _, err := NewSkippers(c); err != nil {
+		return err
+	}
+	if err := c.Request.Logging.validate(); err != nil {
+		return err
+	}
+	if err := c.ClientIPConfig.validate(); err != nil {
+		return err
+	}
+	if _, err := compileIPRules(c.IPFilter); err != nil {
+		return err
+	}
+	if _, err := TimeoutMiddleware(c.Request.Timeout); err != nil {
+		return err
+	}
+	if _, err := SlowRequestMiddleware(logx.NewNoopLogger(), c.Request.Slow, nil); err != nil {
+		return err
+	}
+	if _, err := BodyLimitMiddleware(c.Request.BodyLimitConfig, nil); err != nil {
+		return err
+	}
+	if _, err := SecurityHeadersMiddleware(c.SecurityHeaders); err != nil {
+		return err
+	}
+	if _, err := authx.NewStaticAPIKeyStore(c.Auth.APIKey.Keys); err != nil {
+		return err
+	}
+	if _, err := NewResponseCache(c.Cache, nil, nil); err != nil {
+		return err
+	}
+	if _, err := NewCoalescer(c.Coalesce, nil); err != nil {
+		return err
+	}
+	for name, wh := range c.Auth.Webhooks {
+		if _, err := authx.WebhookMiddleware(wh); err != nil {
+			return fmt.Errorf("webhook %q: %w", name, err)
+		}
+	}
+	if _, err := NewAuthorizer(logx.NewNoopLogger(), c.Auth.Policies); err != nil {
+		return err
+	}
+	return nil
+}
+
 // ConfigSummary returns a compact diagnostic map for HTTP configuration
 func (c Config) ConfigSummary() map[string]any {
 	return map[string]any{
-		"addr":           c.Addr,
-		"base_path":      c.BasePath,
-		"readiness_path": c.Health.ReadinessPath,
-		"liveness_path":  c.Health.LivenessPath,
-		"health_timeout": c.Health.Timeout,
+		"addr":            c.Addr,
+		"base_path":       c.BasePath,
+		"trusted_proxies": len(c.TrustedProxies),
+		"readiness_path":  c.Health.ReadinessPath,
+		"liveness_path":   c.Health.LivenessPath,
+		"health_timeout":  c.Health.Timeout,
+		"concurrency":     c.Concurrency.Enabled,
+		"timeout":         c.Request.Timeout.Default,
+		"slow_threshold":  c.Request.Slow.Threshold,
+		"stack_depth":     c.Recovery.StackDepth,
+		"max_body_bytes":  c.Request.MaxBodyBytes,
+		"compression":     c.Compression.Enabled,
+		"security":        c.SecurityHeaders.Enabled,
+		"idempotency":     c.Idempotency.Enabled,
+		"etag":            c.ETag.Enabled,
+		"cache":           c.Cache.Enabled,
+		"coalesce":        c.Coalesce.Enabled,
+		"body_capture":    c.Request.Logging.Capture.Enabled,
+		"jwt_auth":        c.Auth.JWT.Enabled(),
+		"api_keys":        len(c.Auth.APIKey.Keys),
+		"auth_policies":   len(c.Auth.Policies),
 	}
 }
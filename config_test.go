@@ -1,6 +1,7 @@
 package httpx
 
 import (
+	"strings"
 	"testing"
 	"time"
 
@@ -21,6 +22,66 @@ func TestNewConfig(t *testing.T) {
 		assert.Equal(t, "/livez", cfg.Health.LivenessPath)
 		assert.Equal(t, "/actuator/info", cfg.Health.InfoPath)
 		assert.Equal(t, 300*time.Millisecond, cfg.Health.Timeout)
+		assert.False(t, cfg.Concurrency.Enabled)
+		assert.Equal(t, "aimd", cfg.Concurrency.Algorithm)
+		assert.Empty(t, cfg.Concurrency.PriorityHeader, "the unauthenticated priority header is opt-in")
+		assert.Equal(t, []string{"POST", "PATCH"}, cfg.Idempotency.Methods)
+		assert.Equal(t, 24*time.Hour, cfg.Idempotency.TTL)
+		assert.Empty(t, cfg.TrustedProxies)
+		assert.Equal(t, []string{"X-Forwarded-For", "X-Real-IP"}, cfg.RemoteIPHeaders)
+		assert.Equal(t, "X-Request-ID", cfg.Request.ID.Header)
+		assert.Equal(t, "uuidv4", cfg.Request.ID.Generator)
+		assert.False(t, cfg.Request.ID.IgnoreIncoming)
+		assert.Equal(t, []string{"ip", "sub"}, cfg.Request.Logging.Fields)
+		assert.Equal(t, "structured", cfg.Request.Logging.Format)
+	})
+
+	t.Run("binds per-route settings from yaml", func(t *testing.T) {
+		loader, err := configx.NewWithReader(strings.NewReader(`
+http:
+  trusted_proxies: ["10.0.0.0/8", "192.168.1.1"]
+  trusted_platform: CF-Connecting-IP
+  request:
+    max_body_bytes: 1048576
+    body_limits:
+      - method: POST
+        urlPattern: "^/upload$"
+        max_body_bytes: 10485760
+    timeout:
+      default: 5s
+      routes:
+        - urlPattern: "^/reports"
+          timeout: 30s
+`))
+		require.NoError(t, err)
+
+		cfg, err := NewConfig(loader)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{"10.0.0.0/8", "192.168.1.1"}, cfg.TrustedProxies)
+		assert.Equal(t, "CF-Connecting-IP", cfg.TrustedPlatform)
+		assert.Equal(t, int64(1048576), cfg.Request.MaxBodyBytes)
+		require.Len(t, cfg.Request.BodyLimits, 1)
+		assert.Equal(t, "POST", cfg.Request.BodyLimits[0].Method)
+		assert.Equal(t, "^/upload$", cfg.Request.BodyLimits[0].URLPattern)
+		assert.Equal(t, int64(10485760), cfg.Request.BodyLimits[0].MaxBodyBytes)
+		assert.Equal(t, 5*time.Second, cfg.Request.Timeout.Default)
+		require.Len(t, cfg.Request.Timeout.Routes, 1)
+		assert.Equal(t, 30*time.Second, cfg.Request.Timeout.Routes[0].Timeout)
+	})
+
+	t.Run("rejects invalid route patterns", func(t *testing.T) {
+		loader, err := configx.NewWithReader(strings.NewReader(`
+http:
+  request:
+    body_limits:
+      - urlPattern: "(["
+        max_body_bytes: 10
+`))
+		require.NoError(t, err)
+
+		_, err = NewConfig(loader)
+		assert.Error(t, err)
 	})
 
 	t.Run("Prefix returns 'http'", func(t *testing.T) {
@@ -3,13 +3,16 @@ module github.com/gostratum/httpx
 go 1.25.1
 
 require (
+	github.com/andybalholm/brotli v1.2.6
 	github.com/gin-gonic/gin v1.11.0
 	github.com/google/uuid v1.6.0
 	github.com/gostratum/core v0.2.2
 	github.com/gostratum/metricsx v0.2.1
 	github.com/gostratum/tracingx v0.2.1
+	github.com/klauspost/compress v1.20.1
 	github.com/stretchr/testify v1.11.1
 	go.uber.org/fx v1.24.0
+	go.uber.org/zap v1.27.0
 )
 
 require (
@@ -69,7 +72,6 @@ require (
 	go.uber.org/dig v1.19.0 // indirect
 	go.uber.org/mock v0.5.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
-	go.uber.org/zap v1.27.0 // indirect
 	go.yaml.in/yaml/v2 v2.4.2 // indirect
 	go.yaml.in/yaml/v3 v3.0.4 // indirect
 	golang.org/x/arch v0.20.0 // indirect
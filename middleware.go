@@ -2,83 +2,39 @@ package httpx
 
 import (
 	"context"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/requestidx"
 )
 
 // ctxKey is used as a key for context values
 type ctxKey string
 
-const requestIDKey ctxKey = "rid"
-
-// RequestIDMiddleware adds X-Request-ID header and context value to requests
+// RequestIDMiddleware assigns a request ID with the default settings: the
+// X-Request-ID header, UUIDv4 IDs and incoming IDs of up to 128 characters.
+// The engine uses the http.request.id configuration instead.
 func RequestIDMiddleware() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Get request ID from header or generate a new one
-		id := c.Request.Header.Get("X-Request-ID")
-		if id == "" {
-			id = uuid.NewString()
-		}
-
-		// Add request ID to context and response header
-		ctx := context.WithValue(c.Request.Context(), requestIDKey, id)
-		c.Request = c.Request.WithContext(ctx)
-		c.Writer.Header().Set("X-Request-ID", id)
+	return requestidx.Default().Middleware()
+}
 
-		c.Next()
-	}
+// RequestIDFromContext returns the request ID assigned by RequestIDMiddleware
+// or responsex.MetaMiddleware, or "" when there is none
+func RequestIDFromContext(ctx context.Context) string {
+	return requestidx.FromContext(ctx)
 }
 
 // LoggingMiddleware logs HTTP requests using Zap logger with configurable skipping
+// and the default access log fields. The engine uses AccessLogMiddleware with
+// the http.request.logging configuration instead.
 func LoggingMiddleware(log logx.Logger, skip func(method, path string) bool) gin.HandlerFunc {
-	return func(c *gin.Context) {
-		// Skip logging if configured
-		if skip != nil && skip(c.Request.Method, c.FullPath()) {
-			c.Next()
-			return
-		}
-
-		start := time.Now()
-		c.Next()
-
-		// Extract request ID from response header (set by RequestIDMiddleware)
-		requestID := c.Writer.Header().Get("X-Request-ID")
-
-		// Log the request
-		log.Info("http",
-			logx.String("rid", requestID),
-			logx.String("method", c.Request.Method),
-			logx.String("path", c.FullPath()),
-			logx.Int("status", c.Writer.Status()),
-			logx.Duration("dur", time.Since(start)),
-		)
-	}
+	mw, _ := AccessLogMiddleware(log, LoggingConfig{}, skip, nil)
+	return mw
 }
 
-// RecoveryMiddleware handles panics and converts them to 500 errors
+// RecoveryMiddleware handles panics and converts them to 500 error envelopes,
+// logging the default stack depth. The engine uses PanicRecoveryMiddleware
+// with the http.recovery configuration and metrics instead.
 func RecoveryMiddleware(log logx.Logger) gin.HandlerFunc {
-	return gin.CustomRecovery(func(c *gin.Context, err any) {
-		// Prefer request ID from request context (set by RequestIDMiddleware),
-		// fall back to the response header if not present.
-		var requestID string
-		if v := c.Request.Context().Value(requestIDKey); v != nil {
-			if s, ok := v.(string); ok {
-				requestID = s
-			}
-		}
-		if requestID == "" {
-			requestID = c.Writer.Header().Get("X-Request-ID")
-		}
-
-		log.Error("http panic recovered",
-			logx.String("rid", requestID),
-			logx.String("method", c.Request.Method),
-			logx.String("path", c.FullPath()),
-			logx.Any("error", err),
-		)
-		c.AbortWithStatus(500)
-	})
+	return PanicRecoveryMiddleware(log, RecoveryConfig{StackDepth: defaultStackDepth}, nil)
 }
@@ -0,0 +1,327 @@
+package httpx
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/gostratum/metricsx"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Access log formats
+const (
+	// AccessLogStructured logs one structured entry with a field per value
+	AccessLogStructured = "structured"
+
+	// AccessLogCombined logs Apache combined log format lines
+	AccessLogCombined = "combined"
+
+	// AccessLogCommon logs Apache common log format lines
+	AccessLogCommon = "common"
+)
+
+// LogSampling logs a fraction of the successful (status < 400) requests to
+// matching routes. Errors and slow requests are always logged.
+type LogSampling struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// Rate is the fraction of requests logged, between 0 and 1
+	Rate float64 `mapstructure:"rate"`
+}
+
+// logSampler is a compiled sampling rule
+type logSampler struct {
+	match routeMatcher
+	rate  float64
+}
+
+// logRateLimiter allows up to limit lines per wall-clock second
+type logRateLimiter struct {
+	limit  int
+	mu     sync.Mutex
+	second int64
+	count  int
+}
+
+func (l *logRateLimiter) allow(now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s := now.Unix(); s != l.second {
+		l.second, l.count = s, 0
+	}
+	if l.count >= l.limit {
+		return false
+	}
+	l.count++
+	return true
+}
+
+// accessLogField renders one optional structured access log field
+type accessLogField func(c *gin.Context) (logx.Field, bool)
+
+// accessLogFields are the optional fields selectable with LoggingConfig.Fields
+var accessLogFields = map[string]accessLogField{
+	"ip": func(c *gin.Context) (logx.Field, bool) {
+		return logx.String("ip", c.ClientIP()), true
+	},
+	"user_agent": func(c *gin.Context) (logx.Field, bool) {
+		return logx.String("user_agent", c.Request.UserAgent()), true
+	},
+	"query": func(c *gin.Context) (logx.Field, bool) {
+		return logx.String("query", c.Request.URL.RawQuery), c.Request.URL.RawQuery != ""
+	},
+	"referer": func(c *gin.Context) (logx.Field, bool) {
+		return logx.String("referer", c.Request.Referer()), c.Request.Referer() != ""
+	},
+	"request_bytes": func(c *gin.Context) (logx.Field, bool) {
+		return logx.Int64("request_bytes", max(c.Request.ContentLength, 0)), true
+	},
+	"response_bytes": func(c *gin.Context) (logx.Field, bool) {
+		return logx.Int("response_bytes", max(c.Writer.Size(), 0)), true
+	},
+	"raw_path": func(c *gin.Context) (logx.Field, bool) {
+		return logx.String("raw_path", c.Request.URL.Path), true
+	},
+	"proto": func(c *gin.Context) (logx.Field, bool) {
+		return logx.String("proto", c.Request.Proto), true
+	},
+	"tls": func(c *gin.Context) (logx.Field, bool) {
+		if c.Request.TLS == nil {
+			return logx.Field{}, false
+		}
+		return logx.String("tls", tls.VersionName(c.Request.TLS.Version)), true
+	},
+	"sub": func(c *gin.Context) (logx.Field, bool) {
+		p, ok := authx.PrincipalFromContext(c.Request.Context())
+		if !ok {
+			return logx.Field{}, false
+		}
+		return logx.String("sub", p.Subject), true
+	},
+}
+
+// defaultAccessLogFields are logged by LoggingMiddleware and when no fields are configured
+var defaultAccessLogFields = []string{"ip", "sub"}
+
+// validate checks field names, the format and the output
+func (c LoggingConfig) validate() error {
+	for _, f := range c.Fields {
+		if _, ok := accessLogFields[f]; !ok {
+			return fmt.Errorf("access log: unknown field %q", f)
+		}
+	}
+	switch c.Format {
+	case "", AccessLogStructured, AccessLogCombined, AccessLogCommon:
+	default:
+		return fmt.Errorf("access log: unknown format %q", c.Format)
+	}
+	if _, err := c.compileSampling(); err != nil {
+		return err
+	}
+	_, err := NewBodyCapture(c.Capture)
+	return err
+}
+
+func (c LoggingConfig) compileSampling() ([]logSampler, error) {
+	samplers := make([]logSampler, len(c.Sampling))
+	for i, s := range c.Sampling {
+		if s.Rate < 0 || s.Rate > 1 {
+			return nil, fmt.Errorf("access log: sampling rate %v for %q must be between 0 and 1", s.Rate, s.URLPattern)
+		}
+		m, err := s.compile()
+		if err != nil {
+			return nil, err
+		}
+		samplers[i] = logSampler{match: m, rate: s.Rate}
+	}
+	return samplers, nil
+}
+
+// AccessLogMiddleware logs one access log entry per request. The rid, method,
+// path (the route), status and dur fields are always present in structured
+// entries; cfg.Fields selects additional ones. With StatusLevels, 4xx responses
+// are logged at warn and 5xx at error. Text formats and a dedicated Output
+// file bypass log and write lines or JSON entries to the sink directly.
+//
+// Sampling rules thin out successful requests on busy routes, but responses
+// with status >= 400 and requests flagged by a SlowRequestMiddleware
+// registered after this one are always logged. MaxPerSecond caps all lines;
+// dropped lines are counted in http_access_log_dropped_total by reason
+// (sampled or rate_limited).
+//
+// Structured entries include the payloads captured by a BodyCapture
+// middleware registered after this one.
+func AccessLogMiddleware(log logx.Logger, cfg LoggingConfig, skip func(method, path string) bool, metrics metricsx.Metrics) (gin.HandlerFunc, error) {
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	samplers, _ := cfg.compileSampling()
+	var limiter *logRateLimiter
+	if cfg.MaxPerSecond > 0 {
+		limiter = &logRateLimiter{limit: cfg.MaxPerSecond}
+	}
+	var dropped metricsx.Counter
+	if metrics != nil {
+		dropped = metrics.Counter(
+			"http_access_log_dropped_total",
+			metricsx.WithHelp("Total number of access log lines dropped by sampling or the rate cap"),
+			metricsx.WithLabels("reason"),
+		)
+	}
+	drop := func(reason string) {
+		if dropped != nil {
+			dropped.Inc(reason)
+		}
+	}
+	fieldNames := cfg.Fields
+	if len(fieldNames) == 0 {
+		fieldNames = defaultAccessLogFields
+	}
+	fields := make([]accessLogField, 0, len(fieldNames))
+	for _, name := range fieldNames {
+		fields = append(fields, accessLogFields[name])
+	}
+
+	var sink io.Writer
+	if cfg.Output != "" {
+		w, err := openAccessLogOutput(cfg.Output)
+		if err != nil {
+			return nil, err
+		}
+		sink = w
+		if cfg.Format == "" || cfg.Format == AccessLogStructured {
+			log = logx.ProvideAdapter(zap.New(zapcore.NewCore(
+				zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig()),
+				zapcore.AddSync(w),
+				zapcore.DebugLevel,
+			)))
+			sink = nil
+		}
+	}
+	var mu sync.Mutex
+
+	return func(c *gin.Context) {
+		// Skip logging if configured
+		if skip != nil && skip(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		dur := time.Since(start)
+		if status < 400 && !c.GetBool(slowRequestKey) {
+			for _, s := range samplers {
+				if s.match.match(c.Request.Method, c.FullPath()) {
+					if rand.Float64() >= s.rate {
+						drop("sampled")
+						return
+					}
+					break
+				}
+			}
+		}
+		if limiter != nil && !limiter.allow(start) {
+			drop("rate_limited")
+			return
+		}
+
+		emit := log.Info
+		if cfg.StatusLevels {
+			switch {
+			case status >= 500:
+				emit = log.Error
+			case status >= 400:
+				emit = log.Warn
+			}
+		}
+
+		switch cfg.Format {
+		case AccessLogCombined, AccessLogCommon:
+			line := apacheLogLine(c, start, cfg.Format == AccessLogCombined)
+			if sink != nil {
+				mu.Lock()
+				_, _ = io.WriteString(sink, line+"\n")
+				mu.Unlock()
+				return
+			}
+			emit(line)
+		default:
+			entry := []logx.Field{
+				logx.String("rid", RequestIDFromContext(c.Request.Context())),
+				logx.String("method", c.Request.Method),
+				logx.String("path", c.FullPath()),
+				logx.Int("status", status),
+				logx.Duration("dur", dur),
+			}
+			for _, f := range fields {
+				if field, ok := f(c); ok {
+					entry = append(entry, field)
+				}
+			}
+			if p, ok := c.Get(bodyCaptureKey); ok {
+				entry = append(entry, p.(*capturedPayloads).fields()...)
+			}
+			emit("http", entry...)
+		}
+	}, nil
+}
+
+// openAccessLogOutput opens stdout, stderr or an append-only file
+func openAccessLogOutput(output string) (io.Writer, error) {
+	switch output {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	default:
+		f, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("access log: %w", err)
+		}
+		return f, nil
+	}
+}
+
+// apacheLogLine renders a request in Apache common or combined log format.
+// The authenticated subject is used as the remote user.
+func apacheLogLine(c *gin.Context, start time.Time, combined bool) string {
+	user := "-"
+	if p, ok := authx.PrincipalFromContext(c.Request.Context()); ok && p.Subject != "" {
+		user = p.Subject
+	}
+	size := "-"
+	if n := c.Writer.Size(); n > 0 {
+		size = strconv.Itoa(n)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s - %s [%s] %q %d %s",
+		c.ClientIP(), user, start.Format("02/Jan/2006:15:04:05 -0700"),
+		c.Request.Method+" "+c.Request.URL.RequestURI()+" "+c.Request.Proto,
+		c.Writer.Status(), size)
+	if combined {
+		fmt.Fprintf(&b, " %q %q", orDash(c.Request.Referer()), orDash(c.Request.UserAgent()))
+	}
+	return b.String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
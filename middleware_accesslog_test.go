@@ -0,0 +1,205 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(t *testing.T, log logx.Logger, cfg LoggingConfig) *gin.Engine {
+		mw, err := AccessLogMiddleware(log, cfg, nil, nil)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(RequestIDMiddleware(), mw)
+		engine.POST("/orders/:id", withPrincipal(&authx.Principal{Subject: "user-1"}), func(c *gin.Context) {
+			c.String(http.StatusCreated, "created")
+		})
+		engine.GET("/missing", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+		engine.GET("/broken", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+		return engine
+	}
+
+	send := func(engine *gin.Engine, method, target, body string) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.RemoteAddr = "192.0.2.1:4000"
+		req.Header.Set("User-Agent", "curl/8.0")
+		req.Header.Set("Referer", "https://example.com/")
+		engine.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	t.Run("logs the default fields", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		engine := newEngine(t, logx.ProvideAdapter(zap.New(core)), LoggingConfig{})
+		send(engine, http.MethodPost, "/orders/1", "{}")
+
+		require.Equal(t, 1, logs.Len())
+		fields := logs.All()[0].ContextMap()
+		assert.Equal(t, "/orders/:id", fields["path"])
+		assert.Equal(t, int64(http.StatusCreated), fields["status"])
+		assert.Equal(t, "192.0.2.1", fields["ip"])
+		assert.Equal(t, "user-1", fields["sub"])
+		assert.NotContains(t, fields, "user_agent")
+	})
+
+	t.Run("logs the selected fields", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		cfg := LoggingConfig{Fields: []string{"user_agent", "query", "referer", "request_bytes", "response_bytes", "raw_path", "proto"}}
+		engine := newEngine(t, logx.ProvideAdapter(zap.New(core)), cfg)
+		send(engine, http.MethodPost, "/orders/1?dry_run=1", "{}")
+
+		fields := logs.All()[0].ContextMap()
+		assert.Equal(t, "curl/8.0", fields["user_agent"])
+		assert.Equal(t, "dry_run=1", fields["query"])
+		assert.Equal(t, "https://example.com/", fields["referer"])
+		assert.Equal(t, int64(2), fields["request_bytes"])
+		assert.Equal(t, int64(len("created")), fields["response_bytes"])
+		assert.Equal(t, "/orders/1", fields["raw_path"])
+		assert.Equal(t, "HTTP/1.1", fields["proto"])
+		assert.NotContains(t, fields, "ip")
+	})
+
+	t.Run("maps status codes to levels", func(t *testing.T) {
+		core, logs := observer.New(zap.DebugLevel)
+		engine := newEngine(t, logx.ProvideAdapter(zap.New(core)), LoggingConfig{StatusLevels: true})
+		send(engine, http.MethodPost, "/orders/1", "")
+		send(engine, http.MethodGet, "/missing", "")
+		send(engine, http.MethodGet, "/broken", "")
+
+		levels := []zapcore.Level{}
+		for _, e := range logs.All() {
+			levels = append(levels, e.Level)
+		}
+		assert.Equal(t, []zapcore.Level{zapcore.InfoLevel, zapcore.WarnLevel, zapcore.ErrorLevel}, levels)
+	})
+
+	t.Run("writes Apache combined lines", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		engine := newEngine(t, logx.ProvideAdapter(zap.New(core)), LoggingConfig{Format: AccessLogCombined})
+		send(engine, http.MethodPost, "/orders/1?x=1", "{}")
+
+		line := logs.All()[0].Message
+		assert.Regexp(t, `^192\.0\.2\.1 - user-1 \[[^\]]+\] "POST /orders/1\?x=1 HTTP/1\.1" 201 7 "https://example\.com/" "curl/8\.0"$`, line)
+	})
+
+	t.Run("writes to a dedicated file", func(t *testing.T) {
+		dir := t.TempDir()
+		core, logs := observer.New(zap.InfoLevel)
+		app := logx.ProvideAdapter(zap.New(core))
+
+		common := filepath.Join(dir, "access.log")
+		send(newEngine(t, app, LoggingConfig{Format: AccessLogCommon, Output: common}), http.MethodGet, "/missing", "")
+		data, err := os.ReadFile(common)
+		require.NoError(t, err)
+		assert.Regexp(t, `^192\.0\.2\.1 - - \[[^\]]+\] "GET /missing HTTP/1\.1" 404 -\n$`, string(data))
+
+		structured := filepath.Join(dir, "access.json")
+		send(newEngine(t, app, LoggingConfig{Output: structured}), http.MethodGet, "/missing", "")
+		data, err = os.ReadFile(structured)
+		require.NoError(t, err)
+		var entry map[string]any
+		require.NoError(t, json.Unmarshal(data, &entry))
+		assert.Equal(t, "/missing", entry["path"])
+
+		assert.Zero(t, logs.Len(), "the application logger is bypassed")
+	})
+
+	t.Run("rejects unknown fields and formats", func(t *testing.T) {
+		_, err := AccessLogMiddleware(logx.NewNoopLogger(), LoggingConfig{Fields: []string{"password"}}, nil, nil)
+		assert.Error(t, err)
+		_, err = AccessLogMiddleware(logx.NewNoopLogger(), LoggingConfig{Format: "xml"}, nil, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestAccessLogSampling(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(t *testing.T, cfg LoggingConfig) (*gin.Engine, *observer.ObservedLogs, *recordingMetrics) {
+		core, logs := observer.New(zap.InfoLevel)
+		metrics := newRecordingMetrics()
+		mw, err := AccessLogMiddleware(logx.ProvideAdapter(zap.New(core)), cfg, nil, metrics)
+		require.NoError(t, err)
+
+		slow, err := SlowRequestMiddleware(logx.NewNoopLogger(), SlowRequestConfig{Threshold: 10 * time.Millisecond}, nil)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(mw, slow)
+		engine.GET("/feed", func(c *gin.Context) {
+			if c.Query("fail") != "" {
+				c.Status(http.StatusInternalServerError)
+				return
+			}
+			if c.Query("slow") != "" {
+				time.Sleep(15 * time.Millisecond)
+			}
+			c.Status(http.StatusOK)
+		})
+		engine.GET("/orders", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return engine, logs, metrics
+	}
+
+	get := func(engine *gin.Engine, target string) {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	t.Run("samples successful requests but keeps errors and slow requests", func(t *testing.T) {
+		engine, logs, metrics := newEngine(t, LoggingConfig{
+			Sampling: []LogSampling{{RouteMatch: RouteMatch{URLPattern: "^/feed$"}, Rate: 0}},
+		})
+
+		for range 10 {
+			get(engine, "/feed")
+		}
+		get(engine, "/feed?fail=1")
+		get(engine, "/feed?slow=1")
+		get(engine, "/orders")
+
+		assert.Equal(t, 3, logs.Len())
+		assert.Equal(t, float64(10), metrics.counter("http_access_log_dropped_total"))
+	})
+
+	t.Run("keeps roughly the configured fraction", func(t *testing.T) {
+		engine, logs, _ := newEngine(t, LoggingConfig{
+			Sampling: []LogSampling{{RouteMatch: RouteMatch{URLPattern: "^/feed$"}, Rate: 0.5}},
+		})
+		for range 1000 {
+			get(engine, "/feed")
+		}
+		assert.InDelta(t, 500, logs.Len(), 100)
+	})
+
+	t.Run("caps lines per second", func(t *testing.T) {
+		engine, logs, metrics := newEngine(t, LoggingConfig{MaxPerSecond: 5})
+		for range 20 {
+			get(engine, "/orders")
+		}
+		// The burst may straddle a second boundary
+		assert.LessOrEqual(t, logs.Len(), 10)
+		assert.Equal(t, float64(20-logs.Len()), metrics.counter("http_access_log_dropped_total"))
+	})
+
+	t.Run("rejects invalid rates", func(t *testing.T) {
+		cfg := LoggingConfig{Sampling: []LogSampling{{RouteMatch: RouteMatch{URLPattern: ".*"}, Rate: 1.5}}}
+		_, err := AccessLogMiddleware(logx.NewNoopLogger(), cfg, nil, nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,290 @@
+package httpx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/gostratum/httpx/responsex"
+)
+
+// AuthConfig contains authentication and route authorization configuration
+type AuthConfig struct {
+	authx.Config `mapstructure:",squash"`
+
+	// Policies authorizes matching routes; the first match wins
+	Policies []RoutePolicy `mapstructure:"policies"`
+}
+
+// RoutePolicy binds an authorization policy to matching routes
+type RoutePolicy struct {
+	RouteMatch   `mapstructure:",squash"`
+	authx.Policy `mapstructure:",squash"`
+}
+
+// authzRule is a compiled route policy
+type authzRule struct {
+	match  routeMatcher
+	policy *authx.CompiledPolicy
+}
+
+// Authorizer enforces route policies declared in configuration or code
+type Authorizer struct {
+	log            logx.Logger
+	rules          []authzRule
+	authenticators []authx.Authenticator
+}
+
+// NewAuthorizer creates an authorizer from route policies
+func NewAuthorizer(log logx.Logger, policies []RoutePolicy) (*Authorizer, error) {
+	a := &Authorizer{log: log}
+	for _, p := range policies {
+		if err := a.Protect(p.RouteMatch, p.Policy); err != nil {
+			return nil, err
+		}
+	}
+	return a, nil
+}
+
+// Protect adds a policy for matching routes after the configured ones.
+// It must be called before the server starts handling requests.
+func (a *Authorizer) Protect(match RouteMatch, policy authx.Policy) error {
+	m, err := match.compile()
+	if err != nil {
+		return err
+	}
+	cp, err := policy.Compile()
+	if err != nil {
+		return err
+	}
+	a.rules = append(a.rules, authzRule{match: m, policy: cp})
+	return nil
+}
+
+// Authenticate adds authenticators used to resolve the principal of requests to
+// protected routes that no earlier middleware authenticated. The first one
+// finding a credential decides. It must be called before the server starts.
+func (a *Authorizer) Authenticate(authenticators ...authx.Authenticator) {
+	a.authenticators = append(a.authenticators, authenticators...)
+}
+
+// authenticate resolves the request principal with the authenticators, or nil
+func (a *Authorizer) authenticate(c *gin.Context) (*authx.Principal, error) {
+	for _, auth := range a.authenticators {
+		p, err := auth(c)
+		if err != nil || p != nil {
+			return p, err
+		}
+	}
+	return nil, nil
+}
+
+// policyFor returns the first policy matching the route, or nil
+func (a *Authorizer) policyFor(method, path string) *authx.CompiledPolicy {
+	for _, r := range a.rules {
+		if r.match.match(method, path) {
+			return r.policy
+		}
+	}
+	return nil
+}
+
+// Middleware enforces the policy of the matched route. Requests to protected
+// routes that no earlier middleware authenticated are authenticated with the
+// configured authenticators, and the principal is stored on the request context.
+// Protected routes without a principal get 401, and principals failing the
+// policy get 403. Routes without a policy pass through.
+//
+// The module installs it on the engine, so every route registered on the
+// engine is enforced.
+func (a *Authorizer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		policy := a.policyFor(c.Request.Method, c.FullPath())
+		if policy == nil {
+			c.Next()
+			return
+		}
+
+		p, ok := authx.PrincipalFromContext(c.Request.Context())
+		if !ok {
+			var err error
+			if p, err = a.authenticate(c); err != nil {
+				a.log.Warn("authentication failed",
+					logx.String("rid", RequestIDFromContext(c.Request.Context())),
+					logx.String("method", c.Request.Method),
+					logx.String("path", c.FullPath()),
+					logx.String("reason", err.Error()),
+				)
+				authx.AuthenticationFailed(c, err)
+				return
+			}
+			if p == nil {
+				authx.Unauthorized(c, "authentication required")
+				return
+			}
+			c.Request = c.Request.WithContext(authx.WithPrincipal(c.Request.Context(), p))
+		}
+
+		if err := policy.Evaluate(p, c.Params); err != nil {
+			a.log.Warn("authorization denied",
+				logx.String("rid", RequestIDFromContext(c.Request.Context())),
+				logx.String("method", c.Request.Method),
+				logx.String("path", c.FullPath()),
+				logx.String("sub", p.Subject),
+				logx.String("policy", policy.Describe()),
+				logx.String("reason", err.Error()),
+			)
+			authx.Forbidden(c, "access denied")
+			return
+		}
+		c.Next()
+	}
+}
+
+// RouteAuthorization describes the policy protecting a route
+type RouteAuthorization struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Policy string `json:"policy,omitempty"`
+}
+
+// Routes lists the engine's routes with the policy protecting each one
+func (a *Authorizer) Routes(e *gin.Engine) []RouteAuthorization {
+	routes := e.Routes()
+	out := make([]RouteAuthorization, 0, len(routes))
+	for _, r := range routes {
+		ra := RouteAuthorization{Method: r.Method, Path: r.Path}
+		if p := a.policyFor(r.Method, r.Path); p != nil {
+			ra.Policy = p.Describe()
+		}
+		out = append(out, ra)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Path != out[j].Path {
+			return out[i].Path < out[j].Path
+		}
+		return out[i].Method < out[j].Method
+	})
+	return out
+}
+
+// registerRoutesListing registers the route listing endpoint under the base path.
+// The listing reveals the authorization layout, so it is only served when a
+// policy protects it; the authorizer must be installed on the engine.
+func registerRoutesListing(e *gin.Engine, cfg Config, log logx.Logger, a *Authorizer) {
+	if !protectedByPolicy(log, a, http.MethodGet, strings.TrimRight(cfg.BasePath, "/")+cfg.Health.RoutesPath, "route listing") {
+		return
+	}
+	e.Group(strings.TrimRight(cfg.BasePath, "/")).GET(cfg.Health.RoutesPath, func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"routes": a.Routes(e)})
+	})
+}
+
+// protectedByPolicy reports whether a policy of the engine's authorizer protects
+// the route, and warns that the named built-in endpoint is disabled otherwise
+func protectedByPolicy(log logx.Logger, a *Authorizer, method, path, endpoint string) bool {
+	if a != nil && a.policyFor(method, path) != nil {
+		return true
+	}
+	log.Warn("httpx: "+endpoint+" disabled; no policy in http.auth.policies protects it",
+		logx.String("path", path))
+	return false
+}
+
+// newConfigAuthorizer builds an authorizer for http.auth.policies that
+// authenticates with the JWT and API key settings under http.auth
+func newConfigAuthorizer(log logx.Logger, cfg Config, keyOpts ...authx.APIKeyOption) (*Authorizer, error) {
+	a, err := NewAuthorizer(log, cfg.Auth.Policies)
+	if err != nil {
+		return nil, err
+	}
+	var v *authx.JWTVerifier
+	if cfg.Auth.JWT.Enabled() {
+		if v, err = authx.NewJWTVerifier(cfg.Auth.JWT); err != nil {
+			return nil, err
+		}
+	}
+	keys, err := authx.NewStaticAPIKeyStore(cfg.Auth.APIKey.Keys)
+	if err != nil {
+		return nil, err
+	}
+	a.Authenticate(configAuthenticators(cfg.Auth.Config, v, keys, keyOpts...)...)
+	return a, nil
+}
+
+// denyProtectedRoutes fails closed when the configured authorizer cannot be
+// built: requests to routes matched by a policy get 503. A policy whose route
+// pattern does not compile matches every route.
+func denyProtectedRoutes(policies []RoutePolicy) gin.HandlerFunc {
+	matchers := make([]routeMatcher, 0, len(policies))
+	all := false
+	for _, p := range policies {
+		m, err := p.RouteMatch.compile()
+		if err != nil {
+			all = true
+			break
+		}
+		matchers = append(matchers, m)
+	}
+	return func(c *gin.Context) {
+		protected := all
+		for _, m := range matchers {
+			protected = protected || m.match(c.Request.Method, c.FullPath())
+		}
+		if protected {
+			responsex.Error(c, http.StatusServiceUnavailable, "auth_unavailable", "route authorization unavailable", nil)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// configAuthenticators returns the authenticators for the credentials configured
+// under http.auth; a nil verifier or store leaves the method out. keyOpts carry
+// the API key rate limit and last-used hooks.
+func configAuthenticators(cfg authx.Config, v *authx.JWTVerifier, keys authx.APIKeyStore, keyOpts ...authx.APIKeyOption) []authx.Authenticator {
+	var out []authx.Authenticator
+	if v != nil {
+		out = append(out, authx.JWTAuthenticator(v))
+	}
+	if keys != nil && len(cfg.APIKey.Keys) > 0 {
+		out = append(out, authx.APIKeyAuthenticator(keys, cfg.APIKey, keyOpts...))
+	}
+	return out
+}
+
+// credentialHeaders carry credentials; middleware that stores or shares
+// responses must not serve a response produced for them to other callers
+var credentialHeaders = []string{"Authorization", "Cookie", "X-API-Key"}
+
+// hasCredentials reports whether the request carries any credential header
+func hasCredentials(r *http.Request) bool {
+	for _, h := range credentialHeaders {
+		if r.Header.Get(h) != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// principalScope identifies the authenticated principal of ctx for keying
+// stored responses; ok is false when the request has not authenticated
+func principalScope(ctx context.Context) (scope string, ok bool) {
+	p, ok := authx.PrincipalFromContext(ctx)
+	if !ok {
+		return "", false
+	}
+	h := sha256.New()
+	for _, part := range []string{p.Method, p.Issuer, p.Subject} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil)), true
+}
@@ -0,0 +1,374 @@
+package httpx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/configx"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// withPrincipal authenticates every request as the given principal
+func withPrincipal(p *authx.Principal) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if p != nil {
+			c.Request = c.Request.WithContext(authx.WithPrincipal(c.Request.Context(), p))
+		}
+		c.Next()
+	}
+}
+
+func TestAuthorizerMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zap.WarnLevel)
+	a, err := NewAuthorizer(logx.ProvideAdapter(zap.New(core)), []RoutePolicy{
+		{
+			RouteMatch: RouteMatch{URLPattern: "^/tenants/:tenant/"},
+			Policy:     authx.Policy{Name: "tenant-member", Expr: "claims.tenant == params.tenant"},
+		},
+		{
+			RouteMatch: RouteMatch{Method: "DELETE", URLPattern: "^/admin/"},
+			Policy:     authx.Policy{Roles: []string{"admin"}},
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, a.Protect(RouteMatch{URLPattern: "^/reports$"}, authx.Policy{Scopes: []string{"reports:read"}}))
+
+	user := &authx.Principal{Subject: "user-1", Claims: map[string]any{"tenant": "acme"}}
+	do := func(p *authx.Principal, method, target string) *httptest.ResponseRecorder {
+		engine := gin.New()
+		engine.Use(RequestIDMiddleware(), withPrincipal(p), a.Middleware())
+		ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+		engine.GET("/tenants/:tenant/orders", ok)
+		engine.DELETE("/admin/users/:id", ok)
+		engine.GET("/reports", ok)
+		engine.GET("/public", ok)
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+		return w
+	}
+
+	t.Run("allows principals satisfying the policy", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(user, http.MethodGet, "/tenants/acme/orders").Code)
+	})
+
+	t.Run("denies with 403 and logs the request id", func(t *testing.T) {
+		w := do(user, http.MethodGet, "/tenants/other/orders")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"forbidden"`)
+
+		entries := logs.FilterMessage("authorization denied").AllUntimed()
+		require.NotEmpty(t, entries)
+		fields := entries[len(entries)-1].ContextMap()
+		assert.Equal(t, w.Header().Get("X-Request-ID"), fields["rid"])
+		assert.Equal(t, "tenant-member", fields["policy"])
+		assert.Equal(t, "user-1", fields["sub"])
+	})
+
+	t.Run("requires a principal on protected routes", func(t *testing.T) {
+		assert.Equal(t, http.StatusUnauthorized, do(nil, http.MethodGet, "/reports").Code)
+	})
+
+	t.Run("applies code-declared policies", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(user, http.MethodGet, "/reports").Code)
+		reader := &authx.Principal{Subject: "svc", Scopes: []string{"reports:read"}}
+		assert.Equal(t, http.StatusOK, do(reader, http.MethodGet, "/reports").Code)
+	})
+
+	t.Run("matches by method", func(t *testing.T) {
+		assert.Equal(t, http.StatusForbidden, do(user, http.MethodDelete, "/admin/users/1").Code)
+		admin := &authx.Principal{Subject: "root", Roles: []string{"admin"}}
+		assert.Equal(t, http.StatusOK, do(admin, http.MethodDelete, "/admin/users/1").Code)
+	})
+
+	t.Run("authenticates requests without a principal", func(t *testing.T) {
+		svc := &authx.Principal{Subject: "svc", Scopes: []string{"reports:read"}}
+		withAuth, err := NewAuthorizer(logx.NewNoopLogger(), nil)
+		require.NoError(t, err)
+		require.NoError(t, withAuth.Protect(RouteMatch{URLPattern: "^/reports$"}, authx.Policy{Scopes: []string{"reports:read"}}))
+		withAuth.Authenticate(
+			func(c *gin.Context) (*authx.Principal, error) { return nil, nil },
+			func(c *gin.Context) (*authx.Principal, error) {
+				switch c.GetHeader("X-Token") {
+				case "":
+					return nil, nil
+				case "svc":
+					return svc, nil
+				}
+				return nil, errors.New("unknown token")
+			},
+		)
+
+		engine := gin.New()
+		engine.Use(withAuth.Middleware())
+		engine.GET("/reports", func(c *gin.Context) {
+			p, _ := authx.PrincipalFromContext(c.Request.Context())
+			c.String(http.StatusOK, p.Subject)
+		})
+		get := func(token string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			req.Header.Set("X-Token", token)
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		w := get("svc")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "svc", w.Body.String())
+		assert.Equal(t, http.StatusUnauthorized, get("forged").Code)
+		assert.Equal(t, http.StatusUnauthorized, get("").Code)
+	})
+
+	t.Run("maps authentication errors to status codes", func(t *testing.T) {
+		withAuth, err := NewAuthorizer(logx.NewNoopLogger(), nil)
+		require.NoError(t, err)
+		require.NoError(t, withAuth.Protect(RouteMatch{URLPattern: "^/reports$"}, authx.Policy{}))
+		withAuth.Authenticate(func(c *gin.Context) (*authx.Principal, error) {
+			switch c.GetHeader("X-Token") {
+			case "store-down":
+				return nil, fmt.Errorf("%w: database down", authx.ErrAuthUnavailable)
+			case "throttled":
+				return nil, authx.ErrRateLimited
+			}
+			return nil, errors.New("unknown token")
+		})
+
+		engine := gin.New()
+		engine.Use(withAuth.Middleware())
+		engine.GET("/reports", func(c *gin.Context) { c.Status(http.StatusOK) })
+		get := func(token string) *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			req.Header.Set("X-Token", token)
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		w := get("store-down")
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"auth_unavailable"`)
+		w = get("throttled")
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.Equal(t, http.StatusUnauthorized, get("forged").Code)
+	})
+
+	t.Run("passes routes without a policy", func(t *testing.T) {
+		assert.Equal(t, http.StatusOK, do(nil, http.MethodGet, "/public").Code)
+	})
+
+	t.Run("rejects invalid policies", func(t *testing.T) {
+		_, err := NewAuthorizer(logx.NewNoopLogger(), []RoutePolicy{{Policy: authx.Policy{Expr: "claims.x =="}}})
+		assert.Error(t, err)
+		assert.Error(t, a.Protect(RouteMatch{URLPattern: "(["}, authx.Policy{}))
+	})
+}
+
+func TestRoutesListing(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := Config{BasePath: "/", Health: HealthConfig{RoutesPath: "/actuator/routes"}}
+	newEngine := func(t *testing.T, policies []RoutePolicy) (*gin.Engine, *observer.ObservedLogs) {
+		a, err := NewAuthorizer(logx.NewNoopLogger(), policies)
+		require.NoError(t, err)
+
+		core, logs := observer.New(zap.WarnLevel)
+		engine := gin.New()
+		engine.Use(a.Middleware())
+		ok := func(c *gin.Context) {}
+		engine.GET("/admin/users", ok)
+		engine.GET("/public", ok)
+		registerRoutesListing(engine, cfg, logx.ProvideAdapter(zap.New(core)), a)
+		return engine, logs
+	}
+
+	t.Run("lists routes with their policies for authorized callers", func(t *testing.T) {
+		engine, _ := newEngine(t, []RoutePolicy{
+			{RouteMatch: RouteMatch{URLPattern: "^/admin/"}, Policy: authx.Policy{Name: "admin-only"}},
+			{RouteMatch: RouteMatch{URLPattern: "^/actuator/"}, Policy: authx.Policy{Name: "ops", Roles: []string{"ops"}}},
+		})
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/actuator/routes", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/actuator/routes", nil)
+		req = req.WithContext(authx.WithPrincipal(req.Context(), &authx.Principal{Subject: "op", Roles: []string{"ops"}}))
+		engine.ServeHTTP(w, req)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var body struct {
+			Routes []RouteAuthorization `json:"routes"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, []RouteAuthorization{
+			{Method: "GET", Path: "/actuator/routes", Policy: "ops"},
+			{Method: "GET", Path: "/admin/users", Policy: "admin-only"},
+			{Method: "GET", Path: "/public"},
+		}, body.Routes)
+	})
+
+	t.Run("is not served without a policy protecting it", func(t *testing.T) {
+		engine, logs := newEngine(t, []RoutePolicy{
+			{RouteMatch: RouteMatch{URLPattern: "^/admin/"}, Policy: authx.Policy{Name: "admin-only"}},
+		})
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/actuator/routes", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, 1, logs.FilterMessageSnippet("route listing disabled").Len())
+	})
+}
+
+func TestEngineEnforcesConfiguredPolicies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	key := "k-123"
+	cfg := Config{Auth: AuthConfig{
+		Config: authx.Config{APIKey: authx.APIKeyConfig{
+			Header: "X-API-Key",
+			Keys:   []authx.APIKeyEntry{{ID: "ci", Hash: authx.HashAPIKey(key), Scopes: []string{"reports:read"}}},
+		}},
+		Policies: []RoutePolicy{
+			{RouteMatch: RouteMatch{URLPattern: "^/reports$"}, Policy: authx.Policy{Scopes: []string{"reports:read"}}},
+		},
+	}}
+	engine := NewEngine(logx.NewNoopLogger(), cfg, nil)
+	engine.GET("/reports", func(c *gin.Context) {
+		p, _ := authx.PrincipalFromContext(c.Request.Context())
+		c.String(http.StatusOK, p.Subject)
+	})
+
+	get := func(key string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, get("").Code)
+	assert.Equal(t, http.StatusUnauthorized, get("wrong").Code)
+	w := get(key)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "ci", w.Body.String())
+
+	t.Run("applies the API key hooks", func(t *testing.T) {
+		var used []string
+		engine := NewEngine(logx.NewNoopLogger(), cfg, nil, WithAPIKeyOptions(
+			authx.WithAPIKeyRateLimit(func(_ context.Context, _ *authx.APIKey) bool { return len(used) < 1 }),
+			authx.WithAPIKeyLastUsed(func(_ context.Context, k *authx.APIKey, _ time.Time) { used = append(used, k.ID) }),
+		))
+		engine.GET("/reports", func(c *gin.Context) { c.Status(http.StatusOK) })
+		do := func() *httptest.ResponseRecorder {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+			req.Header.Set("X-API-Key", key)
+			engine.ServeHTTP(w, req)
+			return w
+		}
+
+		assert.Equal(t, http.StatusOK, do().Code)
+		w := do()
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"rate_limited"`)
+		assert.Equal(t, []string{"ci"}, used)
+	})
+
+	t.Run("answers store failures with 503", func(t *testing.T) {
+		a, err := NewAuthorizer(logx.NewNoopLogger(), cfg.Auth.Policies)
+		require.NoError(t, err)
+		a.Authenticate(configAuthenticators(cfg.Auth.Config, nil, failingKeyStore{})...)
+		engine := NewEngine(logx.NewNoopLogger(), cfg, nil, WithAuthorizer(a))
+		engine.GET("/reports", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+		req.Header.Set("X-API-Key", key)
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"auth_unavailable"`)
+	})
+}
+
+// failingKeyStore fails every lookup like an unreachable database
+type failingKeyStore struct{}
+
+func (failingKeyStore) Lookup(context.Context, string) (*authx.APIKey, error) {
+	return nil, errors.New("database down")
+}
+
+func TestEngineFailsClosedOnInvalidAuthConfig(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(policies ...RoutePolicy) *gin.Engine {
+		engine := NewEngine(logx.NewNoopLogger(), Config{Auth: AuthConfig{Policies: policies}}, nil)
+		engine.GET("/reports", func(c *gin.Context) { c.Status(http.StatusOK) })
+		engine.GET("/public", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return engine
+	}
+	get := func(engine *gin.Engine, target string) int {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w.Code
+	}
+
+	t.Run("rejects protected routes when a policy is invalid", func(t *testing.T) {
+		engine := newEngine(RoutePolicy{RouteMatch: RouteMatch{URLPattern: "^/reports$"}, Policy: authx.Policy{Expr: "claims.tenant =="}})
+		assert.Equal(t, http.StatusServiceUnavailable, get(engine, "/reports"))
+		assert.Equal(t, http.StatusOK, get(engine, "/public"))
+	})
+
+	t.Run("rejects every route when a route pattern is invalid", func(t *testing.T) {
+		engine := newEngine(RoutePolicy{RouteMatch: RouteMatch{URLPattern: "("}, Policy: authx.Policy{Scopes: []string{"reports:read"}}})
+		assert.Equal(t, http.StatusServiceUnavailable, get(engine, "/reports"))
+		assert.Equal(t, http.StatusServiceUnavailable, get(engine, "/public"))
+	})
+}
+
+func TestAuthConfigBinding(t *testing.T) {
+	loader, err := configx.NewWithReader(strings.NewReader(`
+http:
+  auth:
+    jwt:
+      issuer: "https://issuer.example.com"
+    policies:
+      - method: GET
+        urlPattern: "^/tenants/"
+        name: tenant-member
+        scopes: ["orders:read"]
+        expr: "claims.tenant == params.tenant"
+`))
+	require.NoError(t, err)
+
+	cfg, err := NewConfig(loader)
+	require.NoError(t, err)
+
+	assert.Equal(t, "https://issuer.example.com", cfg.Auth.JWT.Issuer)
+	require.Len(t, cfg.Auth.Policies, 1)
+	p := cfg.Auth.Policies[0]
+	assert.Equal(t, "GET", p.Method)
+	assert.Equal(t, "^/tenants/", p.URLPattern)
+	assert.Equal(t, "tenant-member", p.Name)
+	assert.Equal(t, []string{"orders:read"}, p.Scopes)
+	assert.Equal(t, "claims.tenant == params.tenant", p.Expr)
+}
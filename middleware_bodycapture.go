@@ -0,0 +1,321 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+)
+
+// bodyCaptureKey is the gin context key holding captured payloads for the access log
+const bodyCaptureKey = "httpx.body_capture"
+
+// redacted replaces sensitive values in captured payloads and headers
+const redacted = "[REDACTED]"
+
+// BodyCaptureConfig contains request and response body capture configuration
+type BodyCaptureConfig struct {
+	// Enabled installs body capture; bodies are captured on Routes or while the debug switch is on
+	Enabled bool `mapstructure:"enabled"`
+
+	// Routes lists the routes whose payloads are always captured
+	Routes []RouteMatch `mapstructure:"routes"`
+
+	// MaxBytes bounds the captured part of each body
+	MaxBytes int `mapstructure:"max_bytes" default:"4096"`
+
+	// ContentTypes lists the media types that are captured; other bodies are omitted
+	ContentTypes []string `mapstructure:"content_types" default:"[\"application/json\",\"application/x-www-form-urlencoded\",\"text/plain\"]"`
+
+	// RedactFields are field names redacted at any depth (e.g. password) or
+	// dotted paths from the document root (e.g. card.number or $.card.number)
+	RedactFields []string `mapstructure:"redact_fields" default:"[\"password\",\"token\",\"secret\",\"access_token\",\"refresh_token\"]"`
+
+	// RedactHeaders are header names whose values are redacted
+	RedactHeaders []string `mapstructure:"redact_headers" default:"[\"Authorization\",\"Cookie\",\"Set-Cookie\",\"X-API-Key\"]"`
+
+	// DebugPath registers a POST endpoint toggling capture for all routes; empty disables it
+	DebugPath string `mapstructure:"debug_path"`
+}
+
+// capturedPayloads holds the redacted payloads logged with the access log entry
+type capturedPayloads struct {
+	reqHeaders  map[string]string
+	reqBody     string
+	respHeaders map[string]string
+	respBody    string
+}
+
+// fields renders the payloads as access log fields
+func (p *capturedPayloads) fields() []logx.Field {
+	fields := []logx.Field{
+		logx.Any("req_headers", p.reqHeaders),
+		logx.Any("resp_headers", p.respHeaders),
+	}
+	if p.reqBody != "" {
+		fields = append(fields, logx.String("req_body", p.reqBody))
+	}
+	if p.respBody != "" {
+		fields = append(fields, logx.String("resp_body", p.respBody))
+	}
+	return fields
+}
+
+// BodyCapture captures redacted request and response payloads for the access
+// log on configured routes, or on every route while the debug switch is on.
+type BodyCapture struct {
+	cfg          BodyCaptureConfig
+	routes       []routeMatcher
+	fieldNames   map[string]bool
+	fieldPaths   map[string]bool
+	headers      map[string]bool
+	contentTypes []string
+
+	// debugUntil is the unix nano time the debug switch expires; -1 means no expiry
+	debugUntil atomic.Int64
+}
+
+// arrayIndex strips [*] and [n] from JSONPath rules; arrays are matched element-wise
+var arrayIndex = regexp.MustCompile(`\[(\*|\d+)\]`)
+
+// NewBodyCapture creates a body capture from configuration
+func NewBodyCapture(cfg BodyCaptureConfig) (*BodyCapture, error) {
+	bc := &BodyCapture{
+		cfg:        cfg,
+		fieldNames: make(map[string]bool),
+		fieldPaths: make(map[string]bool),
+		headers:    make(map[string]bool),
+	}
+	for _, r := range cfg.Routes {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		bc.routes = append(bc.routes, m)
+	}
+	for _, f := range cfg.RedactFields {
+		f = arrayIndex.ReplaceAllString(strings.TrimPrefix(strings.TrimPrefix(f, "$"), "."), "")
+		if strings.Contains(f, ".") {
+			bc.fieldPaths[strings.ToLower(f)] = true
+		} else {
+			bc.fieldNames[strings.ToLower(f)] = true
+		}
+	}
+	for _, h := range cfg.RedactHeaders {
+		bc.headers[http.CanonicalHeaderKey(h)] = true
+	}
+	for _, ct := range cfg.ContentTypes {
+		bc.contentTypes = append(bc.contentTypes, strings.ToLower(ct))
+	}
+	return bc, nil
+}
+
+// EnableDebug captures payloads on every route for d, or until DisableDebug when d is 0
+func (bc *BodyCapture) EnableDebug(d time.Duration) {
+	if d <= 0 {
+		bc.debugUntil.Store(-1)
+		return
+	}
+	bc.debugUntil.Store(time.Now().Add(d).UnixNano())
+}
+
+// DisableDebug turns the debug switch off
+func (bc *BodyCapture) DisableDebug() {
+	bc.debugUntil.Store(0)
+}
+
+// Debugging reports whether the debug switch is on
+func (bc *BodyCapture) Debugging() bool {
+	until := bc.debugUntil.Load()
+	return until == -1 || (until > 0 && time.Now().UnixNano() < until)
+}
+
+func (bc *BodyCapture) captures(method, path string) bool {
+	if bc.Debugging() {
+		return true
+	}
+	for _, m := range bc.routes {
+		if m.match(method, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware captures payloads for the access log. It must run after the
+// access log middleware so the entry is written once capture has finished,
+// and after CompressionMiddleware so the response is captured before encoding.
+func (bc *BodyCapture) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !bc.captures(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+
+		p := &capturedPayloads{reqHeaders: bc.redactHeaders(c.Request.Header)}
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			contentType := c.ContentType()
+			head, _ := io.ReadAll(io.LimitReader(c.Request.Body, int64(bc.cfg.MaxBytes)+1))
+			c.Request.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(head), c.Request.Body), c.Request.Body}
+			p.reqBody = bc.renderBody(contentType, head)
+		}
+		c.Set(bodyCaptureKey, p)
+
+		original := c.Writer
+		rw := &bodyCaptureWriter{ResponseWriter: c.Writer, limit: bc.cfg.MaxBytes}
+		c.Writer = rw
+		c.Next()
+		c.Writer = original
+
+		p.respHeaders = bc.redactHeaders(rw.Header())
+		p.respBody = bc.renderBody(rw.Header().Get("Content-Type"), rw.body.Bytes())
+	}
+}
+
+// renderBody returns the redacted body, or a placeholder when it must not be logged.
+// head holds up to MaxBytes+1 bytes so truncation can be detected.
+func (bc *BodyCapture) renderBody(contentType string, head []byte) string {
+	if len(head) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if !slices.Contains(bc.contentTypes, strings.ToLower(mediaType)) {
+		return "[omitted: " + strconv.Quote(mediaType) + " not captured]"
+	}
+	truncated := len(head) > bc.cfg.MaxBytes
+	if truncated {
+		head = head[:bc.cfg.MaxBytes]
+	}
+
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		var doc any
+		if truncated || json.Unmarshal(head, &doc) != nil {
+			// Partial or invalid documents cannot be redacted reliably
+			return "[omitted: body exceeds max_bytes or is not valid JSON]"
+		}
+		out, _ := json.Marshal(bc.redactJSON(doc, ""))
+		return string(out)
+	case mediaType == "application/x-www-form-urlencoded":
+		values, err := url.ParseQuery(string(head))
+		if err != nil {
+			return "[omitted: invalid form body]"
+		}
+		for k := range values {
+			if bc.redactsField(k, k) {
+				values[k] = []string{redacted}
+			}
+		}
+		return values.Encode()
+	default:
+		if truncated {
+			return string(head) + "...[truncated]"
+		}
+		return string(head)
+	}
+}
+
+// redactsField reports whether a field with name at the dotted path is redacted
+func (bc *BodyCapture) redactsField(name, path string) bool {
+	return bc.fieldNames[strings.ToLower(name)] || bc.fieldPaths[strings.ToLower(path)]
+}
+
+// redactJSON replaces redacted fields in a decoded JSON document
+func (bc *BodyCapture) redactJSON(v any, path string) any {
+	switch t := v.(type) {
+	case map[string]any:
+		for k, child := range t {
+			childPath := k
+			if path != "" {
+				childPath = path + "." + k
+			}
+			if bc.redactsField(k, childPath) {
+				t[k] = redacted
+			} else {
+				t[k] = bc.redactJSON(child, childPath)
+			}
+		}
+	case []any:
+		for i, child := range t {
+			t[i] = bc.redactJSON(child, path)
+		}
+	}
+	return v
+}
+
+// redactHeaders flattens headers and redacts the configured ones
+func (bc *BodyCapture) redactHeaders(h http.Header) map[string]string {
+	out := make(map[string]string, len(h))
+	for k, vs := range h {
+		if bc.headers[http.CanonicalHeaderKey(k)] {
+			out[k] = redacted
+			continue
+		}
+		out[k] = strings.Join(vs, ", ")
+	}
+	return out
+}
+
+// registerBodyCaptureSwitch registers the debug switch endpoint under the base path.
+// "enabled" turns capture for all routes on or off; "duration" bounds how long it
+// stays on and must be positive. The endpoint is only registered when a policy of
+// the engine's authorizer protects it.
+func registerBodyCaptureSwitch(e *gin.Engine, cfg Config, log logx.Logger, a *Authorizer, bc *BodyCapture) {
+	if !protectedByPolicy(log, a, http.MethodPost, strings.TrimRight(cfg.BasePath, "/")+cfg.Request.Logging.Capture.DebugPath, "body capture debug switch") {
+		return
+	}
+	e.Group(strings.TrimRight(cfg.BasePath, "/")).POST(cfg.Request.Logging.Capture.DebugPath, func(c *gin.Context) {
+		enabled, err := strconv.ParseBool(c.DefaultQuery("enabled", "true"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "enabled must be a boolean"})
+			return
+		}
+		if !enabled {
+			bc.DisableDebug()
+			c.JSON(http.StatusOK, gin.H{"debug": false})
+			return
+		}
+		d, err := time.ParseDuration(c.DefaultQuery("duration", "10m"))
+		if err != nil || d <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "duration must be a positive Go duration such as 10m"})
+			return
+		}
+		bc.EnableDebug(d)
+		c.JSON(http.StatusOK, gin.H{"debug": true, "duration": d.String()})
+	})
+}
+
+// bodyCaptureWriter keeps the first limit bytes of the response and one more to detect truncation
+type bodyCaptureWriter struct {
+	gin.ResponseWriter
+	body  bytes.Buffer
+	limit int
+}
+
+func (w *bodyCaptureWriter) keep(n int) int {
+	return max(0, min(n, w.limit+1-w.body.Len()))
+}
+
+func (w *bodyCaptureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b[:w.keep(len(b))])
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *bodyCaptureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s[:w.keep(len(s))])
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,217 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestBodyCapture(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	defaults := func() BodyCaptureConfig {
+		return BodyCaptureConfig{
+			Enabled:       true,
+			Routes:        []RouteMatch{{URLPattern: "^/payments$"}},
+			MaxBytes:      4096,
+			ContentTypes:  []string{"application/json", "application/x-www-form-urlencoded", "text/plain"},
+			RedactFields:  []string{"password", "$.card.number", "items[*].secret"},
+			RedactHeaders: []string{"Authorization", "Set-Cookie"},
+		}
+	}
+
+	newEngine := func(t *testing.T, bc *BodyCapture) (*gin.Engine, *observer.ObservedLogs) {
+		core, logs := observer.New(zap.InfoLevel)
+		mw, err := AccessLogMiddleware(logx.ProvideAdapter(zap.New(core)), LoggingConfig{}, nil, nil)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(mw, bc.Middleware())
+		echo := func(c *gin.Context) {
+			body, _ := c.GetRawData()
+			c.Header("Set-Cookie", "session=abc")
+			c.Data(http.StatusOK, c.ContentType(), body)
+		}
+		engine.POST("/payments", echo)
+		engine.POST("/orders", echo)
+		return engine, logs
+	}
+
+	post := func(engine *gin.Engine, target, contentType, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("Authorization", "Bearer secret-token")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("logs redacted payloads on configured routes", func(t *testing.T) {
+		bc, err := NewBodyCapture(defaults())
+		require.NoError(t, err)
+		engine, logs := newEngine(t, bc)
+
+		body := `{"user":"ann","password":"hunter2","card":{"number":"4111","exp":"12/30"},"items":[{"secret":"s","sku":"a"}]}`
+		w := post(engine, "/payments", "application/json", body)
+		assert.JSONEq(t, body, w.Body.String(), "the handler and client see the original payloads")
+
+		fields := logs.All()[0].ContextMap()
+		var logged map[string]any
+		require.NoError(t, json.Unmarshal([]byte(fields["req_body"].(string)), &logged))
+		assert.Equal(t, "ann", logged["user"])
+		assert.Equal(t, redacted, logged["password"])
+		assert.Equal(t, map[string]any{"number": redacted, "exp": "12/30"}, logged["card"])
+		assert.Equal(t, []any{map[string]any{"secret": redacted, "sku": "a"}}, logged["items"])
+		assert.Equal(t, fields["req_body"], fields["resp_body"])
+
+		assert.Equal(t, redacted, fields["req_headers"].(map[string]string)["Authorization"])
+		assert.Equal(t, redacted, fields["resp_headers"].(map[string]string)["Set-Cookie"])
+	})
+
+	t.Run("skips other routes until the debug switch is on", func(t *testing.T) {
+		bc, err := NewBodyCapture(defaults())
+		require.NoError(t, err)
+		engine, logs := newEngine(t, bc)
+
+		post(engine, "/orders", "text/plain", "hello")
+		assert.NotContains(t, logs.All()[0].ContextMap(), "req_body")
+
+		bc.EnableDebug(time.Minute)
+		assert.True(t, bc.Debugging())
+		post(engine, "/orders", "text/plain", "hello")
+		assert.Equal(t, "hello", logs.All()[1].ContextMap()["req_body"])
+
+		bc.DisableDebug()
+		assert.False(t, bc.Debugging())
+	})
+
+	t.Run("redacts form fields", func(t *testing.T) {
+		bc, err := NewBodyCapture(defaults())
+		require.NoError(t, err)
+		engine, logs := newEngine(t, bc)
+
+		post(engine, "/payments", "application/x-www-form-urlencoded", "user=ann&password=hunter2")
+		assert.Equal(t, "password=%5BREDACTED%5D&user=ann", logs.All()[0].ContextMap()["req_body"])
+	})
+
+	t.Run("omits bodies that cannot be redacted or are not allowed", func(t *testing.T) {
+		cfg := defaults()
+		cfg.MaxBytes = 16
+		bc, err := NewBodyCapture(cfg)
+		require.NoError(t, err)
+		engine, logs := newEngine(t, bc)
+
+		body := `{"password":"hunter2","padding":"xxxxxxxx"}`
+		w := post(engine, "/payments", "application/json", body)
+		assert.Equal(t, body, w.Body.String(), "the full body still reaches the handler")
+		assert.NotContains(t, logs.All()[0].ContextMap()["req_body"], "hunter2")
+
+		post(engine, "/payments", "application/octet-stream", "binary")
+		assert.Contains(t, logs.All()[1].ContextMap()["req_body"], "not captured")
+
+		post(engine, "/payments", "text/plain", strings.Repeat("a", 20))
+		assert.Equal(t, strings.Repeat("a", 16)+"...[truncated]", logs.All()[2].ContextMap()["req_body"])
+	})
+
+	t.Run("toggles capture through the debug endpoint", func(t *testing.T) {
+		cfg := Config{}
+		cfg.Request.Logging.Capture = defaults()
+		cfg.Request.Logging.Capture.DebugPath = "/debug/body-capture"
+		bc, err := NewBodyCapture(cfg.Request.Logging.Capture)
+		require.NoError(t, err)
+
+		a, err := NewAuthorizer(logx.NewNoopLogger(), []RoutePolicy{
+			{RouteMatch: RouteMatch{URLPattern: "^/debug/"}, Policy: authx.Policy{Roles: []string{"ops"}}},
+		})
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(a.Middleware())
+		registerBodyCaptureSwitch(engine, cfg, logx.NewNoopLogger(), a, bc)
+		toggleAs := func(p *authx.Principal, query string) int {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/debug/body-capture"+query, nil)
+			if p != nil {
+				req = req.WithContext(authx.WithPrincipal(req.Context(), p))
+			}
+			engine.ServeHTTP(w, req)
+			return w.Code
+		}
+		toggle := func(query string) int {
+			return toggleAs(&authx.Principal{Subject: "op", Roles: []string{"ops"}}, query)
+		}
+
+		assert.Equal(t, http.StatusUnauthorized, toggleAs(nil, "?duration=5m"))
+		assert.False(t, bc.Debugging())
+		assert.Equal(t, http.StatusOK, toggle("?duration=5m"))
+		assert.True(t, bc.Debugging())
+		assert.Equal(t, http.StatusOK, toggle("?enabled=false"))
+		assert.False(t, bc.Debugging())
+		assert.Equal(t, http.StatusBadRequest, toggle("?duration=soon"))
+		assert.Equal(t, http.StatusBadRequest, toggle("?duration=0"), "the endpoint never enables capture without expiry")
+		assert.Equal(t, http.StatusBadRequest, toggle("?duration=-1m"))
+		assert.False(t, bc.Debugging())
+	})
+
+	t.Run("registers the debug endpoint only behind a policy", func(t *testing.T) {
+		cfg := Config{}
+		cfg.Request.Logging.Capture = defaults()
+		cfg.Request.Logging.Capture.DebugPath = "/debug/body-capture"
+		bc, err := NewBodyCapture(cfg.Request.Logging.Capture)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		registerBodyCaptureSwitch(engine, cfg, logx.NewNoopLogger(), nil, bc)
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/debug/body-capture", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("logs uncompressed payloads when responses are compressed", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		cfg := Config{}
+		cfg.Request.Logging.Capture = defaults()
+		cfg.Compression = CompressionConfig{Enabled: true}
+		engine := NewEngine(logx.ProvideAdapter(zap.New(core)), cfg, nil)
+		engine.POST("/payments", func(c *gin.Context) {
+			if c.Query("text") != "" {
+				c.String(http.StatusOK, "receipt for ann")
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"user": "ann", "password": "hunter2"})
+		})
+
+		send := func(target string) map[string]any {
+			req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(`{"user":"ann"}`))
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Accept-Encoding", "gzip")
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, req)
+			require.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			entries := logs.FilterMessage("http").All()
+			require.NotEmpty(t, entries)
+			return entries[len(entries)-1].ContextMap()
+		}
+
+		assert.JSONEq(t, `{"user":"ann","password":"[REDACTED]"}`, send("/payments")["resp_body"].(string))
+		assert.Equal(t, "receipt for ann", send("/payments?text=1")["resp_body"])
+	})
+
+	t.Run("rejects invalid route patterns", func(t *testing.T) {
+		cfg := defaults()
+		cfg.Routes = []RouteMatch{{URLPattern: "("}}
+		_, err := NewBodyCapture(cfg)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,145 @@
+package httpx
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/responsex"
+	"github.com/gostratum/metricsx"
+)
+
+// BodyLimitConfig contains request body size limits
+type BodyLimitConfig struct {
+	// MaxBodyBytes is the global request body limit in bytes; zero disables it
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes"`
+
+	// BodyLimits overrides the global limit for matching routes; the first match wins
+	BodyLimits []RouteBodyLimit `mapstructure:"body_limits"`
+}
+
+// RouteBodyLimit sets the body size limit for matching routes
+type RouteBodyLimit struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// MaxBodyBytes is the route limit in bytes; zero disables the limit for the route
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes"`
+}
+
+// enabled reports whether any body limit is configured
+func (c BodyLimitConfig) enabled() bool {
+	return c.MaxBodyBytes > 0 || len(c.BodyLimits) > 0
+}
+
+// BodyLimitMiddleware rejects request bodies larger than the configured limit with 413.
+// Requests announcing a larger Content-Length are rejected before the handler runs;
+// chunked bodies are wrapped in http.MaxBytesReader and rejected as soon as a read
+// crosses the limit, after which any further handler writes are discarded.
+// Rejections are observed in http_request_size_bytes with outcome "rejected"
+// when metrics are available.
+func BodyLimitMiddleware(cfg BodyLimitConfig, metrics metricsx.Metrics) (gin.HandlerFunc, error) {
+	routes := make([]routeMatcher, len(cfg.BodyLimits))
+	for i, r := range cfg.BodyLimits {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		routes[i] = m
+	}
+
+	sizes := rejectedSizeHistogram(metrics)
+
+	resolve := func(c *gin.Context) int64 {
+		for i, m := range routes {
+			if m.match(c.Request.Method, c.FullPath()) {
+				return cfg.BodyLimits[i].MaxBodyBytes
+			}
+		}
+		return cfg.MaxBodyBytes
+	}
+
+	return func(c *gin.Context) {
+		limit := resolve(c)
+		if limit <= 0 || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		if c.Request.ContentLength > limit {
+			rejectTooLarge(c, sizes, c.Request.ContentLength)
+			return
+		}
+
+		original := c.Writer
+		c.Request.Body = newLimitedBody(c, c.Request.Body, limit, sizes)
+
+		c.Next()
+
+		c.Writer = original
+	}, nil
+}
+
+// rejectedSizeHistogram returns the request size family for rejected bodies, or nil without metrics
+func rejectedSizeHistogram(metrics metricsx.Metrics) metricsx.Histogram {
+	if metrics == nil {
+		return nil
+	}
+	return requestSizeHistogram(metrics)
+}
+
+// rejectTooLarge answers with a 413 error envelope unless a response was already
+// written, and observes the rejected size (the bytes read up to the limit for chunked bodies)
+func rejectTooLarge(c *gin.Context, sizes metricsx.Histogram, size int64) {
+	if sizes != nil {
+		sizes.Observe(float64(size), c.Request.Method, c.FullPath(), "rejected")
+	}
+	c.Set(bodyRejectedKey, true)
+	if !c.Writer.Written() {
+		responsex.Error(c, http.StatusRequestEntityTooLarge, "payload_too_large", "request body too large", nil)
+	}
+	c.Abort()
+}
+
+// newLimitedBody wraps body in http.MaxBytesReader and rejects the request with
+// 413 on the first read that crosses the limit. Later handler writes are discarded.
+func newLimitedBody(c *gin.Context, body io.ReadCloser, limit int64, sizes metricsx.Histogram) io.ReadCloser {
+	b := &limitedBody{ReadCloser: http.MaxBytesReader(c.Writer, body, limit)}
+	b.onExceeded = func() {
+		rejectTooLarge(c, sizes, b.read)
+		c.Writer = &discardWriter{ResponseWriter: c.Writer}
+	}
+	return b
+}
+
+// limitedBody reports the first read that crosses the body limit
+type limitedBody struct {
+	io.ReadCloser
+	onExceeded func()
+	exceeded   bool
+	read       int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	var tooLarge *http.MaxBytesError
+	if err != nil && !b.exceeded && errors.As(err, &tooLarge) {
+		b.exceeded = true
+		b.onExceeded()
+	}
+	return n, err
+}
+
+// discardWriter drops handler writes once a response has been sent on its behalf
+type discardWriter struct {
+	gin.ResponseWriter
+}
+
+func (w *discardWriter) WriteHeader(int) {}
+
+func (w *discardWriter) WriteHeaderNow() {}
+
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+
+func (w *discardWriter) WriteString(s string) (int, error) { return len(s), nil }
@@ -0,0 +1,96 @@
+package httpx
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBodyLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(t *testing.T, cfg BodyLimitConfig, metrics *recordingMetrics) *gin.Engine {
+		mw, err := BodyLimitMiddleware(cfg, metrics)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(mw)
+		engine.POST("/ingest", func(c *gin.Context) {
+			var payload map[string]any
+			if err := c.ShouldBindJSON(&payload); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, payload)
+		})
+		engine.POST("/upload", func(c *gin.Context) {
+			n, _ := io.Copy(io.Discard, c.Request.Body)
+			c.JSON(http.StatusOK, gin.H{"bytes": n})
+		})
+		return engine
+	}
+
+	t.Run("rejects declared Content-Length over the limit", func(t *testing.T) {
+		metrics := newRecordingMetrics()
+		engine := newEngine(t, BodyLimitConfig{MaxBodyBytes: 16}, metrics)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", strings.NewReader(`{"name":"a very long value"}`))
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"payload_too_large"`)
+		assert.Equal(t, []float64{28}, metrics.observationsWith("http_request_size_bytes", "rejected"))
+		assert.Empty(t, metrics.observationsWith("http_request_size_bytes", "accepted"))
+	})
+
+	t.Run("rejects chunked body without Content-Length", func(t *testing.T) {
+		metrics := newRecordingMetrics()
+		engine := newEngine(t, BodyLimitConfig{MaxBodyBytes: 16}, metrics)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", io.NopCloser(strings.NewReader(`{"name":"a very long value"}`)))
+		req.ContentLength = -1
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"payload_too_large"`)
+		assert.NotContains(t, w.Body.String(), `"error":"http: request body too large"`)
+		rejected := metrics.observationsWith("http_request_size_bytes", "rejected")
+		require.Len(t, rejected, 1, "chunked rejections are observed with the bytes read up to the limit")
+		assert.Equal(t, 16.0, rejected[0])
+	})
+
+	t.Run("route limit overrides the global limit", func(t *testing.T) {
+		engine := newEngine(t, BodyLimitConfig{
+			MaxBodyBytes: 16,
+			BodyLimits: []RouteBodyLimit{
+				{RouteMatch: RouteMatch{Method: "POST", URLPattern: "^/upload$"}, MaxBodyBytes: 1024},
+			},
+		}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/upload", io.NopCloser(strings.NewReader(strings.Repeat("x", 512))))
+		req.ContentLength = -1
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"bytes":512}`, w.Body.String())
+	})
+
+	t.Run("passes bodies within the limit", func(t *testing.T) {
+		engine := newEngine(t, BodyLimitConfig{MaxBodyBytes: 1024}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", strings.NewReader(`{"name":"ok"}`))
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+}
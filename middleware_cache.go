@@ -0,0 +1,558 @@
+package httpx
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/requestidx"
+	"github.com/gostratum/metricsx"
+)
+
+// cacheTagsKey is the gin context key holding tags added by handlers
+const cacheTagsKey = "httpx.cache_tags"
+
+// CacheConfig contains server-side response cache configuration
+type CacheConfig struct {
+	// Enabled turns on the response cache for the configured routes
+	Enabled bool `mapstructure:"enabled"`
+
+	// MaxEntries bounds the in-memory LRU store
+	MaxEntries int `mapstructure:"max_entries" default:"1000"`
+
+	// MaxEntryBytes skips caching responses with larger bodies
+	MaxEntryBytes int `mapstructure:"max_entry_bytes" default:"1048576"`
+
+	// PurgePath registers a POST endpoint purging entries by tag; empty disables it
+	PurgePath string `mapstructure:"purge_path"`
+
+	// Routes lists the cacheable GET routes; the first match wins
+	Routes []RouteCache `mapstructure:"routes"`
+}
+
+// RouteCache configures caching for matching GET routes
+type RouteCache struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// TTL is how long a response is fresh unless Cache-Control max-age says otherwise
+	TTL time.Duration `mapstructure:"ttl"`
+
+	// StaleWhileRevalidate serves expired entries this much longer while refreshing in the background
+	StaleWhileRevalidate time.Duration `mapstructure:"stale_while_revalidate"`
+
+	// VaryHeaders are request headers included in the cache key
+	VaryHeaders []string `mapstructure:"vary_headers"`
+
+	// Tags label stored entries for purging; "{name}" is replaced with the path parameter
+	Tags []string `mapstructure:"tags"`
+}
+
+// CachedResponse is a stored response
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+
+	// Tags label the entry for purging
+	Tags []string
+
+	// Vary holds the request header values the response varies on
+	Vary map[string]string
+
+	// Shared marks responses explicitly cacheable for requests with credentials
+	// (Cache-Control public or s-maxage); only these are served to such requests
+	Shared bool
+
+	StoredAt   time.Time
+	Expires    time.Time
+	StaleUntil time.Time
+}
+
+// CacheStore persists cached responses. Get returns nil without error on a miss.
+type CacheStore interface {
+	Get(ctx context.Context, key string) (*CachedResponse, error)
+	Set(ctx context.Context, key string, resp *CachedResponse) error
+	PurgeTags(ctx context.Context, tags ...string) (int, error)
+}
+
+// CacheTags adds purge tags to the response being cached
+func CacheTags(c *gin.Context, tags ...string) {
+	existing, _ := c.Get(cacheTagsKey)
+	prev, _ := existing.([]string)
+	c.Set(cacheTagsKey, append(prev, tags...))
+}
+
+// MemoryCacheStore is an in-memory LRU CacheStore
+type MemoryCacheStore struct {
+	mu         sync.Mutex
+	maxEntries int
+	lru        *list.List
+	entries    map[string]*list.Element
+	tags       map[string]map[string]struct{}
+}
+
+type memoryCacheEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+// NewMemoryCacheStore creates an LRU store holding up to maxEntries responses
+func NewMemoryCacheStore(maxEntries int) *MemoryCacheStore {
+	return &MemoryCacheStore{
+		maxEntries: maxEntries,
+		lru:        list.New(),
+		entries:    make(map[string]*list.Element),
+		tags:       make(map[string]map[string]struct{}),
+	}
+}
+
+// Get returns the entry for key and marks it recently used
+func (s *MemoryCacheStore) Get(_ context.Context, key string) (*CachedResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	el, ok := s.entries[key]
+	if !ok {
+		return nil, nil
+	}
+	entry := el.Value.(*memoryCacheEntry)
+	if time.Now().After(entry.resp.StaleUntil) {
+		s.remove(el)
+		return nil, nil
+	}
+	s.lru.MoveToFront(el)
+	return entry.resp, nil
+}
+
+// Set stores resp under key, evicting the least recently used entries
+func (s *MemoryCacheStore) Set(_ context.Context, key string, resp *CachedResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if el, ok := s.entries[key]; ok {
+		s.remove(el)
+	}
+	s.entries[key] = s.lru.PushFront(&memoryCacheEntry{key: key, resp: resp})
+	for _, t := range resp.Tags {
+		if s.tags[t] == nil {
+			s.tags[t] = make(map[string]struct{})
+		}
+		s.tags[t][key] = struct{}{}
+	}
+	for s.maxEntries > 0 && s.lru.Len() > s.maxEntries {
+		s.remove(s.lru.Back())
+	}
+	return nil
+}
+
+// PurgeTags removes every entry labelled with one of the tags
+func (s *MemoryCacheStore) PurgeTags(_ context.Context, tags ...string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	purged := 0
+	for _, t := range tags {
+		for key := range s.tags[t] {
+			if el, ok := s.entries[key]; ok {
+				s.remove(el)
+				purged++
+			}
+		}
+	}
+	return purged, nil
+}
+
+// Len returns the number of stored entries
+func (s *MemoryCacheStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lru.Len()
+}
+
+// remove deletes an element and its tag index entries; callers hold mu
+func (s *MemoryCacheStore) remove(el *list.Element) {
+	entry := el.Value.(*memoryCacheEntry)
+	s.lru.Remove(el)
+	delete(s.entries, entry.key)
+	for _, t := range entry.resp.Tags {
+		delete(s.tags[t], entry.key)
+		if len(s.tags[t]) == 0 {
+			delete(s.tags, t)
+		}
+	}
+}
+
+// cacheRule is a compiled route cache rule
+type cacheRule struct {
+	match routeMatcher
+	cfg   RouteCache
+}
+
+// ResponseCache caches GET responses for configured routes
+type ResponseCache struct {
+	cfg      CacheConfig
+	rules    []cacheRule
+	store    CacheStore
+	requests metricsx.Counter
+
+	mu         sync.Mutex
+	refreshing map[string]bool
+}
+
+// NewResponseCache creates a response cache. A nil store uses an in-memory LRU.
+func NewResponseCache(cfg CacheConfig, store CacheStore, metrics metricsx.Metrics) (*ResponseCache, error) {
+	rules := make([]cacheRule, len(cfg.Routes))
+	for i, r := range cfg.Routes {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = cacheRule{match: m, cfg: r}
+	}
+	if store == nil {
+		store = NewMemoryCacheStore(cfg.MaxEntries)
+	}
+
+	rc := &ResponseCache{cfg: cfg, rules: rules, store: store, refreshing: make(map[string]bool)}
+	if metrics != nil {
+		rc.requests = metrics.Counter(
+			"http_cache_requests_total",
+			metricsx.WithHelp("Total number of cacheable HTTP requests by cache result"),
+			metricsx.WithLabels("path", "result"),
+		)
+	}
+	return rc, nil
+}
+
+// Purge removes entries labelled with any of the tags
+func (rc *ResponseCache) Purge(ctx context.Context, tags ...string) (int, error) {
+	return rc.store.PurgeTags(ctx, tags...)
+}
+
+func (rc *ResponseCache) rule(method, path string) *RouteCache {
+	if method != http.MethodGet {
+		return nil
+	}
+	for i := range rc.rules {
+		if rc.rules[i].match.match(method, path) {
+			return &rc.rules[i].cfg
+		}
+	}
+	return nil
+}
+
+func (rc *ResponseCache) record(path, result string) {
+	if rc.requests != nil {
+		rc.requests.Inc(path, result)
+	}
+}
+
+// cacheKey builds the key from the route, sorted query and configured headers
+func cacheKey(c *gin.Context, rule *RouteCache) string {
+	var b strings.Builder
+	b.WriteString(c.Request.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(c.Request.URL.Query().Encode())
+	for _, h := range rule.VaryHeaders {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(h))
+		b.WriteByte(':')
+		b.WriteString(c.GetHeader(h))
+	}
+	return b.String()
+}
+
+// Middleware serves cached responses for configured GET routes and stores
+// fresh ones. handler re-runs requests in the background to revalidate stale
+// entries; it is normally the engine the middleware is installed on.
+//
+// Request Cache-Control no-store bypasses the cache and no-cache forces a refresh.
+// Responses are stored only with status 200 and without Cache-Control no-store
+// or private, Set-Cookie, or Vary: *; max-age and s-maxage override the route TTL.
+//
+// As a shared cache, it neither stores nor serves responses for requests with
+// credentials (an authenticated principal, Authorization, Cookie or X-API-Key)
+// unless the response is explicitly public or carries s-maxage.
+func (rc *ResponseCache) Middleware(handler http.Handler) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := rc.rule(c.Request.Method, c.FullPath())
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		path := c.FullPath()
+		reqCC := c.GetHeader("Cache-Control")
+		if hasDirective(reqCC, "no-store") {
+			rc.record(path, "bypass")
+			c.Header("X-Cache", "BYPASS")
+			c.Next()
+			return
+		}
+
+		ctx := c.Request.Context()
+		key := cacheKey(c, rule)
+		_, authenticated := principalScope(ctx)
+		credentialed := authenticated || hasCredentials(c.Request)
+		if !hasDirective(reqCC, "no-cache") {
+			if cached, err := rc.store.Get(ctx, key); err == nil && cached != nil && varyMatches(c, cached) && (cached.Shared || !credentialed) {
+				now := time.Now()
+				if now.Before(cached.Expires) {
+					rc.record(path, "hit")
+					writeCached(c, cached, "HIT", now)
+					return
+				}
+				if now.Before(cached.StaleUntil) {
+					rc.record(path, "stale")
+					rc.revalidate(handler, c.Request, key)
+					writeCached(c, cached, "STALE", now)
+					return
+				}
+			}
+		}
+
+		rc.record(path, "miss")
+		c.Header("X-Cache", "MISS")
+
+		original := c.Writer
+		rw := &cacheCaptureWriter{ResponseWriter: c.Writer, limit: rc.cfg.MaxEntryBytes}
+		c.Writer = rw
+		c.Next()
+		c.Writer = original
+
+		if resp := rc.storable(c, rule, rw); resp != nil && (resp.Shared || !credentialed) {
+			_ = rc.store.Set(context.WithoutCancel(ctx), key, resp)
+		}
+	}
+}
+
+// storable builds the entry for a captured response, or nil if it must not be cached
+func (rc *ResponseCache) storable(c *gin.Context, rule *RouteCache, rw *cacheCaptureWriter) *CachedResponse {
+	if rw.Status() != http.StatusOK || rw.overflow {
+		return nil
+	}
+	header := rw.Header()
+	cc := header.Get("Cache-Control")
+	if hasDirective(cc, "no-store") || hasDirective(cc, "private") {
+		return nil
+	}
+	// A replayed Set-Cookie would hand one client's session to the next
+	if len(header.Values("Set-Cookie")) > 0 {
+		return nil
+	}
+
+	ttl := rule.TTL
+	sMaxAge, shared := directiveSeconds(cc, "s-maxage")
+	if shared {
+		ttl = sMaxAge
+	} else if age, ok := directiveSeconds(cc, "max-age"); ok {
+		ttl = age
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	vary := map[string]string{}
+	for _, v := range header.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			f = http.CanonicalHeaderKey(strings.TrimSpace(f))
+			switch f {
+			case "*":
+				return nil
+			case "", "Accept-Encoding":
+				// Bodies are stored uncompressed; compression is applied on replay
+			default:
+				vary[f] = c.GetHeader(f)
+			}
+		}
+	}
+
+	stored := header.Clone()
+	for _, h := range []string{"Content-Length", "Content-Encoding", "Date", "X-Cache", "Age", requestidx.HeaderFromContext(c.Request.Context())} {
+		stored.Del(h)
+	}
+
+	tags, _ := c.Get(cacheTagsKey)
+	handlerTags, _ := tags.([]string)
+	allTags := make([]string, 0, len(rule.Tags)+len(handlerTags))
+	for _, t := range rule.Tags {
+		allTags = append(allTags, expandParams(t, c.Params))
+	}
+	allTags = append(allTags, handlerTags...)
+
+	now := time.Now()
+	return &CachedResponse{
+		Status:     rw.Status(),
+		Header:     stored,
+		Body:       bytes.Clone(rw.body.Bytes()),
+		Tags:       allTags,
+		Vary:       vary,
+		Shared:     shared || hasDirective(cc, "public"),
+		StoredAt:   now,
+		Expires:    now.Add(ttl),
+		StaleUntil: now.Add(ttl + rule.StaleWhileRevalidate),
+	}
+}
+
+// revalidate refreshes key in the background by replaying the request with no-cache
+func (rc *ResponseCache) revalidate(handler http.Handler, r *http.Request, key string) {
+	rc.mu.Lock()
+	if rc.refreshing[key] {
+		rc.mu.Unlock()
+		return
+	}
+	rc.refreshing[key] = true
+	rc.mu.Unlock()
+
+	req := r.Clone(context.WithoutCancel(r.Context()))
+	req.Header.Set("Cache-Control", "no-cache")
+	req.Header.Del("If-None-Match")
+	req.Header.Del("If-Modified-Since")
+
+	go func() {
+		defer func() {
+			rc.mu.Lock()
+			delete(rc.refreshing, key)
+			rc.mu.Unlock()
+		}()
+		handler.ServeHTTP(&discardResponseWriter{header: http.Header{}}, req)
+	}()
+}
+
+// registerCachePurgeRoute registers the purge endpoint under the base path.
+// Tags are read from repeated "tag" query parameters. The endpoint is only
+// registered when a policy of the engine's authorizer protects it.
+func registerCachePurgeRoute(e *gin.Engine, cfg Config, log logx.Logger, a *Authorizer, rc *ResponseCache) {
+	if !protectedByPolicy(log, a, http.MethodPost, strings.TrimRight(cfg.BasePath, "/")+cfg.Cache.PurgePath, "cache purge endpoint") {
+		return
+	}
+	e.Group(strings.TrimRight(cfg.BasePath, "/")).POST(cfg.Cache.PurgePath, func(c *gin.Context) {
+		tags := c.QueryArray("tag")
+		if len(tags) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "at least one tag is required"})
+			return
+		}
+		n, err := rc.Purge(c.Request.Context(), tags...)
+		if err != nil {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"purged": n, "tags": tags})
+	})
+}
+
+// varyMatches reports whether the request matches the header values the entry varies on
+func varyMatches(c *gin.Context, cached *CachedResponse) bool {
+	for h, v := range cached.Vary {
+		if c.GetHeader(h) != v {
+			return false
+		}
+	}
+	return true
+}
+
+// writeCached writes a stored response with cache status headers
+func writeCached(c *gin.Context, cached *CachedResponse, status string, now time.Time) {
+	for k, vs := range cached.Header {
+		c.Writer.Header()[k] = slices.Clone(vs)
+	}
+	c.Header("X-Cache", status)
+	c.Header("Age", strconv.Itoa(int(now.Sub(cached.StoredAt).Seconds())))
+	if etag := cached.Header.Get("ETag"); etag != "" && etagListContains(c.GetHeader("If-None-Match"), etag) {
+		c.AbortWithStatus(http.StatusNotModified)
+		return
+	}
+	c.Status(cached.Status)
+	_, _ = c.Writer.Write(cached.Body)
+	c.Abort()
+}
+
+// etagListContains reports whether an If-None-Match list matches etag by weak comparison
+func etagListContains(list, etag string) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// hasDirective reports whether a Cache-Control value contains the directive
+func hasDirective(cc, directive string) bool {
+	for _, d := range strings.Split(cc, ",") {
+		name, _, _ := strings.Cut(strings.TrimSpace(d), "=")
+		if strings.EqualFold(name, directive) {
+			return true
+		}
+	}
+	return false
+}
+
+// directiveSeconds returns a Cache-Control delta-seconds directive value
+func directiveSeconds(cc, directive string) (time.Duration, bool) {
+	for _, d := range strings.Split(cc, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(d), "=")
+		if ok && strings.EqualFold(name, directive) {
+			if n, err := strconv.Atoi(strings.Trim(value, `"`)); err == nil {
+				return time.Duration(n) * time.Second, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// expandParams replaces "{name}" placeholders with path parameter values
+func expandParams(s string, params gin.Params) string {
+	for _, p := range params {
+		s = strings.ReplaceAll(s, "{"+p.Key+"}", p.Value)
+	}
+	return s
+}
+
+// cacheCaptureWriter records the response body up to limit while writing it through
+type cacheCaptureWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool
+}
+
+func (w *cacheCaptureWriter) capture(n int, write func()) {
+	if w.overflow {
+		return
+	}
+	if w.limit > 0 && w.body.Len()+n > w.limit {
+		w.overflow = true
+		w.body.Reset()
+		return
+	}
+	write()
+}
+
+func (w *cacheCaptureWriter) Write(b []byte) (int, error) {
+	w.capture(len(b), func() { w.body.Write(b) })
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *cacheCaptureWriter) WriteString(s string) (int, error) {
+	w.capture(len(s), func() { w.body.WriteString(s) })
+	return w.ResponseWriter.WriteString(s)
+}
+
+// discardResponseWriter drops the response of a background revalidation
+type discardResponseWriter struct {
+	header http.Header
+}
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
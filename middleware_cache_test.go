@@ -0,0 +1,269 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseCacheMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	type fixture struct {
+		engine  *gin.Engine
+		cache   *ResponseCache
+		calls   *atomic.Int32
+		metrics *recordingMetrics
+	}
+
+	newFixture := func(t *testing.T, routes ...RouteCache) fixture {
+		metrics := newRecordingMetrics()
+		rc, err := NewResponseCache(CacheConfig{Enabled: true, MaxEntries: 10, MaxEntryBytes: 1024, Routes: routes}, nil, metrics)
+		require.NoError(t, err)
+
+		calls := &atomic.Int32{}
+		engine := gin.New()
+		engine.Use(rc.Middleware(engine))
+		engine.GET("/products/:id", func(c *gin.Context) {
+			n := calls.Add(1)
+			CacheTags(c, "catalog")
+			c.Header("ETag", `"v`+strconv.Itoa(int(n))+`"`)
+			c.String(http.StatusOK, "product %s v%d", c.Param("id"), n)
+		})
+		engine.GET("/private", func(c *gin.Context) {
+			calls.Add(1)
+			c.Header("Cache-Control", "private")
+			c.String(http.StatusOK, "secret")
+		})
+		engine.GET("/localized", func(c *gin.Context) {
+			calls.Add(1)
+			c.Header("Vary", "Accept-Language")
+			c.String(http.StatusOK, "hello in %s", c.GetHeader("Accept-Language"))
+		})
+		engine.GET("/session", func(c *gin.Context) {
+			n := calls.Add(1)
+			c.Header("Set-Cookie", "session=sess-"+strconv.Itoa(int(n)))
+			c.String(http.StatusOK, "welcome")
+		})
+		engine.GET("/broken", func(c *gin.Context) {
+			calls.Add(1)
+			c.String(http.StatusInternalServerError, "oops")
+		})
+		return fixture{engine: engine, cache: rc, calls: calls, metrics: metrics}
+	}
+
+	get := func(f fixture, target string, headers ...string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		for i := 0; i+1 < len(headers); i += 2 {
+			req.Header.Set(headers[i], headers[i+1])
+		}
+		w := httptest.NewRecorder()
+		f.engine.ServeHTTP(w, req)
+		return w
+	}
+
+	all := RouteCache{RouteMatch: RouteMatch{URLPattern: ".*"}, TTL: time.Minute, Tags: []string{"product:{id}"}}
+
+	t.Run("serves hits from the cache", func(t *testing.T) {
+		f := newFixture(t, all)
+
+		first := get(f, "/products/1?b=2&a=1")
+		second := get(f, "/products/1?a=1&b=2")
+		assert.Equal(t, "MISS", first.Header().Get("X-Cache"))
+		assert.Equal(t, "HIT", second.Header().Get("X-Cache"))
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, `"v1"`, second.Header().Get("ETag"))
+		assert.Equal(t, int32(1), f.calls.Load())
+
+		get(f, "/products/1?a=other")
+		assert.Equal(t, int32(2), f.calls.Load(), "different query is a different entry")
+
+		assert.Equal(t, float64(3), f.metrics.counter("http_cache_requests_total"))
+	})
+
+	t.Run("answers conditional hits with 304", func(t *testing.T) {
+		f := newFixture(t, all)
+		get(f, "/products/1")
+		w := get(f, "/products/1", "If-None-Match", `"v1"`)
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+	})
+
+	t.Run("honors request cache-control", func(t *testing.T) {
+		f := newFixture(t, all)
+		get(f, "/products/1")
+
+		w := get(f, "/products/1", "Cache-Control", "no-store")
+		assert.Equal(t, "BYPASS", w.Header().Get("X-Cache"))
+
+		w = get(f, "/products/1", "Cache-Control", "no-cache")
+		assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+		assert.Contains(t, get(f, "/products/1").Body.String(), "v3", "no-cache refreshes the entry")
+	})
+
+	t.Run("does not store private, failed or unconfigured responses", func(t *testing.T) {
+		f := newFixture(t, all)
+		get(f, "/private")
+		get(f, "/private")
+		get(f, "/broken")
+		get(f, "/broken")
+		assert.Equal(t, int32(4), f.calls.Load())
+
+		f = newFixture(t, RouteCache{RouteMatch: RouteMatch{URLPattern: "^/localized$"}, TTL: time.Minute})
+		get(f, "/products/1")
+		w := get(f, "/products/1")
+		assert.Empty(t, w.Header().Get("X-Cache"))
+		assert.Equal(t, int32(2), f.calls.Load())
+	})
+
+	t.Run("never replays Set-Cookie to other clients", func(t *testing.T) {
+		f := newFixture(t, all)
+		first := get(f, "/session")
+		second := get(f, "/session")
+		assert.Equal(t, "session=sess-1", first.Header().Get("Set-Cookie"))
+		assert.Equal(t, "MISS", second.Header().Get("X-Cache"))
+		assert.Equal(t, "session=sess-2", second.Header().Get("Set-Cookie"))
+		assert.Equal(t, int32(2), f.calls.Load())
+	})
+
+	t.Run("separates variants by response Vary and configured headers", func(t *testing.T) {
+		f := newFixture(t, all)
+		get(f, "/localized", "Accept-Language", "en")
+		assert.Equal(t, "HIT", get(f, "/localized", "Accept-Language", "en").Header().Get("X-Cache"))
+		w := get(f, "/localized", "Accept-Language", "de")
+		assert.Equal(t, "MISS", w.Header().Get("X-Cache"))
+		assert.Equal(t, "hello in de", w.Body.String())
+
+		f = newFixture(t, RouteCache{RouteMatch: RouteMatch{URLPattern: ".*"}, TTL: time.Minute, VaryHeaders: []string{"X-Tenant"}})
+		get(f, "/products/1", "X-Tenant", "a")
+		get(f, "/products/1", "X-Tenant", "b")
+		assert.Equal(t, "HIT", get(f, "/products/1", "X-Tenant", "a").Header().Get("X-Cache"))
+		assert.Equal(t, int32(2), f.calls.Load())
+	})
+
+	t.Run("serves stale entries while revalidating", func(t *testing.T) {
+		f := newFixture(t, RouteCache{RouteMatch: RouteMatch{URLPattern: ".*"}, TTL: 10 * time.Millisecond, StaleWhileRevalidate: time.Minute})
+		get(f, "/products/1")
+		time.Sleep(20 * time.Millisecond)
+
+		w := get(f, "/products/1")
+		assert.Equal(t, "STALE", w.Header().Get("X-Cache"))
+		assert.Contains(t, w.Body.String(), "v1")
+
+		require.Eventually(t, func() bool {
+			return get(f, "/products/1").Header().Get("X-Cache") == "HIT"
+		}, time.Second, 5*time.Millisecond)
+		assert.Contains(t, get(f, "/products/1").Body.String(), "v2")
+	})
+
+	t.Run("never serves authenticated responses to other callers", func(t *testing.T) {
+		f := newFixture(t, all)
+		f.engine.GET("/me", func(c *gin.Context) {
+			f.calls.Add(1)
+			c.String(http.StatusOK, "profile of %s", c.GetHeader("Authorization"))
+		})
+
+		get(f, "/me", "Authorization", "Bearer alice")
+		w := get(f, "/me")
+		assert.Equal(t, "profile of ", w.Body.String(), "anonymous requests never get the authenticated body")
+		w = get(f, "/me", "Authorization", "Bearer bob")
+		assert.Equal(t, "profile of Bearer bob", w.Body.String())
+		get(f, "/me", "Cookie", "session=carol")
+		assert.Equal(t, int32(4), f.calls.Load(), "requests with credentials neither store nor hit")
+
+		// The anonymous response was stored, but is not served to callers with credentials
+		assert.Equal(t, "HIT", get(f, "/me").Header().Get("X-Cache"))
+		assert.Equal(t, "MISS", get(f, "/me", "X-API-Key", "k").Header().Get("X-Cache"))
+	})
+
+	t.Run("shares explicitly public responses with authenticated requests", func(t *testing.T) {
+		f := newFixture(t, all)
+		f.engine.GET("/catalog", func(c *gin.Context) {
+			f.calls.Add(1)
+			c.Header("Cache-Control", "public, max-age=60")
+			c.String(http.StatusOK, "catalog")
+		})
+
+		get(f, "/catalog", "Authorization", "Bearer alice")
+		assert.Equal(t, "HIT", get(f, "/catalog").Header().Get("X-Cache"))
+		assert.Equal(t, "HIT", get(f, "/catalog", "Authorization", "Bearer bob").Header().Get("X-Cache"))
+		assert.Equal(t, int32(1), f.calls.Load())
+	})
+
+	t.Run("registers the purge endpoint only behind a policy", func(t *testing.T) {
+		f := newFixture(t, all)
+		registerCachePurgeRoute(f.engine, Config{BasePath: "/", Cache: CacheConfig{PurgePath: "/actuator/cache/purge"}}, logx.NewNoopLogger(), nil, f.cache)
+		w := httptest.NewRecorder()
+		f.engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/actuator/cache/purge?tag=product:1", nil))
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("purges entries by tag", func(t *testing.T) {
+		f := newFixture(t, all)
+		a, err := NewAuthorizer(logx.NewNoopLogger(), []RoutePolicy{
+			{RouteMatch: RouteMatch{URLPattern: "^/actuator/"}, Policy: authx.Policy{Roles: []string{"ops"}}},
+		})
+		require.NoError(t, err)
+		a.Authenticate(func(c *gin.Context) (*authx.Principal, error) {
+			if c.GetHeader("X-Ops") == "" {
+				return nil, nil
+			}
+			return &authx.Principal{Subject: "op", Roles: []string{"ops"}}, nil
+		})
+		f.engine.Use(a.Middleware())
+		registerCachePurgeRoute(f.engine, Config{BasePath: "/", Cache: CacheConfig{PurgePath: "/actuator/cache/purge"}}, logx.NewNoopLogger(), a, f.cache)
+		get(f, "/products/1")
+		get(f, "/products/2")
+
+		w := httptest.NewRecorder()
+		f.engine.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/actuator/cache/purge?tag=product:1", nil))
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+		w = httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, "/actuator/cache/purge?tag=product:1", nil)
+		req.Header.Set("X-Ops", "1")
+		f.engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"purged":1,"tags":["product:1"]}`, w.Body.String())
+
+		assert.Equal(t, "MISS", get(f, "/products/1").Header().Get("X-Cache"))
+		assert.Equal(t, "HIT", get(f, "/products/2").Header().Get("X-Cache"))
+
+		n, err := f.cache.Purge(t.Context(), "catalog")
+		require.NoError(t, err)
+		assert.Equal(t, 2, n)
+	})
+}
+
+func TestMemoryCacheStoreEviction(t *testing.T) {
+	store := NewMemoryCacheStore(2)
+	ctx := t.Context()
+	entry := func() *CachedResponse {
+		return &CachedResponse{Status: 200, Tags: []string{"t"}, StaleUntil: time.Now().Add(time.Minute)}
+	}
+
+	require.NoError(t, store.Set(ctx, "a", entry()))
+	require.NoError(t, store.Set(ctx, "b", entry()))
+	_, _ = store.Get(ctx, "a")
+	require.NoError(t, store.Set(ctx, "c", entry()))
+
+	assert.Equal(t, 2, store.Len())
+	b, _ := store.Get(ctx, "b")
+	assert.Nil(t, b, "least recently used entry is evicted")
+	a, _ := store.Get(ctx, "a")
+	assert.NotNil(t, a)
+
+	n, err := store.PurgeTags(ctx, "t")
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, 0, store.Len())
+}
@@ -0,0 +1,73 @@
+package httpx
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const clientIPKey ctxKey = "client_ip"
+
+// ClientIPConfig controls how the client IP is resolved behind proxies
+type ClientIPConfig struct {
+	// TrustedProxies lists the IPs and CIDRs whose forwarding headers are trusted.
+	// Empty trusts no proxy, so the client IP is the connection's remote address.
+	TrustedProxies []string `mapstructure:"trusted_proxies"`
+
+	// TrustedPlatform names a header set by the hosting platform that carries the
+	// client IP, e.g. CF-Connecting-IP or X-Real-IP. It is trusted from any peer,
+	// so only set it when the server is reachable through that platform alone.
+	TrustedPlatform string `mapstructure:"trusted_platform"`
+
+	// RemoteIPHeaders are the forwarding headers read from trusted proxies, in order
+	RemoteIPHeaders []string `mapstructure:"remote_ip_headers" default:"[\"X-Forwarded-For\",\"X-Real-IP\"]"`
+}
+
+// validate checks that every trusted proxy is an IP or CIDR
+func (c ClientIPConfig) validate() error {
+	for _, p := range c.TrustedProxies {
+		if strings.Contains(p, "/") {
+			if _, _, err := net.ParseCIDR(p); err != nil {
+				return fmt.Errorf("trusted_proxies: invalid CIDR %q", p)
+			}
+		} else if net.ParseIP(p) == nil {
+			return fmt.Errorf("trusted_proxies: invalid IP %q", p)
+		}
+	}
+	return nil
+}
+
+// configureClientIP applies the client IP settings to the engine
+func configureClientIP(e *gin.Engine, cfg ClientIPConfig) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+	if err := e.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		return err
+	}
+	e.TrustedPlatform = cfg.TrustedPlatform
+	if len(cfg.RemoteIPHeaders) > 0 {
+		e.RemoteIPHeaders = cfg.RemoteIPHeaders
+	}
+	return nil
+}
+
+// ClientIPMiddleware stores the resolved client IP in the request context so
+// code without access to the gin context, such as rate limit hooks, sees the
+// same address as logs and traces.
+func ClientIPMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := context.WithValue(c.Request.Context(), clientIPKey, c.ClientIP())
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
+
+// ClientIPFromContext returns the client IP stored by ClientIPMiddleware
+func ClientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey).(string)
+	return ip
+}
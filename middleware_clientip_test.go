@@ -0,0 +1,64 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientIPResolution(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(cfg ClientIPConfig) *gin.Engine {
+		engine := NewEngine(logx.NewNoopLogger(), Config{ClientIPConfig: cfg}, nil)
+		engine.GET("/ip", func(c *gin.Context) {
+			c.String(http.StatusOK, ClientIPFromContext(c.Request.Context())+" "+c.ClientIP())
+		})
+		return engine
+	}
+
+	get := func(engine *gin.Engine, remoteAddr string, headers map[string]string) string {
+		req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+		req.RemoteAddr = remoteAddr
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w.Body.String()
+	}
+
+	forwarded := map[string]string{"X-Forwarded-For": "203.0.113.9"}
+	defaults := ClientIPConfig{RemoteIPHeaders: []string{"X-Forwarded-For", "X-Real-IP"}}
+
+	t.Run("ignores forwarding headers without trusted proxies", func(t *testing.T) {
+		assert.Equal(t, "198.51.100.1 198.51.100.1", get(newEngine(defaults), "198.51.100.1:4000", forwarded))
+	})
+
+	t.Run("reads forwarding headers from trusted proxies only", func(t *testing.T) {
+		cfg := defaults
+		cfg.TrustedProxies = []string{"10.0.0.0/8"}
+		engine := newEngine(cfg)
+
+		assert.Equal(t, "203.0.113.9 203.0.113.9", get(engine, "10.1.2.3:4000", forwarded))
+		assert.Equal(t, "198.51.100.1 198.51.100.1", get(engine, "198.51.100.1:4000", forwarded))
+	})
+
+	t.Run("uses the trusted platform header", func(t *testing.T) {
+		cfg := defaults
+		cfg.TrustedPlatform = "CF-Connecting-IP"
+		got := get(newEngine(cfg), "198.51.100.1:4000", map[string]string{"CF-Connecting-IP": "192.0.2.7"})
+		assert.Equal(t, "192.0.2.7 192.0.2.7", got)
+	})
+
+	t.Run("invalid proxies trust nothing", func(t *testing.T) {
+		cfg := defaults
+		cfg.TrustedProxies = []string{"10.0.0.0/99"}
+		assert.Error(t, cfg.validate())
+		assert.Equal(t, "10.1.2.3 10.1.2.3", get(newEngine(cfg), "10.1.2.3:4000", forwarded))
+	})
+}
@@ -0,0 +1,223 @@
+package httpx
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/requestidx"
+	"github.com/gostratum/metricsx"
+)
+
+// CoalesceConfig contains request coalescing configuration
+type CoalesceConfig struct {
+	// Enabled turns on coalescing for the configured routes
+	Enabled bool `mapstructure:"enabled"`
+
+	// MaxBodyBytes bounds the captured response; larger responses are not shared
+	MaxBodyBytes int `mapstructure:"max_body_bytes" default:"1048576"`
+
+	// Routes lists the coalesced GET routes; the first match wins
+	Routes []RouteCoalesce `mapstructure:"routes"`
+}
+
+// RouteCoalesce configures coalescing for matching GET routes
+type RouteCoalesce struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// VaryHeaders are request headers that must match for requests to be coalesced,
+	// e.g. Authorization when responses depend on the caller
+	VaryHeaders []string `mapstructure:"vary_headers"`
+}
+
+// coalesceRule is a compiled route coalescing rule
+type coalesceRule struct {
+	match routeMatcher
+	cfg   RouteCoalesce
+}
+
+// coalesceCall is an in-flight handler execution shared by identical requests
+type coalesceCall struct {
+	done    chan struct{}
+	waiters int // guarded by Coalescer.mu
+	ok      bool
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+// Coalescer collapses concurrent identical GET requests into one handler execution
+type Coalescer struct {
+	cfg       CoalesceConfig
+	rules     []coalesceRule
+	coalesced metricsx.Counter
+
+	mu       sync.Mutex
+	inflight map[string]*coalesceCall
+}
+
+// NewCoalescer creates a request coalescer for the configured routes
+func NewCoalescer(cfg CoalesceConfig, metrics metricsx.Metrics) (*Coalescer, error) {
+	rules := make([]coalesceRule, len(cfg.Routes))
+	for i, r := range cfg.Routes {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = coalesceRule{match: m, cfg: r}
+	}
+
+	co := &Coalescer{cfg: cfg, rules: rules, inflight: make(map[string]*coalesceCall)}
+	if metrics != nil {
+		co.coalesced = metrics.Counter(
+			"http_coalesced_requests_total",
+			metricsx.WithHelp("Total number of HTTP requests served from another request's handler execution"),
+			metricsx.WithLabels("path"),
+		)
+	}
+	return co, nil
+}
+
+func (co *Coalescer) rule(method, path string) *RouteCoalesce {
+	if method != http.MethodGet {
+		return nil
+	}
+	for i := range co.rules {
+		if co.rules[i].match.match(method, path) {
+			return &co.rules[i].cfg
+		}
+	}
+	return nil
+}
+
+// keysCredentials reports whether every credential the request carries is part
+// of the coalescing key. A principal without credential headers cannot be keyed.
+func (r *RouteCoalesce) keysCredentials(req *http.Request) bool {
+	carried := false
+	for _, h := range credentialHeaders {
+		if req.Header.Get(h) == "" {
+			continue
+		}
+		carried = true
+		if !slices.ContainsFunc(r.VaryHeaders, func(v string) bool { return strings.EqualFold(v, h) }) {
+			return false
+		}
+	}
+	if _, ok := principalScope(req.Context()); ok && !carried {
+		return false
+	}
+	return true
+}
+
+// coalesceKey builds the key from the route, sorted query, conditional headers and configured headers
+func coalesceKey(c *gin.Context, rule *RouteCoalesce) string {
+	var b strings.Builder
+	b.WriteString(c.Request.URL.Path)
+	b.WriteByte('?')
+	b.WriteString(c.Request.URL.Query().Encode())
+	for _, h := range append([]string{"If-None-Match", "If-Modified-Since"}, rule.VaryHeaders...) {
+		b.WriteByte('\n')
+		b.WriteString(http.CanonicalHeaderKey(h))
+		b.WriteByte(':')
+		b.WriteString(c.GetHeader(h))
+	}
+	return b.String()
+}
+
+// Middleware runs the handler once per set of concurrent identical requests.
+// The first request executes the handler; requests arriving while it runs wait
+// and receive a copy of its response. If the shared execution panics, is aborted
+// by the client, sets a cookie or produces a response over MaxBodyBytes, waiters
+// run the handler themselves.
+//
+// Requests with credentials (an authenticated principal, Authorization, Cookie
+// or X-API-Key) are only coalesced when every credential header they carry is
+// listed in the route's VaryHeaders; otherwise they run the handler alone.
+func (co *Coalescer) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rule := co.rule(c.Request.Method, c.FullPath())
+		if rule == nil {
+			c.Next()
+			return
+		}
+
+		if !rule.keysCredentials(c.Request) {
+			// Sharing would hand one caller's response to another
+			c.Next()
+			return
+		}
+
+		key := coalesceKey(c, rule)
+		co.mu.Lock()
+		if call, ok := co.inflight[key]; ok {
+			call.waiters++
+			co.mu.Unlock()
+			co.wait(c, call)
+			return
+		}
+		call := &coalesceCall{done: make(chan struct{})}
+		co.inflight[key] = call
+		co.mu.Unlock()
+
+		defer func() {
+			co.mu.Lock()
+			delete(co.inflight, key)
+			co.mu.Unlock()
+			close(call.done)
+		}()
+
+		// Middleware after this one, such as the timeout, may replace the request
+		// context and cancel it on return; only the client's context tells an abort
+		ctx := c.Request.Context()
+		original := c.Writer
+		rw := &cacheCaptureWriter{ResponseWriter: c.Writer, limit: co.cfg.MaxBodyBytes}
+		c.Writer = rw
+		c.Next()
+		c.Writer = original
+
+		// A shared Set-Cookie would hand this caller's session to every waiter
+		if rw.overflow || ctx.Err() != nil || len(rw.Header().Values("Set-Cookie")) > 0 {
+			return
+		}
+		header := rw.Header().Clone()
+		for _, h := range []string{"Content-Length", "Content-Encoding", "Date", requestidx.HeaderFromContext(ctx)} {
+			header.Del(h)
+		}
+		call.status = rw.Status()
+		call.header = header
+		call.body = bytes.Clone(rw.body.Bytes())
+		call.ok = true
+	}
+}
+
+// wait blocks until the shared execution finishes and copies its response
+func (co *Coalescer) wait(c *gin.Context, call *coalesceCall) {
+	select {
+	case <-call.done:
+	case <-c.Request.Context().Done():
+		c.Abort()
+		return
+	}
+	if !call.ok {
+		c.Next()
+		return
+	}
+
+	if co.coalesced != nil {
+		co.coalesced.Inc(c.FullPath())
+	}
+	for k, vs := range call.header {
+		c.Writer.Header()[k] = slices.Clone(vs)
+	}
+	c.Header("X-Coalesced", "true")
+	c.Status(call.status)
+	if len(call.body) > 0 {
+		_, _ = c.Writer.Write(call.body)
+	} else {
+		c.Writer.WriteHeaderNow()
+	}
+	c.Abort()
+}
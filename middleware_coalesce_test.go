@@ -0,0 +1,269 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCoalescerMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	cfg := CoalesceConfig{
+		Enabled:      true,
+		MaxBodyBytes: 1024,
+		Routes: []RouteCoalesce{{
+			RouteMatch:  RouteMatch{URLPattern: "^/reports"},
+			VaryHeaders: []string{"Authorization"},
+		}},
+	}
+
+	type fixture struct {
+		engine  *gin.Engine
+		co      *Coalescer
+		metrics *recordingMetrics
+		passed  *atomic.Int32
+	}
+
+	// newFixtureWith returns an engine running mw between the coalescer and the
+	// handler, counting the requests that pass the coalescer instead of waiting
+	newFixtureWith := func(t *testing.T, cfg CoalesceConfig, handler gin.HandlerFunc, mw ...gin.HandlerFunc) fixture {
+		metrics := newRecordingMetrics()
+		co, err := NewCoalescer(cfg, metrics)
+		require.NoError(t, err)
+
+		passed := &atomic.Int32{}
+		engine := gin.New()
+		engine.Use(gin.CustomRecovery(func(c *gin.Context, _ any) { c.AbortWithStatus(http.StatusInternalServerError) }))
+		engine.Use(co.Middleware())
+		engine.Use(func(c *gin.Context) {
+			passed.Add(1)
+			c.Next()
+		})
+		engine.Use(mw...)
+		engine.GET("/reports/:id", handler)
+		engine.GET("/other", handler)
+		return fixture{engine: engine, co: co, metrics: metrics, passed: passed}
+	}
+	newFixture := func(t *testing.T, handler gin.HandlerFunc) fixture {
+		return newFixtureWith(t, cfg, handler)
+	}
+
+	get := func(engine *gin.Engine, target, auth string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if auth != "" {
+			req.Header.Set("Authorization", auth)
+		}
+		if auth == "cookie" {
+			req.Header.Del("Authorization")
+			req.Header.Set("Cookie", "session=1")
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	// waiting counts the requests waiting on a shared execution
+	waiting := func(co *Coalescer) int {
+		co.mu.Lock()
+		defer co.mu.Unlock()
+		n := 0
+		for _, call := range co.inflight {
+			n += call.waiters
+		}
+		return n
+	}
+
+	// fanOut issues one request per auth value: the first one alone, the others
+	// once it is inside the handler. It releases the handler only after every
+	// request is either waiting on a shared execution or past the coalescer.
+	fanOut := func(t *testing.T, f fixture, started <-chan struct{}, release chan struct{}, target string, auths ...string) []*httptest.ResponseRecorder {
+		results := make([]*httptest.ResponseRecorder, len(auths))
+		passed := f.passed.Load()
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[0] = get(f.engine, target, auths[0])
+		}()
+		<-started
+		for i := 1; i < len(auths); i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				results[i] = get(f.engine, target, auths[i])
+			}(i)
+		}
+		require.Eventually(t, func() bool {
+			return waiting(f.co)+int(f.passed.Load()-passed) == len(auths)
+		}, 5*time.Second, time.Millisecond)
+		close(release)
+		wg.Wait()
+		return results
+	}
+
+	t.Run("shares one execution between identical requests", func(t *testing.T) {
+		var calls atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		f := newFixture(t, func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				close(started)
+			}
+			<-release
+			c.Header("X-Report", c.Param("id"))
+			c.String(http.StatusOK, "report %s", c.Param("id"))
+		})
+
+		results := fanOut(t, f, started, release, "/reports/7?b=1&a=2", slices.Repeat([]string{""}, 5)...)
+
+		assert.Equal(t, int32(1), calls.Load())
+		for i, w := range results {
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "report 7", w.Body.String())
+			assert.Equal(t, "7", w.Header().Get("X-Report"))
+			assert.Equal(t, i > 0, w.Header().Get("X-Coalesced") == "true")
+		}
+		assert.Equal(t, float64(4), f.metrics.counter("http_coalesced_requests_total"))
+	})
+
+	t.Run("shares executions when a timeout middleware runs inside", func(t *testing.T) {
+		timeout, err := TimeoutMiddleware(TimeoutConfig{Default: time.Second})
+		require.NoError(t, err)
+
+		var calls atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		f := newFixtureWith(t, cfg, func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				close(started)
+			}
+			<-release
+			c.String(http.StatusOK, "report")
+		}, timeout)
+
+		results := fanOut(t, f, started, release, "/reports/1", slices.Repeat([]string{""}, 3)...)
+		assert.Equal(t, int32(1), calls.Load())
+		assert.Equal(t, "true", results[2].Header().Get("X-Coalesced"))
+	})
+
+	t.Run("does not share requests whose credentials are not keyed", func(t *testing.T) {
+		var calls atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		unkeyed := CoalesceConfig{Enabled: true, MaxBodyBytes: 1024, Routes: []RouteCoalesce{{RouteMatch: RouteMatch{URLPattern: "^/reports"}}}}
+		f := newFixtureWith(t, unkeyed, func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				close(started)
+			}
+			<-release
+			c.String(http.StatusOK, "for %s", c.GetHeader("Authorization"))
+		})
+
+		results := fanOut(t, f, started, release, "/reports/1", "Bearer a", "Bearer a", "")
+
+		assert.Equal(t, int32(3), calls.Load())
+		assert.Empty(t, results[1].Header().Get("X-Coalesced"))
+		assert.Equal(t, "for ", results[2].Body.String(), "waiters never get a credentialed response")
+
+		// Cookies must be keyed too, even when Authorization is
+		calls.Store(0)
+		started, release = make(chan struct{}), make(chan struct{})
+		f = newFixtureWith(t, cfg, func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				close(started)
+			}
+			<-release
+			c.Status(http.StatusOK)
+		})
+		fanOut(t, f, started, release, "/reports/1", "cookie", "cookie")
+		assert.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("keeps requests with different vary headers apart", func(t *testing.T) {
+		var calls atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		f := newFixture(t, func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				close(started)
+			}
+			<-release
+			c.String(http.StatusOK, c.GetHeader("Authorization"))
+		})
+
+		results := fanOut(t, f, started, release, "/reports/1", "Bearer a", "Bearer a", "Bearer b")
+
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Equal(t, "Bearer a", results[1].Body.String())
+		assert.Equal(t, "Bearer b", results[2].Body.String())
+		assert.Empty(t, results[2].Header().Get("X-Coalesced"))
+	})
+
+	t.Run("waiters run the handler when the shared execution panics", func(t *testing.T) {
+		var calls atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		f := newFixture(t, func(c *gin.Context) {
+			if calls.Add(1) == 1 {
+				close(started)
+				<-release
+				panic("boom")
+			}
+			c.String(http.StatusOK, "ok")
+		})
+
+		results := fanOut(t, f, started, release, "/reports/1", slices.Repeat([]string{""}, 3)...)
+
+		assert.Equal(t, http.StatusInternalServerError, results[0].Code)
+		assert.Equal(t, http.StatusOK, results[1].Code)
+		assert.Equal(t, http.StatusOK, results[2].Code)
+		assert.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("waiters run the handler when the shared execution sets a cookie", func(t *testing.T) {
+		var calls atomic.Int32
+		started := make(chan struct{})
+		release := make(chan struct{})
+		f := newFixture(t, func(c *gin.Context) {
+			n := calls.Add(1)
+			if n == 1 {
+				close(started)
+				<-release
+			}
+			c.Header("Set-Cookie", "session=sess-"+strconv.Itoa(int(n)))
+			c.String(http.StatusOK, "report")
+		})
+
+		results := fanOut(t, f, started, release, "/reports/1", slices.Repeat([]string{""}, 3)...)
+
+		assert.Equal(t, int32(3), calls.Load())
+		cookies := map[string]bool{}
+		for _, w := range results {
+			assert.Empty(t, w.Header().Get("X-Coalesced"))
+			cookies[w.Header().Get("Set-Cookie")] = true
+		}
+		assert.Len(t, cookies, 3, "every caller gets its own session")
+	})
+
+	t.Run("ignores unconfigured routes", func(t *testing.T) {
+		var calls atomic.Int32
+		f := newFixture(t, func(c *gin.Context) {
+			calls.Add(1)
+			c.Status(http.StatusOK)
+		})
+
+		get(f.engine, "/other", "")
+		get(f.engine, "/other", "")
+		assert.Equal(t, int32(2), calls.Load())
+	})
+}
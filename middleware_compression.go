@@ -0,0 +1,302 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+)
+
+// CompressionConfig contains response compression configuration
+type CompressionConfig struct {
+	// Enabled turns on response compression
+	Enabled bool `mapstructure:"enabled"`
+
+	// MinSize is the smallest response body in bytes that is compressed
+	MinSize int `mapstructure:"min_size" default:"1024"`
+
+	// ContentTypes is the allowlist of compressible media types; empty uses a default list
+	ContentTypes []string `mapstructure:"content_types"`
+
+	// Encodings lists the supported encodings in server preference order
+	Encodings []string `mapstructure:"encodings"`
+}
+
+// defaultCompressibleTypes are compressed when no content type allowlist is configured
+var defaultCompressibleTypes = []string{
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/css",
+	"text/event-stream",
+	"text/html",
+	"text/javascript",
+	"text/plain",
+	"text/xml",
+}
+
+// defaultEncodings is the server preference order when none is configured
+var defaultEncodings = []string{"br", "zstd", "gzip"}
+
+// uncompressedSizeKey stores the uncompressed response size for MetricsMiddleware
+const uncompressedSizeKey = "httpx.uncompressed_size"
+
+// encoder is a pooled streaming compressor
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// zstdEncoder adapts zstd.Encoder to the encoder interface
+type zstdEncoder struct{ *zstd.Encoder }
+
+func (z zstdEncoder) Reset(w io.Writer) { z.Encoder.Reset(w) }
+
+// encoderPools holds a pool of encoders per content coding
+var encoderPools = map[string]*sync.Pool{
+	"gzip": {New: func() any { return gzip.NewWriter(io.Discard) }},
+	"br":   {New: func() any { return brotli.NewWriterLevel(io.Discard, brotli.DefaultCompression) }},
+	"zstd": {New: func() any {
+		enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		return zstdEncoder{enc}
+	}},
+}
+
+func acquireEncoder(encoding string, w io.Writer) encoder {
+	enc := encoderPools[encoding].Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+func releaseEncoder(encoding string, enc encoder) {
+	enc.Reset(io.Discard)
+	encoderPools[encoding].Put(enc)
+}
+
+// negotiateEncoding picks the best supported encoding from an Accept-Encoding header.
+// Ties in quality are broken by the server preference order.
+func negotiateEncoding(header string, supported []string) string {
+	if header == "" {
+		return ""
+	}
+
+	quality := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if name == "*" {
+			wildcard = q
+			continue
+		}
+		quality[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, enc := range supported {
+		q, ok := quality[enc]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+// CompressionMiddleware compresses responses negotiated via Accept-Encoding.
+// Bodies are buffered until MinSize bytes so small responses go out uncompressed,
+// and Flush forces the decision so streaming responses keep working. The
+// uncompressed size is recorded for MetricsMiddleware.
+func CompressionMiddleware(cfg CompressionConfig) gin.HandlerFunc {
+	types := cfg.ContentTypes
+	if len(types) == 0 {
+		types = defaultCompressibleTypes
+	}
+	allowed := make(map[string]bool, len(types))
+	for _, t := range types {
+		allowed[strings.ToLower(strings.TrimSpace(t))] = true
+	}
+
+	var encodings []string
+	for _, e := range cfg.Encodings {
+		e = strings.ToLower(strings.TrimSpace(e))
+		if _, ok := encoderPools[e]; ok {
+			encodings = append(encodings, e)
+		}
+	}
+	if len(encodings) == 0 {
+		encodings = defaultEncodings
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead || c.GetHeader("Upgrade") != "" {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		cw := &compressWriter{
+			ResponseWriter: original,
+			encoding:       negotiateEncoding(c.GetHeader("Accept-Encoding"), encodings),
+			minSize:        cfg.MinSize,
+			allowed:        allowed,
+		}
+		c.Writer = cw
+
+		defer func() {
+			cw.close()
+			c.Writer = original
+			if cw.size > 0 {
+				c.Set(uncompressedSizeKey, cw.size)
+			}
+		}()
+
+		c.Next()
+	}
+}
+
+// compressWriter buffers the start of the body to decide whether to compress it
+type compressWriter struct {
+	gin.ResponseWriter
+
+	encoding string
+	minSize  int
+	allowed  map[string]bool
+
+	buf     bytes.Buffer
+	enc     encoder
+	decided bool
+	size    int
+}
+
+// eligible reports whether the response may be compressed at all,
+// independent of what the client accepts
+func (w *compressWriter) eligible() bool {
+	switch status := w.ResponseWriter.Status(); {
+	case status < 200, status == http.StatusNoContent, status == http.StatusNotModified, status == http.StatusPartialContent:
+		return false
+	}
+	h := w.ResponseWriter.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, err := mime.ParseMediaType(h.Get("Content-Type"))
+	if err != nil {
+		return false
+	}
+	return w.allowed[strings.ToLower(mediaType)]
+}
+
+// decide chooses between compressed and identity output and flushes the buffer
+func (w *compressWriter) decide(final bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+
+	if w.eligible() && (!final || w.buf.Len() >= w.minSize) {
+		h := w.ResponseWriter.Header()
+		addVary(h, "Accept-Encoding")
+		if w.encoding != "" {
+			h.Set("Content-Encoding", w.encoding)
+			h.Del("Content-Length")
+			w.enc = acquireEncoder(w.encoding, w.ResponseWriter)
+		}
+	}
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	_, err := w.out().Write(w.buf.Bytes())
+	w.buf.Reset()
+	return err
+}
+
+func (w *compressWriter) out() io.Writer {
+	if w.enc != nil {
+		return w.enc
+	}
+	return w.ResponseWriter
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	w.size += len(b)
+	if !w.decided {
+		w.buf.Write(b)
+		if w.buf.Len() < w.minSize {
+			return len(b), nil
+		}
+		return len(b), w.decide(false)
+	}
+	return w.out().Write(b)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// WriteHeaderNow is deferred until the compression decision is made
+func (w *compressWriter) WriteHeaderNow() {}
+
+func (w *compressWriter) Written() bool {
+	return w.buf.Len() > 0 || w.ResponseWriter.Written()
+}
+
+func (w *compressWriter) Size() int {
+	if w.size > 0 {
+		return w.size
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *compressWriter) Flush() {
+	_ = w.decide(false)
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// close finishes the response; small bodies are written uncompressed
+func (w *compressWriter) close() {
+	if w.size > 0 {
+		_ = w.decide(true)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		releaseEncoder(w.encoding, w.enc)
+		w.enc = nil
+	}
+}
+
+// addVary adds a field to the Vary header unless it is already listed
+func addVary(h http.Header, field string) {
+	for _, v := range h.Values("Vary") {
+		for _, f := range strings.Split(v, ",") {
+			f = strings.TrimSpace(f)
+			if f == "*" || strings.EqualFold(f, field) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", field)
+}
@@ -0,0 +1,157 @@
+package httpx
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	supported := []string{"br", "zstd", "gzip"}
+
+	assert.Equal(t, "br", negotiateEncoding("gzip, br", supported))
+	assert.Equal(t, "gzip", negotiateEncoding("gzip;q=1.0, br;q=0.5", supported))
+	assert.Equal(t, "zstd", negotiateEncoding("zstd", supported))
+	assert.Equal(t, "br", negotiateEncoding("*", supported))
+	assert.Equal(t, "gzip", negotiateEncoding("*;q=0.1, gzip", supported))
+	assert.Equal(t, "", negotiateEncoding("br;q=0, gzip;q=0", supported))
+	assert.Equal(t, "", negotiateEncoding("identity", supported))
+	assert.Equal(t, "", negotiateEncoding("", supported))
+}
+
+func TestCompressionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	large := strings.Repeat("compressible payload ", 200)
+
+	newEngine := func(cfg CompressionConfig) *gin.Engine {
+		engine := gin.New()
+		engine.Use(CompressionMiddleware(cfg))
+		engine.GET("/large", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"data": large})
+		})
+		engine.GET("/small", func(c *gin.Context) {
+			c.JSON(http.StatusOK, gin.H{"data": "tiny"})
+		})
+		engine.GET("/binary", func(c *gin.Context) {
+			c.Data(http.StatusOK, "image/png", []byte(large))
+		})
+		engine.GET("/stream", func(c *gin.Context) {
+			c.Header("Content-Type", "text/event-stream")
+			for i := 0; i < 3; i++ {
+				_, _ = c.Writer.WriteString("data: tick\n\n")
+				c.Writer.Flush()
+			}
+		})
+		return engine
+	}
+
+	decoders := map[string]func(io.Reader) (io.Reader, error){
+		"gzip": func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) },
+		"br":   func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil },
+		"zstd": func(r io.Reader) (io.Reader, error) { return zstd.NewReader(r) },
+	}
+
+	for encoding, decode := range decoders {
+		t.Run("compresses with "+encoding, func(t *testing.T) {
+			engine := newEngine(CompressionConfig{Enabled: true, MinSize: 1024})
+
+			w := httptest.NewRecorder()
+			req, _ := http.NewRequest("GET", "/large", nil)
+			req.Header.Set("Accept-Encoding", encoding)
+			engine.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, encoding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			assert.Less(t, w.Body.Len(), len(large))
+
+			r, err := decode(w.Body)
+			require.NoError(t, err)
+			body, err := io.ReadAll(r)
+			require.NoError(t, err)
+			assert.Contains(t, string(body), large)
+		})
+	}
+
+	t.Run("leaves small responses uncompressed", func(t *testing.T) {
+		engine := newEngine(CompressionConfig{Enabled: true, MinSize: 1024})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/small", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		engine.ServeHTTP(w, req)
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.JSONEq(t, `{"data":"tiny"}`, w.Body.String())
+	})
+
+	t.Run("skips content types outside the allowlist", func(t *testing.T) {
+		engine := newEngine(CompressionConfig{Enabled: true, MinSize: 10})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/binary", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		engine.ServeHTTP(w, req)
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Empty(t, w.Header().Get("Vary"))
+		assert.Equal(t, large, w.Body.String())
+	})
+
+	t.Run("adds Vary even when the client does not accept compression", func(t *testing.T) {
+		engine := newEngine(CompressionConfig{Enabled: true, MinSize: 1024})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/large", nil)
+		engine.ServeHTTP(w, req)
+
+		assert.Empty(t, w.Header().Get("Content-Encoding"))
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	})
+
+	t.Run("compresses flushed streams", func(t *testing.T) {
+		engine := newEngine(CompressionConfig{Enabled: true, MinSize: 1024})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/stream", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		engine.ServeHTTP(w, req)
+
+		assert.True(t, w.Flushed)
+		assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+		r, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		body, err := io.ReadAll(r)
+		require.NoError(t, err)
+		assert.Equal(t, strings.Repeat("data: tick\n\n", 3), string(body))
+	})
+
+	t.Run("metrics report uncompressed and compressed sizes", func(t *testing.T) {
+		metrics := newRecordingMetrics()
+
+		engine := gin.New()
+		engine.Use(MetricsMiddleware(metrics))
+		engine.Use(CompressionMiddleware(CompressionConfig{Enabled: true, MinSize: 1024}))
+		engine.GET("/large", func(c *gin.Context) {
+			c.String(http.StatusOK, large)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/large", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, []float64{float64(len(large))}, metrics.observations("http_response_size_bytes"))
+		assert.Equal(t, []float64{float64(w.Body.Len())}, metrics.observations("http_response_compressed_size_bytes"))
+	})
+}
@@ -0,0 +1,321 @@
+package httpx
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/responsex"
+	"github.com/gostratum/metricsx"
+)
+
+// ConcurrencyConfig contains adaptive concurrency limiting configuration
+type ConcurrencyConfig struct {
+	// Enabled turns on the concurrency limiter
+	Enabled bool `mapstructure:"enabled"`
+
+	// Algorithm selects how the limit adapts: "aimd" or "gradient"
+	Algorithm string `mapstructure:"algorithm" default:"aimd" validate:"oneof=aimd gradient"`
+
+	// InitialLimit is the number of in-flight requests allowed at startup
+	InitialLimit int `mapstructure:"initial_limit" default:"100"`
+
+	// MinLimit is the lower bound of the adaptive limit
+	MinLimit int `mapstructure:"min_limit" default:"10"`
+
+	// MaxLimit is the upper bound of the adaptive limit
+	MaxLimit int `mapstructure:"max_limit" default:"1000"`
+
+	// LatencyThreshold is the latency above which AIMD backs off
+	LatencyThreshold time.Duration `mapstructure:"latency_threshold" default:"250ms"`
+
+	// Backoff is the multiplicative decrease factor used by AIMD, between 0 and 1
+	Backoff float64 `mapstructure:"backoff" default:"0.9"`
+
+	// Tolerance is how much latency may grow over the baseline before gradient shrinks the limit
+	Tolerance float64 `mapstructure:"tolerance" default:"2.0"`
+
+	// PriorityHeader is a request header clients may use to lower their priority
+	// to "low"; it cannot raise it. Empty, the default, disables it
+	PriorityHeader string `mapstructure:"priority_header"`
+
+	// NormalShare is the fraction of the limit normal priority requests may use, between 0 and 1
+	NormalShare float64 `mapstructure:"normal_share" default:"0.9"`
+
+	// LowShare is the fraction of the limit low priority requests may use, between 0 and NormalShare
+	LowShare float64 `mapstructure:"low_share" default:"0.7"`
+
+	// Routes assigns priority classes to routes; the first match wins over the header
+	Routes []RoutePriority `mapstructure:"routes"`
+}
+
+// RoutePriority assigns a priority class to matching routes
+type RoutePriority struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// Priority is the priority class: "high", "normal" or "low"
+	Priority string `mapstructure:"priority"`
+}
+
+// Priority is the class used to decide which requests are shed first
+type Priority int
+
+const (
+	// PriorityLow requests are shed first
+	PriorityLow Priority = iota
+	// PriorityNormal is the default priority class
+	PriorityNormal
+	// PriorityHigh requests may use the full limit
+	PriorityHigh
+)
+
+// String returns the priority class name
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityHigh:
+		return "high"
+	default:
+		return "normal"
+	}
+}
+
+// ParsePriority parses a priority class name
+func ParsePriority(s string) (Priority, bool) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "low":
+		return PriorityLow, true
+	case "normal":
+		return PriorityNormal, true
+	case "high":
+		return PriorityHigh, true
+	}
+	return PriorityNormal, false
+}
+
+// limitAlgorithm computes a new limit from a completed request sample
+type limitAlgorithm interface {
+	update(limit float64, rtt time.Duration, inflight int, dropped bool) float64
+}
+
+// aimdLimit grows the limit by one while latency is healthy and backs off multiplicatively otherwise
+type aimdLimit struct {
+	threshold time.Duration
+	backoff   float64
+}
+
+func (a *aimdLimit) update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	if dropped || rtt > a.threshold {
+		return limit * a.backoff
+	}
+	// Only grow when the limit is actually being used
+	if float64(inflight)*2 >= limit {
+		return limit + 1
+	}
+	return limit
+}
+
+// gradientLimit adjusts the limit by the ratio of the long-term latency baseline to the sampled latency
+type gradientLimit struct {
+	tolerance float64
+	baseline  float64 // exponentially smoothed latency in seconds
+}
+
+func (g *gradientLimit) update(limit float64, rtt time.Duration, inflight int, dropped bool) float64 {
+	sample := rtt.Seconds()
+	if g.baseline == 0 {
+		g.baseline = sample
+	}
+	g.baseline = g.baseline*0.95 + sample*0.05
+
+	// Skip growth when the server is not using the limit
+	if !dropped && float64(inflight) < limit/2 {
+		return limit
+	}
+
+	gradient := 0.5
+	if sample > 0 {
+		gradient = math.Max(0.5, math.Min(1.0, g.tolerance*g.baseline/sample))
+	}
+	if dropped {
+		gradient = 0.5
+	}
+	next := limit*gradient + math.Sqrt(limit)
+
+	// Smooth the change to avoid oscillation
+	return limit*0.8 + next*0.2
+}
+
+// ConcurrencyLimiter bounds in-flight requests with an adaptive limit
+type ConcurrencyLimiter struct {
+	mu       sync.Mutex
+	limit    float64
+	inflight int
+	min      float64
+	max      float64
+	algo     limitAlgorithm
+	shares   [3]float64
+}
+
+// NewConcurrencyLimiter creates a limiter from configuration
+func NewConcurrencyLimiter(cfg ConcurrencyConfig) (*ConcurrencyLimiter, error) {
+	var algo limitAlgorithm
+	switch strings.ToLower(cfg.Algorithm) {
+	case "", "aimd":
+		algo = &aimdLimit{threshold: cfg.LatencyThreshold, backoff: cfg.Backoff}
+	case "gradient":
+		algo = &gradientLimit{tolerance: cfg.Tolerance}
+	default:
+		return nil, fmt.Errorf("httpx: unknown concurrency algorithm %q", cfg.Algorithm)
+	}
+	if cfg.MinLimit <= 0 || cfg.MaxLimit < cfg.MinLimit {
+		return nil, fmt.Errorf("httpx: invalid concurrency limit bounds [%d, %d]", cfg.MinLimit, cfg.MaxLimit)
+	}
+	if cfg.Backoff <= 0 || cfg.Backoff >= 1 {
+		return nil, fmt.Errorf("httpx: concurrency backoff %v must be between 0 and 1", cfg.Backoff)
+	}
+	if cfg.NormalShare < 0 || cfg.NormalShare > 1 || cfg.LowShare < 0 || cfg.LowShare > cfg.NormalShare {
+		return nil, fmt.Errorf("httpx: invalid concurrency shares: need 0 <= low_share (%v) <= normal_share (%v) <= 1", cfg.LowShare, cfg.NormalShare)
+	}
+
+	l := &ConcurrencyLimiter{
+		min:    float64(cfg.MinLimit),
+		max:    float64(cfg.MaxLimit),
+		algo:   algo,
+		shares: [3]float64{cfg.LowShare, cfg.NormalShare, 1.0},
+	}
+	l.limit = l.clamp(float64(cfg.InitialLimit))
+	return l, nil
+}
+
+// Acquire reserves a slot for a request of the given priority.
+// Lower priority classes may only use a share of the limit so they are shed first.
+func (l *ConcurrencyLimiter) Acquire(p Priority) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if float64(l.inflight) >= math.Ceil(l.limit*l.shares[p]) {
+		return false
+	}
+	l.inflight++
+	return true
+}
+
+// Release frees a slot and feeds the observed latency back into the algorithm
+func (l *ConcurrencyLimiter) Release(rtt time.Duration, dropped bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.limit = l.clamp(l.algo.update(l.limit, rtt, l.inflight, dropped))
+	l.inflight--
+}
+
+// Limit returns the current concurrency limit
+func (l *ConcurrencyLimiter) Limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return int(l.limit)
+}
+
+// InFlight returns the number of requests currently holding a slot
+func (l *ConcurrencyLimiter) InFlight() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.inflight
+}
+
+func (l *ConcurrencyLimiter) clamp(v float64) float64 {
+	return math.Max(l.min, math.Min(l.max, v))
+}
+
+// ConcurrencyLimitMiddleware sheds requests with 503 when the adaptive limit is reached.
+// Latency is measured from the same start time recorded by MetricsMiddleware.
+// Shed requests are counted in metrics when a metricsx provider is available.
+func ConcurrencyLimitMiddleware(cfg ConcurrencyConfig, metrics metricsx.Metrics) (gin.HandlerFunc, error) {
+	limiter, err := NewConcurrencyLimiter(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make([]routeMatcher, len(cfg.Routes))
+	priorities := make([]Priority, len(cfg.Routes))
+	for i, r := range cfg.Routes {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		p, ok := ParsePriority(r.Priority)
+		if !ok {
+			return nil, fmt.Errorf("httpx: unknown priority %q for route %q", r.Priority, r.URLPattern)
+		}
+		routes[i] = m
+		priorities[i] = p
+	}
+
+	var shedCounter metricsx.Counter
+	var limitGauge metricsx.Gauge
+	if metrics != nil {
+		shedCounter = metrics.Counter(
+			"http_requests_shed_total",
+			metricsx.WithHelp("Total number of HTTP requests rejected by the concurrency limiter"),
+			metricsx.WithLabels("method", "path", "priority"),
+		)
+		limitGauge = metrics.Gauge(
+			"http_concurrency_limit",
+			metricsx.WithHelp("Current adaptive concurrency limit"),
+		)
+		limitGauge.Set(float64(limiter.Limit()))
+	}
+
+	resolve := func(c *gin.Context) Priority {
+		for i, m := range routes {
+			if m.match(c.Request.Method, c.FullPath()) {
+				return priorities[i]
+			}
+		}
+		// The header is unauthenticated, so it may only shed the caller earlier
+		if cfg.PriorityHeader != "" {
+			if p, ok := ParsePriority(c.GetHeader(cfg.PriorityHeader)); ok && p < PriorityNormal {
+				return p
+			}
+		}
+		return PriorityNormal
+	}
+
+	return func(c *gin.Context) {
+		priority := resolve(c)
+
+		if !limiter.Acquire(priority) {
+			if shedCounter != nil {
+				shedCounter.Inc(c.Request.Method, c.FullPath(), priority.String())
+			}
+			c.Header("Retry-After", strconv.Itoa(1))
+			responsex.Error(c, http.StatusServiceUnavailable, "overloaded", "server is overloaded, retry later", nil)
+			c.Abort()
+			return
+		}
+
+		start := requestStart(c)
+		completed := false
+
+		// Release in a defer so a panicking handler cannot leak its slot; a panic
+		// unwinds past the status check and is treated as a dropped request
+		defer func() {
+			status := c.Writer.Status()
+			dropped := !completed || status == http.StatusServiceUnavailable || status == http.StatusGatewayTimeout
+			limiter.Release(time.Since(start), dropped)
+			if limitGauge != nil {
+				limitGauge.Set(float64(limiter.Limit()))
+			}
+		}()
+
+		c.Next()
+		completed = true
+	}, nil
+}
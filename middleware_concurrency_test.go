@@ -0,0 +1,213 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConcurrencyConfig() ConcurrencyConfig {
+	return ConcurrencyConfig{
+		Enabled:          true,
+		Algorithm:        "aimd",
+		InitialLimit:     10,
+		MinLimit:         1,
+		MaxLimit:         100,
+		LatencyThreshold: 100 * time.Millisecond,
+		Backoff:          0.5,
+		Tolerance:        2.0,
+		PriorityHeader:   "X-Priority",
+		NormalShare:      0.9,
+		LowShare:         0.5,
+	}
+}
+
+func TestConcurrencyLimiter(t *testing.T) {
+	t.Run("sheds low priority before high priority", func(t *testing.T) {
+		l, err := NewConcurrencyLimiter(testConcurrencyConfig())
+		require.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			require.True(t, l.Acquire(PriorityHigh))
+		}
+		assert.False(t, l.Acquire(PriorityLow), "low priority may only use half the limit")
+		assert.True(t, l.Acquire(PriorityNormal))
+		for i := 0; i < 4; i++ {
+			require.True(t, l.Acquire(PriorityHigh))
+		}
+		assert.False(t, l.Acquire(PriorityHigh))
+		assert.Equal(t, 10, l.InFlight())
+	})
+
+	t.Run("aimd backs off on slow responses and grows on fast ones", func(t *testing.T) {
+		l, err := NewConcurrencyLimiter(testConcurrencyConfig())
+		require.NoError(t, err)
+
+		require.True(t, l.Acquire(PriorityNormal))
+		l.Release(time.Second, false)
+		assert.Equal(t, 5, l.Limit())
+
+		for i := 0; i < 3; i++ {
+			require.True(t, l.Acquire(PriorityNormal))
+		}
+		l.Release(time.Millisecond, false)
+		assert.Equal(t, 6, l.Limit())
+	})
+
+	t.Run("gradient shrinks when latency exceeds the baseline", func(t *testing.T) {
+		cfg := testConcurrencyConfig()
+		cfg.Algorithm = "gradient"
+		l, err := NewConcurrencyLimiter(cfg)
+		require.NoError(t, err)
+
+		for i := 0; i < 10; i++ {
+			require.True(t, l.Acquire(PriorityHigh))
+		}
+		for i := 0; i < 5; i++ {
+			l.Release(10*time.Millisecond, false)
+		}
+		before := l.Limit()
+		for i := 0; i < 5; i++ {
+			require.True(t, l.Acquire(PriorityHigh))
+		}
+		for i := 0; i < 5; i++ {
+			l.Release(time.Second, false)
+		}
+		assert.Less(t, l.Limit(), before)
+	})
+
+	t.Run("rejects invalid settings", func(t *testing.T) {
+		for name, mutate := range map[string]func(*ConcurrencyConfig){
+			"unknown algorithm":      func(c *ConcurrencyConfig) { c.Algorithm = "vegas" },
+			"zero backoff":           func(c *ConcurrencyConfig) { c.Backoff = 0 },
+			"backoff of one":         func(c *ConcurrencyConfig) { c.Backoff = 1 },
+			"normal share above one": func(c *ConcurrencyConfig) { c.NormalShare = 1.5 },
+			"negative low share":     func(c *ConcurrencyConfig) { c.LowShare = -0.1 },
+			"low share above normal": func(c *ConcurrencyConfig) { c.LowShare = 0.95 },
+		} {
+			cfg := testConcurrencyConfig()
+			mutate(&cfg)
+			_, err := NewConcurrencyLimiter(cfg)
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestConcurrencyLimitMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("returns 503 envelope and counts shed requests", func(t *testing.T) {
+		cfg := testConcurrencyConfig()
+		cfg.InitialLimit = 1
+		cfg.LowShare = 0
+		metrics := newRecordingMetrics()
+
+		mw, err := ConcurrencyLimitMiddleware(cfg, metrics)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(mw)
+		engine.GET("/work", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/work", nil)
+		req.Header.Set("X-Priority", "low")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"overloaded"`)
+		assert.Equal(t, "1", w.Header().Get("Retry-After"))
+		assert.Equal(t, 1.0, metrics.counter("http_requests_shed_total"))
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/work", nil)
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("the header can lower but not raise the priority", func(t *testing.T) {
+		cfg := testConcurrencyConfig()
+		cfg.InitialLimit = 1
+		cfg.NormalShare = 0
+		cfg.LowShare = 0
+
+		mw, err := ConcurrencyLimitMiddleware(cfg, nil)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(mw)
+		engine.GET("/work", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/work", nil)
+		req.Header.Set("X-Priority", "high")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	})
+
+	t.Run("route priority overrides the header", func(t *testing.T) {
+		cfg := testConcurrencyConfig()
+		cfg.InitialLimit = 1
+		cfg.LowShare = 0
+		cfg.Routes = []RoutePriority{
+			{RouteMatch: RouteMatch{Method: "GET", URLPattern: "^/critical$"}, Priority: "high"},
+		}
+
+		mw, err := ConcurrencyLimitMiddleware(cfg, nil)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(mw)
+		engine.GET("/critical", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/critical", nil)
+		req.Header.Set("X-Priority", "low")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("releases the slot when the handler panics", func(t *testing.T) {
+		cfg := testConcurrencyConfig()
+		cfg.InitialLimit = 3
+		cfg.MinLimit = 3
+
+		mw, err := ConcurrencyLimitMiddleware(cfg, nil)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(RecoveryMiddleware(logx.NewNoopLogger()), mw)
+		engine.GET("/panic", func(c *gin.Context) { panic("boom") })
+		engine.GET("/work", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+		for range 5 {
+			w := httptest.NewRecorder()
+			engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+			assert.Equal(t, http.StatusInternalServerError, w.Code)
+		}
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/work", nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("rejects invalid route priority", func(t *testing.T) {
+		cfg := testConcurrencyConfig()
+		cfg.Routes = []RoutePriority{
+			{RouteMatch: RouteMatch{URLPattern: "^/x$"}, Priority: "urgent"},
+		}
+		_, err := ConcurrencyLimitMiddleware(cfg, nil)
+		assert.Error(t, err)
+	})
+}
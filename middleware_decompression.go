@@ -0,0 +1,116 @@
+package httpx
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/responsex"
+	"github.com/gostratum/metricsx"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DecompressionConfig contains request body decompression configuration
+type DecompressionConfig struct {
+	// Enabled turns on transparent request body decompression
+	Enabled bool `mapstructure:"enabled"`
+
+	// MaxDecompressedBytes caps the decoded body size to protect against zip bombs
+	MaxDecompressedBytes int64 `mapstructure:"max_decompressed_bytes" default:"10485760"`
+}
+
+// decoders opens a decoding reader per supported content coding
+var decoders = map[string]func(io.Reader) (io.ReadCloser, error){
+	"gzip": func(r io.Reader) (io.ReadCloser, error) { return gzip.NewReader(r) },
+	// HTTP deflate is the zlib format (RFC 9110), not a raw DEFLATE stream
+	"deflate": func(r io.Reader) (io.ReadCloser, error) { return zlib.NewReader(r) },
+	"br": func(r io.Reader) (io.ReadCloser, error) {
+		return io.NopCloser(brotli.NewReader(r)), nil
+	},
+	"zstd": func(r io.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return d.IOReadCloser(), nil
+	},
+}
+
+// maxContentCodings bounds stacked encodings; each one allocates a decoder
+const maxContentCodings = 2
+
+// supportedDecodings is advertised in Accept-Encoding when rejecting a request
+const supportedDecodings = "gzip, deflate, br, zstd"
+
+// DecompressionMiddleware decodes request bodies sent with Content-Encoding before
+// handlers bind them. Up to two stacked encodings are decoded in reverse order.
+// Unsupported or further encodings are rejected with 415, and decoded bodies
+// larger than MaxDecompressedBytes are rejected with 413.
+func DecompressionMiddleware(cfg DecompressionConfig, metrics metricsx.Metrics) gin.HandlerFunc {
+	sizes := rejectedSizeHistogram(metrics)
+
+	return func(c *gin.Context) {
+		header := c.Request.Header.Get("Content-Encoding")
+		if header == "" || c.Request.Body == nil || c.Request.Body == http.NoBody {
+			c.Next()
+			return
+		}
+
+		var codings []string
+		for coding := range strings.SplitSeq(header, ",") {
+			coding = strings.ToLower(strings.TrimSpace(coding))
+			if coding == "" || coding == "identity" {
+				continue
+			}
+			if len(codings) == maxContentCodings {
+				responsex.Error(c, http.StatusUnsupportedMediaType, "unsupported_encoding",
+					"too many content encodings", nil)
+				c.Abort()
+				return
+			}
+			if _, ok := decoders[coding]; !ok {
+				c.Header("Accept-Encoding", supportedDecodings)
+				responsex.Error(c, http.StatusUnsupportedMediaType, "unsupported_encoding",
+					"unsupported content encoding: "+coding, nil)
+				c.Abort()
+				return
+			}
+			codings = append(codings, coding)
+		}
+
+		var body io.ReadCloser = c.Request.Body
+		var closers []io.Closer
+		defer func() {
+			for _, cl := range closers {
+				_ = cl.Close()
+			}
+		}()
+		for i := len(codings) - 1; i >= 0; i-- {
+			r, err := decoders[codings[i]](body)
+			if err != nil {
+				responsex.Error(c, http.StatusBadRequest, "invalid_body", "request body could not be decoded", nil)
+				c.Abort()
+				return
+			}
+			closers = append(closers, r)
+			body = r
+		}
+
+		original := c.Writer
+		if cfg.MaxDecompressedBytes > 0 {
+			body = newLimitedBody(c, body, cfg.MaxDecompressedBytes, sizes)
+		}
+		c.Request.Body = body
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Del("Content-Length")
+		c.Request.ContentLength = -1
+
+		c.Next()
+
+		c.Writer = original
+	}
+}
@@ -0,0 +1,151 @@
+package httpx
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecompressionMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	gzipBody := func(t *testing.T, s string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, err := zw.Write([]byte(s))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+		return buf.Bytes()
+	}
+
+	newEngine := func(cfg DecompressionConfig, metrics *recordingMetrics) *gin.Engine {
+		engine := gin.New()
+		engine.Use(DecompressionMiddleware(cfg, metrics))
+		engine.POST("/ingest", func(c *gin.Context) {
+			var payload map[string]any
+			if err := c.ShouldBindJSON(&payload); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"payload": payload, "encoding": c.GetHeader("Content-Encoding")})
+		})
+		return engine
+	}
+
+	t.Run("decodes gzip bodies before binding", func(t *testing.T) {
+		engine := newEngine(DecompressionConfig{Enabled: true, MaxDecompressedBytes: 1024}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", bytes.NewReader(gzipBody(t, `{"name":"gzip"}`)))
+		req.Header.Set("Content-Encoding", "gzip")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"payload":{"name":"gzip"},"encoding":""}`, w.Body.String())
+	})
+
+	t.Run("decodes zstd bodies", func(t *testing.T) {
+		engine := newEngine(DecompressionConfig{Enabled: true, MaxDecompressedBytes: 1024}, nil)
+
+		enc, err := zstd.NewWriter(nil)
+		require.NoError(t, err)
+		body := enc.EncodeAll([]byte(`{"name":"zstd"}`), nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", bytes.NewReader(body))
+		req.Header.Set("Content-Encoding", "zstd")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"zstd"`)
+	})
+
+	t.Run("decodes zlib-wrapped deflate bodies", func(t *testing.T) {
+		engine := newEngine(DecompressionConfig{Enabled: true, MaxDecompressedBytes: 1024}, nil)
+
+		var buf bytes.Buffer
+		zw := zlib.NewWriter(&buf)
+		_, err := zw.Write([]byte(`{"name":"deflate"}`))
+		require.NoError(t, err)
+		require.NoError(t, zw.Close())
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", bytes.NewReader(buf.Bytes()))
+		req.Header.Set("Content-Encoding", "deflate")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"deflate"`)
+	})
+
+	t.Run("rejects unsupported encodings with 415", func(t *testing.T) {
+		engine := newEngine(DecompressionConfig{Enabled: true}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", strings.NewReader("data"))
+		req.Header.Set("Content-Encoding", "compress")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"unsupported_encoding"`)
+		assert.Contains(t, w.Header().Get("Accept-Encoding"), "gzip")
+	})
+
+	t.Run("decodes two stacked encodings and rejects more with 415", func(t *testing.T) {
+		engine := newEngine(DecompressionConfig{Enabled: true, MaxDecompressedBytes: 1024}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", bytes.NewReader(gzipBody(t, string(gzipBody(t, `{"name":"twice"}`)))))
+		req.Header.Set("Content-Encoding", "gzip, identity, gzip")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"name":"twice"`)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/ingest", strings.NewReader("data"))
+		req.Header.Set("Content-Encoding", strings.TrimSuffix(strings.Repeat("zstd,", 20000), ","))
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+		assert.Contains(t, w.Body.String(), "too many content encodings")
+	})
+
+	t.Run("rejects corrupt bodies with 400", func(t *testing.T) {
+		engine := newEngine(DecompressionConfig{Enabled: true}, nil)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", strings.NewReader("not gzip"))
+		req.Header.Set("Content-Encoding", "gzip")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"invalid_body"`)
+	})
+
+	t.Run("limits decompressed size", func(t *testing.T) {
+		metrics := newRecordingMetrics()
+		engine := newEngine(DecompressionConfig{Enabled: true, MaxDecompressedBytes: 64}, metrics)
+
+		bomb := gzipBody(t, `{"name":"`+strings.Repeat("a", 1<<20)+`"}`)
+		require.Less(t, len(bomb), 4096)
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/ingest", bytes.NewReader(bomb))
+		req.Header.Set("Content-Encoding", "gzip")
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"payload_too_large"`)
+		rejected := metrics.observationsWith("http_request_size_bytes", "rejected")
+		require.Len(t, rejected, 1)
+		assert.Equal(t, 64.0, rejected[0])
+	})
+}
@@ -0,0 +1,294 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/requestidx"
+	"github.com/gostratum/httpx/responsex"
+)
+
+// IdempotencyConfig contains Idempotency-Key handling configuration
+type IdempotencyConfig struct {
+	// Enabled turns on Idempotency-Key handling
+	Enabled bool `mapstructure:"enabled"`
+
+	// Header is the request header carrying the idempotency key
+	Header string `mapstructure:"header" default:"Idempotency-Key"`
+
+	// TTL is how long a completed response is kept for replay
+	TTL time.Duration `mapstructure:"ttl" default:"24h"`
+
+	// Methods lists the methods the key is honored for
+	Methods []string `mapstructure:"methods" default:"[\"POST\",\"PATCH\"]"`
+
+	// MaxKeyLength rejects longer keys with 400
+	MaxKeyLength int `mapstructure:"max_key_length" default:"255"`
+
+	// MaxBodyBytes bounds the request body buffered to fingerprint the request;
+	// larger bodies are rejected with 413. Zero uses 1 MiB
+	MaxBodyBytes int64 `mapstructure:"max_body_bytes" default:"1048576"`
+
+	// AllowAnonymous handles keys on requests without credentials, sharing one
+	// scope between all anonymous callers. Requests carrying credentials that no
+	// earlier middleware authenticated are never handled.
+	AllowAnonymous bool `mapstructure:"allow_anonymous"`
+}
+
+// IdempotencyRecord is the stored state of an idempotency key
+type IdempotencyRecord struct {
+	// Fingerprint identifies the request the key was first used with
+	Fingerprint string
+
+	// Completed is false while the first request is still in flight
+	Completed bool
+
+	// Status, Header and Body hold the response to replay
+	Status int
+	Header http.Header
+	Body   []byte
+}
+
+// IdempotencyStore persists idempotency records. Implementations must make
+// Reserve atomic so concurrent duplicates cannot both claim a key.
+type IdempotencyStore interface {
+	// Reserve claims key for a new request. When the key is already known it
+	// returns the existing record and false.
+	Reserve(ctx context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error)
+
+	// Complete stores the response for a reserved key
+	Complete(ctx context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error
+
+	// Release forgets a reserved key so the request can be retried
+	Release(ctx context.Context, key string) error
+}
+
+// MemoryIdempotencyStore is an in-process IdempotencyStore
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryIdempotencyEntry
+	lastSweep time.Time
+}
+
+type memoryIdempotencyEntry struct {
+	rec     IdempotencyRecord
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory store
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{entries: make(map[string]memoryIdempotencyEntry), lastSweep: time.Now()}
+}
+
+// Reserve claims key unless a live record exists
+func (s *MemoryIdempotencyStore) Reserve(_ context.Context, key, fingerprint string, ttl time.Duration) (*IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(s.lastSweep) > time.Minute {
+		for k, e := range s.entries {
+			if now.After(e.expires) {
+				delete(s.entries, k)
+			}
+		}
+		s.lastSweep = now
+	}
+
+	if e, ok := s.entries[key]; ok && now.Before(e.expires) {
+		rec := e.rec
+		return &rec, false, nil
+	}
+	s.entries[key] = memoryIdempotencyEntry{
+		rec:     IdempotencyRecord{Fingerprint: fingerprint},
+		expires: now.Add(ttl),
+	}
+	return nil, true, nil
+}
+
+// Complete stores the response for key
+func (s *MemoryIdempotencyStore) Complete(_ context.Context, key string, rec IdempotencyRecord, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	rec.Completed = true
+	s.entries[key] = memoryIdempotencyEntry{rec: rec, expires: time.Now().Add(ttl)}
+	return nil
+}
+
+// Release removes key
+func (s *MemoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// idempotencySkippedHeaders are not stored for replay, nor is the request ID
+// header; Content-Encoding belongs to the compression middleware, which sees
+// the uncompressed body captured here
+var idempotencySkippedHeaders = []string{"Content-Length", "Content-Encoding", "Date"}
+
+// IdempotencyMiddleware replays the stored response for retried requests that
+// carry the same Idempotency-Key. Keys are scoped to the method, the route and
+// the authenticated principal, so it must run after authentication; requests
+// without a principal pass through unhandled unless AllowAnonymous is set.
+// A duplicate arriving while the first request is in flight gets 409, and reuse
+// of a key with a different request body gets 422. Bodies over MaxBodyBytes
+// get 413. Responses with a 5xx status are not stored, so the request can be
+// retried.
+func IdempotencyMiddleware(cfg IdempotencyConfig, store IdempotencyStore) gin.HandlerFunc {
+	header := cfg.Header
+	if header == "" {
+		header = "Idempotency-Key"
+	}
+	maxBody := cfg.MaxBodyBytes
+	if maxBody <= 0 {
+		maxBody = 1 << 20
+	}
+
+	return func(c *gin.Context) {
+		key := c.GetHeader(header)
+		if key == "" || !slices.Contains(cfg.Methods, c.Request.Method) {
+			c.Next()
+			return
+		}
+		scope, ok := principalScope(c.Request.Context())
+		if !ok {
+			if !cfg.AllowAnonymous || hasCredentials(c.Request) {
+				// Not authenticated: replaying could leak another caller's response
+				c.Next()
+				return
+			}
+			scope = "anonymous"
+		}
+		if cfg.MaxKeyLength > 0 && len(key) > cfg.MaxKeyLength {
+			responsex.Error(c, http.StatusBadRequest, "invalid_idempotency_key", "idempotency key too long", nil)
+			c.Abort()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil && c.Request.Body != http.NoBody {
+			data, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBody+1))
+			if err != nil {
+				var tooLarge *http.MaxBytesError
+				if !errors.As(err, &tooLarge) {
+					responsex.Error(c, http.StatusBadRequest, "invalid_body", "request body could not be read", nil)
+				}
+				c.Abort()
+				return
+			}
+			if int64(len(data)) > maxBody {
+				responsex.Error(c, http.StatusRequestEntityTooLarge, "payload_too_large", "request body too large", nil)
+				c.Abort()
+				return
+			}
+			body = data
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		ctx := c.Request.Context()
+		storeKey := c.Request.Method + " " + c.FullPath() + " " + scope + " " + key
+		fingerprint := requestFingerprint(c.Request.Method, c.Request.URL.RequestURI(), body)
+
+		rec, reserved, err := store.Reserve(ctx, storeKey, fingerprint, cfg.TTL)
+		if err != nil {
+			responsex.Error(c, http.StatusServiceUnavailable, "idempotency_unavailable", "idempotency store unavailable", nil)
+			c.Abort()
+			return
+		}
+		if !reserved {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				responsex.Error(c, http.StatusUnprocessableEntity, "idempotency_key_reused",
+					"idempotency key was already used with a different request", nil)
+			case !rec.Completed:
+				responsex.Error(c, http.StatusConflict, "idempotency_conflict",
+					"a request with this idempotency key is already in progress", nil)
+			default:
+				replayResponse(c, rec)
+			}
+			c.Abort()
+			return
+		}
+
+		original := c.Writer
+		rw := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = rw
+
+		completed := false
+		defer func() {
+			c.Writer = original
+			if !completed {
+				// Handler panicked or failed: free the key for retries
+				_ = store.Release(context.WithoutCancel(ctx), storeKey)
+			}
+		}()
+
+		c.Next()
+
+		status := rw.Status()
+		if status >= http.StatusInternalServerError {
+			return
+		}
+		stored := IdempotencyRecord{
+			Fingerprint: fingerprint,
+			Status:      status,
+			Header:      rw.Header().Clone(),
+			Body:        rw.body.Bytes(),
+		}
+		for _, h := range idempotencySkippedHeaders {
+			stored.Header.Del(h)
+		}
+		stored.Header.Del(requestidx.HeaderFromContext(ctx))
+		if err := store.Complete(context.WithoutCancel(ctx), storeKey, stored, cfg.TTL); err == nil {
+			completed = true
+		}
+	}
+}
+
+// requestFingerprint hashes the parts of a request that must match on retry
+func requestFingerprint(method, uri string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(uri))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// replayResponse writes a stored response
+func replayResponse(c *gin.Context, rec *IdempotencyRecord) {
+	for k, vs := range rec.Header {
+		c.Writer.Header()[k] = slices.Clone(vs)
+	}
+	c.Header("Idempotent-Replayed", "true")
+	c.Status(rec.Status)
+	_, _ = c.Writer.Write(rec.Body)
+}
+
+// captureWriter records the response body while writing it through
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
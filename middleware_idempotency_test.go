@@ -0,0 +1,247 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/authx"
+	"github.com/gostratum/httpx/responsex"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testIdempotencyConfig() IdempotencyConfig {
+	return IdempotencyConfig{
+		Enabled:      true,
+		Header:       "Idempotency-Key",
+		TTL:          time.Hour,
+		Methods:      []string{"POST", "PATCH"},
+		MaxKeyLength: 64,
+		MaxBodyBytes: 64,
+	}
+}
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngineWith := func(cfg IdempotencyConfig, handler gin.HandlerFunc) *gin.Engine {
+		engine := gin.New()
+		// X-User stands in for an authentication middleware
+		authenticate := func(c *gin.Context) {
+			if sub := c.GetHeader("X-User"); sub != "" {
+				c.Request = c.Request.WithContext(authx.WithPrincipal(c.Request.Context(), &authx.Principal{Subject: sub}))
+			}
+		}
+		engine.Use(RequestIDMiddleware(), authenticate, IdempotencyMiddleware(cfg, NewMemoryIdempotencyStore()))
+		engine.POST("/payments", handler)
+		engine.GET("/payments", handler)
+		return engine
+	}
+	newEngine := func(handler gin.HandlerFunc) *gin.Engine {
+		return newEngineWith(testIdempotencyConfig(), handler)
+	}
+
+	send := func(engine *gin.Engine, user, method, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/payments", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+	do := func(engine *gin.Engine, method, key, body string) *httptest.ResponseRecorder {
+		return send(engine, "alice", method, key, body)
+	}
+
+	t.Run("replays the stored response for retries", func(t *testing.T) {
+		calls := 0
+		engine := newEngine(func(c *gin.Context) {
+			calls++
+			responsex.Created(c, "/payments/1", gin.H{"id": 1, "call": calls})
+		})
+
+		first := do(engine, http.MethodPost, "k1", `{"amount":10}`)
+		second := do(engine, http.MethodPost, "k1", `{"amount":10}`)
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, http.StatusCreated, second.Code)
+		assert.Equal(t, first.Body.String(), second.Body.String())
+		assert.Equal(t, "/payments/1", second.Header().Get("Location"))
+		assert.Equal(t, "true", second.Header().Get("Idempotent-Replayed"))
+		assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+		assert.NotEqual(t, first.Header().Get("X-Request-ID"), second.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("scopes keys to the authenticated principal", func(t *testing.T) {
+		calls := 0
+		engine := newEngine(func(c *gin.Context) {
+			calls++
+			c.String(http.StatusCreated, "receipt for call %d", calls)
+		})
+
+		first := send(engine, "alice", http.MethodPost, "k1", `{"amount":10}`)
+		other := send(engine, "bob", http.MethodPost, "k1", `{"amount":10}`)
+		anonymous := send(engine, "", http.MethodPost, "k1", `{"amount":10}`)
+
+		assert.Equal(t, 3, calls, "other callers never get the stored receipt")
+		assert.Equal(t, "receipt for call 1", first.Body.String())
+		assert.Empty(t, other.Header().Get("Idempotent-Replayed"))
+		assert.Empty(t, anonymous.Header().Get("Idempotent-Replayed"))
+		assert.NotEqual(t, first.Body.String(), anonymous.Body.String())
+	})
+
+	t.Run("handles anonymous requests only when allowed", func(t *testing.T) {
+		cfg := testIdempotencyConfig()
+		cfg.AllowAnonymous = true
+		calls := 0
+		engine := newEngineWith(cfg, func(c *gin.Context) {
+			calls++
+			c.Status(http.StatusCreated)
+		})
+
+		send(engine, "", http.MethodPost, "k1", `{}`)
+		replayed := send(engine, "", http.MethodPost, "k1", `{}`)
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, "true", replayed.Header().Get("Idempotent-Replayed"))
+
+		// Credentials that were not authenticated are never replayed against
+		req := httptest.NewRequest(http.MethodPost, "/payments", strings.NewReader(`{}`))
+		req.Header.Set("Idempotency-Key", "k1")
+		req.Header.Set("Authorization", "Bearer unverified")
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("rejects key reuse with a different body", func(t *testing.T) {
+		engine := newEngine(func(c *gin.Context) { c.Status(http.StatusCreated) })
+
+		do(engine, http.MethodPost, "k2", `{"amount":10}`)
+		w := do(engine, http.MethodPost, "k2", `{"amount":20}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"idempotency_key_reused"`)
+	})
+
+	t.Run("rejects concurrent duplicates", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		engine := newEngine(func(c *gin.Context) {
+			close(started)
+			<-release
+			c.Status(http.StatusCreated)
+		})
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			do(engine, http.MethodPost, "k3", `{}`)
+		}()
+		<-started
+
+		w := do(engine, http.MethodPost, "k3", `{}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"idempotency_conflict"`)
+
+		close(release)
+		wg.Wait()
+	})
+
+	t.Run("does not store server errors", func(t *testing.T) {
+		calls := 0
+		engine := newEngine(func(c *gin.Context) {
+			calls++
+			if calls == 1 {
+				c.Status(http.StatusBadGateway)
+				return
+			}
+			c.Status(http.StatusCreated)
+		})
+
+		assert.Equal(t, http.StatusBadGateway, do(engine, http.MethodPost, "k4", `{}`).Code)
+		assert.Equal(t, http.StatusCreated, do(engine, http.MethodPost, "k4", `{}`).Code)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("frees the key when the handler panics", func(t *testing.T) {
+		calls := 0
+		engine := gin.New()
+		engine.Use(gin.CustomRecovery(func(c *gin.Context, _ any) { c.AbortWithStatus(http.StatusInternalServerError) }))
+		engine.Use(IdempotencyMiddleware(testIdempotencyConfig(), NewMemoryIdempotencyStore()))
+		engine.POST("/payments", func(c *gin.Context) {
+			calls++
+			if calls == 1 {
+				panic("boom")
+			}
+			c.Status(http.StatusCreated)
+		})
+
+		assert.Equal(t, http.StatusInternalServerError, do(engine, http.MethodPost, "k5", `{}`).Code)
+		assert.Equal(t, http.StatusCreated, do(engine, http.MethodPost, "k5", `{}`).Code)
+	})
+
+	t.Run("ignores safe methods and requests without a key", func(t *testing.T) {
+		calls := 0
+		engine := newEngine(func(c *gin.Context) {
+			calls++
+			c.Status(http.StatusOK)
+		})
+
+		do(engine, http.MethodGet, "k6", "")
+		do(engine, http.MethodGet, "k6", "")
+		do(engine, http.MethodPost, "", `{}`)
+		do(engine, http.MethodPost, "", `{}`)
+		assert.Equal(t, 4, calls)
+	})
+
+	t.Run("rejects overlong keys", func(t *testing.T) {
+		engine := newEngine(func(c *gin.Context) { c.Status(http.StatusCreated) })
+		w := do(engine, http.MethodPost, strings.Repeat("k", 65), `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("rejects bodies over the buffering bound", func(t *testing.T) {
+		calls := 0
+		engine := newEngine(func(c *gin.Context) {
+			calls++
+			c.Status(http.StatusCreated)
+		})
+		w := do(engine, http.MethodPost, "k7", strings.Repeat("x", 65))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"payload_too_large"`)
+		assert.Equal(t, 0, calls)
+
+		assert.Equal(t, http.StatusCreated, do(engine, http.MethodPost, "k7", strings.Repeat("x", 64)).Code)
+	})
+}
+
+func TestMemoryIdempotencyStoreExpiry(t *testing.T) {
+	store := NewMemoryIdempotencyStore()
+	ctx := t.Context()
+
+	_, reserved, err := store.Reserve(ctx, "k", "fp", time.Millisecond)
+	require.NoError(t, err)
+	require.True(t, reserved)
+
+	time.Sleep(5 * time.Millisecond)
+	_, reserved, err = store.Reserve(ctx, "k", "fp", time.Hour)
+	require.NoError(t, err)
+	assert.True(t, reserved, "expired keys can be reserved again")
+
+	require.NoError(t, store.Complete(ctx, "k", IdempotencyRecord{Fingerprint: "fp", Status: 201}, time.Hour))
+	rec, reserved, err := store.Reserve(ctx, "k", "fp", time.Hour)
+	require.NoError(t, err)
+	assert.False(t, reserved)
+	assert.True(t, rec.Completed)
+	assert.Equal(t, 201, rec.Status)
+}
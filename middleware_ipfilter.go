@@ -0,0 +1,305 @@
+package httpx
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/responsex"
+	"github.com/gostratum/metricsx"
+)
+
+// IPFilterConfig contains client IP allow and deny list configuration
+type IPFilterConfig struct {
+	// Enabled turns on IP filtering
+	Enabled bool `mapstructure:"enabled"`
+
+	// Allow lists the IPs and CIDRs allowed to reach any route; empty allows all
+	Allow []string `mapstructure:"allow"`
+
+	// Deny lists the IPs and CIDRs rejected on every route
+	Deny []string `mapstructure:"deny"`
+
+	// File is an optional list file with one "allow <cidr>" or "deny <cidr>" per
+	// line, merged into the global lists and reloaded when it changes
+	File string `mapstructure:"file"`
+
+	// ReloadInterval is how often File is checked for changes
+	ReloadInterval time.Duration `mapstructure:"reload_interval" default:"30s"`
+
+	// Routes adds lists for matching routes, e.g. internal-only admin groups; the first match wins
+	Routes []RouteIPFilter `mapstructure:"routes"`
+}
+
+// RouteIPFilter restricts matching routes to additional allow and deny lists
+type RouteIPFilter struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// Allow lists the IPs and CIDRs allowed on matching routes; empty allows all
+	Allow []string `mapstructure:"allow"`
+
+	// Deny lists the IPs and CIDRs rejected on matching routes
+	Deny []string `mapstructure:"deny"`
+}
+
+// ipList is a compiled allow and deny list
+type ipList struct {
+	allow []netip.Prefix
+	deny  []netip.Prefix
+}
+
+// ipRouteList is a compiled per-route list
+type ipRouteList struct {
+	match routeMatcher
+	list  ipList
+}
+
+// ipRules is the immutable rule set swapped on reload
+type ipRules struct {
+	global  ipList
+	routes  []ipRouteList
+	fileMod time.Time
+}
+
+// IPFilter rejects requests by trusted client IP. Its rules can be replaced at
+// runtime with Reload, or by editing the configured list file.
+type IPFilter struct {
+	log    logx.Logger
+	cfg    atomic.Pointer[IPFilterConfig]
+	rules  atomic.Pointer[ipRules]
+	denied metricsx.Counter
+}
+
+// NewIPFilter creates an IP filter from configuration
+func NewIPFilter(log logx.Logger, cfg IPFilterConfig, metrics metricsx.Metrics) (*IPFilter, error) {
+	f := &IPFilter{log: log}
+	if err := f.Reload(cfg); err != nil {
+		return nil, err
+	}
+	if metrics != nil {
+		f.denied = metrics.Counter(
+			"http_ip_denied_total",
+			metricsx.WithHelp("Total number of HTTP requests rejected by IP allow and deny lists"),
+			metricsx.WithLabels("path", "reason"),
+		)
+	}
+	return f, nil
+}
+
+// denyAllIPFilter returns a filter rejecting every client. The engine installs
+// it in place of a filter whose configuration is invalid, so that a bad list
+// fails closed instead of letting all traffic through.
+func denyAllIPFilter(log logx.Logger, metrics metricsx.Metrics) *IPFilter {
+	f, _ := NewIPFilter(log, IPFilterConfig{Deny: []string{"0.0.0.0/0", "::/0"}}, metrics)
+	return f
+}
+
+// Reload validates cfg and atomically replaces the active rules. On error the
+// previous rules stay in effect.
+func (f *IPFilter) Reload(cfg IPFilterConfig) error {
+	rules, err := compileIPRules(cfg)
+	if err != nil {
+		return err
+	}
+	f.cfg.Store(&cfg)
+	f.rules.Store(rules)
+	return nil
+}
+
+// Watch reloads the list file whenever it changes until ctx is cancelled.
+// Reload failures are logged and keep the previous rules.
+func (f *IPFilter) Watch(ctx context.Context) {
+	cfg := f.cfg.Load()
+	if cfg.File == "" || cfg.ReloadInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(cfg.ReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			cfg := f.cfg.Load()
+			info, err := os.Stat(cfg.File)
+			if err != nil || info.ModTime().Equal(f.rules.Load().fileMod) {
+				continue
+			}
+			if err := f.Reload(*cfg); err != nil {
+				f.log.Error("httpx: ip filter reload failed", logx.String("file", cfg.File), logx.Err(err))
+				continue
+			}
+			f.log.Info("httpx: ip filter reloaded", logx.String("file", cfg.File))
+		}
+	}
+}
+
+// Middleware rejects requests whose client IP is denied, or not allowed when an
+// allow list applies, with a 403 error envelope. Global lists are checked first,
+// then the lists of the first matching route.
+func (f *IPFilter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		rules := f.rules.Load()
+		addr, err := netip.ParseAddr(c.ClientIP())
+		addr = addr.Unmap()
+
+		reason := ""
+		if err != nil {
+			reason = "invalid"
+		} else {
+			reason = rules.global.check(addr)
+			if reason == "" {
+				for _, r := range rules.routes {
+					if r.match.match(c.Request.Method, c.FullPath()) {
+						reason = r.list.check(addr)
+						break
+					}
+				}
+			}
+		}
+		if reason == "" {
+			c.Next()
+			return
+		}
+
+		if f.denied != nil {
+			f.denied.Inc(c.FullPath(), reason)
+		}
+		responsex.Error(c, http.StatusForbidden, "forbidden", "client address is not allowed", nil)
+		c.Abort()
+	}
+}
+
+// check returns "denied" or "not_allowed" when addr is rejected by the list
+func (l ipList) check(addr netip.Addr) string {
+	if containsAddr(l.deny, addr) {
+		return "denied"
+	}
+	if len(l.allow) > 0 && !containsAddr(l.allow, addr) {
+		return "not_allowed"
+	}
+	return ""
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	for _, p := range prefixes {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// compileIPRules parses the configured lists and list file
+func compileIPRules(cfg IPFilterConfig) (*ipRules, error) {
+	global, err := compileIPList(cfg.Allow, cfg.Deny)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &ipRules{routes: make([]ipRouteList, len(cfg.Routes))}
+	if cfg.File != "" {
+		allow, deny, m, err := readIPListFile(cfg.File)
+		if err != nil {
+			return nil, err
+		}
+		fromFile, err := compileIPList(allow, deny)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", cfg.File, err)
+		}
+		global.allow = append(global.allow, fromFile.allow...)
+		global.deny = append(global.deny, fromFile.deny...)
+		rules.fileMod = m
+	}
+	rules.global = global
+
+	for i, r := range cfg.Routes {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		list, err := compileIPList(r.Allow, r.Deny)
+		if err != nil {
+			return nil, err
+		}
+		rules.routes[i] = ipRouteList{match: m, list: list}
+	}
+	return rules, nil
+}
+
+func compileIPList(allow, deny []string) (ipList, error) {
+	var l ipList
+	var err error
+	if l.allow, err = parsePrefixes(allow); err != nil {
+		return ipList{}, err
+	}
+	if l.deny, err = parsePrefixes(deny); err != nil {
+		return ipList{}, err
+	}
+	return l, nil
+}
+
+// parsePrefixes parses IPs and CIDRs; plain IPs become single-address prefixes
+func parsePrefixes(values []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if strings.Contains(v, "/") {
+			p, err := netip.ParsePrefix(v)
+			if err != nil {
+				return nil, fmt.Errorf("ip filter: invalid CIDR %q", v)
+			}
+			prefixes = append(prefixes, p.Masked())
+			continue
+		}
+		a, err := netip.ParseAddr(v)
+		if err != nil {
+			return nil, fmt.Errorf("ip filter: invalid IP %q", v)
+		}
+		a = a.Unmap()
+		prefixes = append(prefixes, netip.PrefixFrom(a, a.BitLen()))
+	}
+	return prefixes, nil
+}
+
+// readIPListFile reads "allow <cidr>" and "deny <cidr>" lines; blank lines and # comments are ignored
+func readIPListFile(path string) (allow, deny []string, mod time.Time, err error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return nil, nil, time.Time{}, err
+	}
+
+	scanner := bufio.NewScanner(file)
+	for n := 1; scanner.Scan(); n++ {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, nil, time.Time{}, fmt.Errorf("%s:%d: expected \"allow|deny <cidr>\"", path, n)
+		}
+		switch fields[0] {
+		case "allow":
+			allow = append(allow, fields[1])
+		case "deny":
+			deny = append(deny, fields[1])
+		default:
+			return nil, nil, time.Time{}, fmt.Errorf("%s:%d: unknown action %q", path, n, fields[0])
+		}
+	}
+	return allow, deny, info.ModTime(), scanner.Err()
+}
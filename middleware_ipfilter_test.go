@@ -0,0 +1,107 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIPFilterMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(t *testing.T, cfg IPFilterConfig) (*gin.Engine, *IPFilter, *recordingMetrics) {
+		metrics := newRecordingMetrics()
+		f, err := NewIPFilter(logx.NewNoopLogger(), cfg, metrics)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(f.Middleware())
+		engine.GET("/public", func(c *gin.Context) { c.Status(http.StatusOK) })
+		engine.GET("/admin/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return engine, f, metrics
+	}
+
+	get := func(engine *gin.Engine, path, ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = ip + ":4000"
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	cfg := IPFilterConfig{
+		Enabled: true,
+		Deny:    []string{"203.0.113.0/24"},
+		Routes: []RouteIPFilter{{
+			RouteMatch: RouteMatch{URLPattern: "^/admin"},
+			Allow:      []string{"10.0.0.0/8", "192.168.1.10"},
+		}},
+	}
+
+	t.Run("applies global and per-route lists", func(t *testing.T) {
+		engine, _, metrics := newEngine(t, cfg)
+
+		assert.Equal(t, http.StatusOK, get(engine, "/public", "198.51.100.1").Code)
+		assert.Equal(t, http.StatusOK, get(engine, "/admin/users", "10.2.3.4").Code)
+		assert.Equal(t, http.StatusOK, get(engine, "/admin/users", "192.168.1.10").Code)
+
+		w := get(engine, "/admin/users", "198.51.100.1")
+		assert.Equal(t, http.StatusForbidden, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"forbidden"`)
+
+		assert.Equal(t, http.StatusForbidden, get(engine, "/public", "203.0.113.5").Code)
+		assert.Equal(t, float64(2), metrics.counter("http_ip_denied_total"))
+	})
+
+	t.Run("reload replaces rules and keeps them on error", func(t *testing.T) {
+		engine, f, _ := newEngine(t, cfg)
+
+		require.NoError(t, f.Reload(IPFilterConfig{Enabled: true, Deny: []string{"198.51.100.1"}}))
+		assert.Equal(t, http.StatusForbidden, get(engine, "/public", "198.51.100.1").Code)
+		assert.Equal(t, http.StatusOK, get(engine, "/admin/users", "198.51.100.2").Code)
+
+		assert.Error(t, f.Reload(IPFilterConfig{Deny: []string{"not-an-ip"}}))
+		assert.Equal(t, http.StatusForbidden, get(engine, "/public", "198.51.100.1").Code)
+	})
+
+	t.Run("watches the list file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "ips.txt")
+		require.NoError(t, os.WriteFile(path, []byte("# office\nallow 10.0.0.0/8\n"), 0o600))
+
+		engine, f, _ := newEngine(t, IPFilterConfig{Enabled: true, File: path, ReloadInterval: 5 * time.Millisecond})
+		assert.Equal(t, http.StatusOK, get(engine, "/public", "10.0.0.1").Code)
+		assert.Equal(t, http.StatusForbidden, get(engine, "/public", "198.51.100.1").Code)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go f.Watch(ctx)
+
+		require.NoError(t, os.WriteFile(path, []byte("allow 198.51.100.0/24\n"), 0o600))
+		require.NoError(t, os.Chtimes(path, time.Now(), time.Now().Add(time.Second)))
+		require.Eventually(t, func() bool {
+			return get(engine, "/public", "198.51.100.1").Code == http.StatusOK
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, http.StatusForbidden, get(engine, "/public", "10.0.0.1").Code)
+	})
+
+	t.Run("rejects invalid lists", func(t *testing.T) {
+		_, err := NewIPFilter(logx.NewNoopLogger(), IPFilterConfig{Allow: []string{"10.0.0.0/33"}}, nil)
+		assert.Error(t, err)
+	})
+
+	t.Run("engine rejects every client when the lists are invalid", func(t *testing.T) {
+		engine := NewEngine(logx.NewNoopLogger(), Config{IPFilter: IPFilterConfig{Enabled: true, Deny: []string{"203.0.113.0/33"}}}, nil)
+		engine.GET("/public", func(c *gin.Context) { c.Status(http.StatusOK) })
+		assert.Equal(t, http.StatusForbidden, get(engine, "/public", "198.51.100.1").Code)
+		assert.Equal(t, http.StatusForbidden, get(engine, "/public", "2001:db8::1").Code)
+	})
+}
@@ -0,0 +1,45 @@
+package httpx
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/gostratum/tracingx"
+)
+
+// RequestLoggerMiddleware attaches a child of log to the request context. It
+// carries the request ID, method and route, plus the trace and span IDs when
+// TracingMiddleware ran first, so handler logs correlate with the access log
+// and traces. Retrieve it with LoggerFromContext.
+func RequestLoggerMiddleware(log logx.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := c.Request.Context()
+		fields := []logx.Field{
+			logx.String("rid", RequestIDFromContext(ctx)),
+			logx.String("method", c.Request.Method),
+			logx.String("path", c.FullPath()),
+		}
+		if span := tracingx.SpanFromContext(ctx); span != nil && span.TraceID() != "" {
+			fields = append(fields,
+				logx.String("trace_id", span.TraceID()),
+				logx.String("span_id", span.SpanID()),
+			)
+		}
+
+		c.Request = c.Request.WithContext(logx.WithContext(ctx, log.With(fields...)))
+		c.Next()
+	}
+}
+
+// LoggerFromContext returns the request-scoped logger attached by
+// RequestLoggerMiddleware, adding the authenticated subject once an auth
+// middleware has verified one. Without a request logger it returns a no-op logger.
+func LoggerFromContext(ctx context.Context) logx.Logger {
+	log := logx.FromContext(ctx)
+	if p, ok := authx.PrincipalFromContext(ctx); ok {
+		log = log.With(logx.String("sub", p.Subject))
+	}
+	return log
+}
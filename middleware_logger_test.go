@@ -0,0 +1,79 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/gostratum/httpx/responsex"
+	"github.com/gostratum/tracingx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// stubSpan is a tracingx.Span with fixed IDs
+type stubSpan struct{ ctx context.Context }
+
+func (s *stubSpan) End()                        {}
+func (s *stubSpan) SetTag(string, any)          {}
+func (s *stubSpan) SetError(error)              {}
+func (s *stubSpan) LogFields(...tracingx.Field) {}
+func (s *stubSpan) Context() context.Context    { return s.ctx }
+func (s *stubSpan) TraceID() string             { return "4bf92f3577b34da6a3ce929d0e0e4736" }
+func (s *stubSpan) SpanID() string              { return "00f067aa0ba902b7" }
+
+func TestRequestLoggerMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	core, logs := observer.New(zap.InfoLevel)
+	engine := gin.New()
+	engine.Use(RequestIDMiddleware())
+	engine.Use(func(c *gin.Context) {
+		ctx := c.Request.Context()
+		c.Request = c.Request.WithContext(tracingx.ContextWithSpan(ctx, &stubSpan{ctx: ctx}))
+	})
+	engine.Use(RequestLoggerMiddleware(logx.ProvideAdapter(zap.New(core))))
+	engine.GET("/orders/:id", withPrincipal(&authx.Principal{Subject: "user-1"}), func(c *gin.Context) {
+		LoggerFromContext(c.Request.Context()).Info("loading order")
+		c.Status(http.StatusOK)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/orders/7", nil)
+	req.Header.Set("X-Request-ID", "rid-7")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	entries := logs.FilterMessage("loading order").All()
+	require.Len(t, entries, 1)
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "rid-7", fields["rid"])
+	assert.Equal(t, http.MethodGet, fields["method"])
+	assert.Equal(t, "/orders/:id", fields["path"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", fields["span_id"])
+	assert.Equal(t, "user-1", fields["sub"])
+}
+
+func TestLoggerFromContextFallbacks(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("returns a no-op logger without a request logger", func(t *testing.T) {
+		assert.NotPanics(t, func() { LoggerFromContext(context.Background()).Info("dropped") })
+	})
+
+	t.Run("returns the logger attached by responsex.WithLogger", func(t *testing.T) {
+		core, logs := observer.New(zap.InfoLevel)
+		engine := gin.New()
+		engine.GET("/", func(c *gin.Context) {
+			responsex.WithLogger(c, logx.ProvideAdapter(zap.New(core)))
+			LoggerFromContext(c.Request.Context()).Info("hello")
+		})
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		assert.Equal(t, 1, logs.FilterMessage("hello").Len())
+	})
+}
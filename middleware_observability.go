@@ -5,10 +5,45 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/authx"
 	"github.com/gostratum/metricsx"
 	"github.com/gostratum/tracingx"
 )
 
+// requestStartKey stores the request start time shared by timing-based middleware
+const requestStartKey = "httpx.start_time"
+
+// requestStart returns the start time recorded by MetricsMiddleware,
+// recording the current time if no earlier middleware did
+func requestStart(c *gin.Context) time.Time {
+	if v, ok := c.Get(requestStartKey); ok {
+		if t, ok := v.(time.Time); ok {
+			return t
+		}
+	}
+	t := time.Now()
+	c.Set(requestStartKey, t)
+	return t
+}
+
+// bodyRejectedKey marks requests whose body was rejected by a size limit
+const bodyRejectedKey = "httpx.body_rejected"
+
+// slowRequestKey marks requests flagged as slow by SlowRequestMiddleware
+const slowRequestKey = "httpx.slow_request"
+
+// requestSizeHistogram returns the http_request_size_bytes family shared by
+// MetricsMiddleware and the body size limits. The outcome label is accepted,
+// or rejected for bodies refused by a size limit.
+func requestSizeHistogram(metrics metricsx.Metrics) metricsx.Histogram {
+	return metrics.Histogram(
+		"http_request_size_bytes",
+		metricsx.WithHelp("HTTP request size in bytes"),
+		metricsx.WithLabels("method", "path", "outcome"),
+		metricsx.WithBuckets(100, 1000, 10000, 100000, 1000000, 10000000),
+	)
+}
+
 // MetricsMiddleware instruments HTTP requests with metrics if metricsx is available
 func MetricsMiddleware(metrics metricsx.Metrics) gin.HandlerFunc {
 	// Create metric collectors
@@ -25,12 +60,7 @@ func MetricsMiddleware(metrics metricsx.Metrics) gin.HandlerFunc {
 		metricsx.WithBuckets(0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0),
 	)
 
-	requestSize := metrics.Histogram(
-		"http_request_size_bytes",
-		metricsx.WithHelp("HTTP request size in bytes"),
-		metricsx.WithLabels("method", "path"),
-		metricsx.WithBuckets(100, 1000, 10000, 100000, 1000000, 10000000),
-	)
+	requestSize := requestSizeHistogram(metrics)
 
 	responseSize := metrics.Histogram(
 		"http_response_size_bytes",
@@ -39,26 +69,33 @@ func MetricsMiddleware(metrics metricsx.Metrics) gin.HandlerFunc {
 		metricsx.WithBuckets(100, 1000, 10000, 100000, 1000000, 10000000),
 	)
 
+	compressedSize := metrics.Histogram(
+		"http_response_compressed_size_bytes",
+		metricsx.WithHelp("HTTP response size on the wire in bytes for compressed responses"),
+		metricsx.WithLabels("method", "path", "status"),
+		metricsx.WithBuckets(100, 1000, 10000, 100000, 1000000, 10000000),
+	)
+
 	activeRequests := metrics.Gauge(
 		"http_requests_in_flight",
 		metricsx.WithHelp("Current number of HTTP requests being processed"),
 	)
 
 	return func(c *gin.Context) {
-		start := time.Now()
+		start := requestStart(c)
 
 		// Increment active requests
 		activeRequests.Inc()
 		defer activeRequests.Dec()
 
-		// Record request size
-		if c.Request.ContentLength > 0 {
-			requestSize.Observe(float64(c.Request.ContentLength), c.Request.Method, c.FullPath())
-		}
-
 		// Process request
 		c.Next()
 
+		// Record request size; body size limits record rejected requests themselves
+		if c.Request.ContentLength > 0 && !c.GetBool(bodyRejectedKey) {
+			requestSize.Observe(float64(c.Request.ContentLength), c.Request.Method, c.FullPath(), "accepted")
+		}
+
 		// Calculate duration
 		duration := time.Since(start).Seconds()
 		status := strconv.Itoa(c.Writer.Status())
@@ -66,7 +103,16 @@ func MetricsMiddleware(metrics metricsx.Metrics) gin.HandlerFunc {
 		// Record metrics
 		requestCounter.Inc(c.Request.Method, c.FullPath(), status)
 		requestDuration.Observe(duration, c.Request.Method, c.FullPath(), status)
-		responseSize.Observe(float64(c.Writer.Size()), c.Request.Method, c.FullPath(), status)
+
+		// Response size stays the uncompressed payload size; wire size is reported separately
+		if v, ok := c.Get(uncompressedSizeKey); ok {
+			responseSize.Observe(float64(v.(int)), c.Request.Method, c.FullPath(), status)
+			if c.Writer.Header().Get("Content-Encoding") != "" {
+				compressedSize.Observe(float64(c.Writer.Size()), c.Request.Method, c.FullPath(), status)
+			}
+		} else {
+			responseSize.Observe(float64(c.Writer.Size()), c.Request.Method, c.FullPath(), status)
+		}
 	}
 }
 
@@ -89,7 +135,7 @@ func TracingMiddleware(tracer tracingx.Tracer) gin.HandlerFunc {
 				"http.host":        c.Request.Host,
 				"http.scheme":      c.Request.URL.Scheme,
 				"http.user_agent":  c.Request.UserAgent(),
-				"http.request_id":  c.Writer.Header().Get("X-Request-ID"),
+				"http.request_id":  RequestIDFromContext(c.Request.Context()),
 				"http.remote_addr": c.ClientIP(),
 			}),
 		)
@@ -108,6 +154,9 @@ func TracingMiddleware(tracer tracingx.Tracer) gin.HandlerFunc {
 		status := c.Writer.Status()
 		span.SetTag("http.status_code", status)
 		span.SetTag("http.response_size", c.Writer.Size())
+		if p, ok := authx.PrincipalFromContext(c.Request.Context()); ok {
+			span.SetTag("enduser.id", p.Subject)
+		}
 
 		// Mark span as error if status >= 500
 		if status >= 500 {
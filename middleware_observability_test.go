@@ -3,9 +3,12 @@ package httpx
 import (
 	"net/http"
 	"net/http/httptest"
+	"slices"
+	"sync"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gostratum/metricsx"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -281,3 +284,127 @@ func TestObservabilityIntegration(t *testing.T) {
 		assert.True(t, tracer.spans[0].ended)
 	})
 }
+
+// recordingMetrics implements metricsx.Metrics and records counter and gauge values by name
+type recordingMetrics struct {
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+	observed map[string][]float64
+	labeled  map[string][]recordedObservation
+	added    map[string][]recordedObservation
+}
+
+// recordedObservation is one counter increment or histogram observation with its label values
+type recordedObservation struct {
+	value  float64
+	labels []string
+}
+
+func newRecordingMetrics() *recordingMetrics {
+	return &recordingMetrics{
+		counters: make(map[string]float64),
+		gauges:   make(map[string]float64),
+		observed: make(map[string][]float64),
+		labeled:  make(map[string][]recordedObservation),
+		added:    make(map[string][]recordedObservation),
+	}
+}
+
+func (m *recordingMetrics) Counter(name string, opts ...metricsx.Option) metricsx.Counter {
+	return &recordingInstrument{m: m, name: name}
+}
+
+func (m *recordingMetrics) Gauge(name string, opts ...metricsx.Option) metricsx.Gauge {
+	return &recordingInstrument{m: m, name: name}
+}
+
+func (m *recordingMetrics) Histogram(name string, opts ...metricsx.Option) metricsx.Histogram {
+	return &recordingInstrument{m: m, name: name}
+}
+
+func (m *recordingMetrics) Summary(name string, opts ...metricsx.Option) metricsx.Summary {
+	return &recordingInstrument{m: m, name: name}
+}
+
+func (m *recordingMetrics) counter(name string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.counters[name]
+}
+
+func (m *recordingMetrics) gauge(name string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.gauges[name]
+}
+
+func (m *recordingMetrics) observations(name string) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]float64(nil), m.observed[name]...)
+}
+
+// observationsWith returns the observations of name carrying the label value
+func (m *recordingMetrics) observationsWith(name, label string) []float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var values []float64
+	for _, o := range m.labeled[name] {
+		if slices.Contains(o.labels, label) {
+			values = append(values, o.value)
+		}
+	}
+	return values
+}
+
+// counterWith returns the total of counter name over increments carrying the label value
+func (m *recordingMetrics) counterWith(name, label string) float64 {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var total float64
+	for _, o := range m.added[name] {
+		if slices.Contains(o.labels, label) {
+			total += o.value
+		}
+	}
+	return total
+}
+
+type recordingInstrument struct {
+	m    *recordingMetrics
+	name string
+}
+
+func (i *recordingInstrument) Inc(labels ...string) { i.Add(1, labels...) }
+
+func (i *recordingInstrument) Add(value float64, labels ...string) {
+	i.m.mu.Lock()
+	defer i.m.mu.Unlock()
+	i.m.counters[i.name] += value
+	i.m.gauges[i.name] += value
+	i.m.added[i.name] = append(i.m.added[i.name], recordedObservation{value: value, labels: labels})
+}
+
+func (i *recordingInstrument) Set(value float64, labels ...string) {
+	i.m.mu.Lock()
+	defer i.m.mu.Unlock()
+	i.m.gauges[i.name] = value
+}
+
+func (i *recordingInstrument) Dec(labels ...string) { i.Sub(1, labels...) }
+
+func (i *recordingInstrument) Sub(value float64, labels ...string) {
+	i.m.mu.Lock()
+	defer i.m.mu.Unlock()
+	i.m.gauges[i.name] -= value
+}
+
+func (i *recordingInstrument) Observe(value float64, labels ...string) {
+	i.m.mu.Lock()
+	defer i.m.mu.Unlock()
+	i.m.observed[i.name] = append(i.m.observed[i.name], value)
+	i.m.labeled[i.name] = append(i.m.labeled[i.name], recordedObservation{value: value, labels: labels})
+}
+
+func (i *recordingInstrument) Timer(labels ...string) metricsx.Timer { return nil }
@@ -0,0 +1,134 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/responsex"
+	"github.com/gostratum/metricsx"
+	"github.com/gostratum/tracingx"
+)
+
+// defaultStackDepth is the number of stack frames RecoveryMiddleware logs
+const defaultStackDepth = 32
+
+// RecoveryConfig contains panic recovery configuration
+type RecoveryConfig struct {
+	// StackDepth is the number of stack frames logged with a panic; negative disables stacks
+	StackDepth int `mapstructure:"stack_depth" default:"32"`
+}
+
+// PanicRecoveryMiddleware recovers handler panics and answers with a 500
+// error envelope carrying the request ID. The panic is logged with up to
+// StackDepth stack frames, recorded as a panic event and error on the active
+// span and counted in http_panics_total by method and route.
+//
+// Panics caused by the client closing the connection (broken pipe or
+// connection reset) are logged at debug without a stack, since no response
+// can be delivered. http.ErrAbortHandler is re-raised for net/http to handle.
+func PanicRecoveryMiddleware(log logx.Logger, cfg RecoveryConfig, metrics metricsx.Metrics) gin.HandlerFunc {
+	var panics metricsx.Counter
+	if metrics != nil {
+		panics = metrics.Counter(
+			"http_panics_total",
+			metricsx.WithHelp("Total number of recovered handler panics"),
+			metricsx.WithLabels("method", "path"),
+		)
+	}
+
+	return func(c *gin.Context) {
+		defer func() {
+			rec := recover()
+			if rec == nil {
+				return
+			}
+			if rec == http.ErrAbortHandler {
+				panic(rec)
+			}
+
+			ctx := c.Request.Context()
+			fields := []logx.Field{
+				logx.String("rid", RequestIDFromContext(ctx)),
+				logx.String("method", c.Request.Method),
+				logx.String("path", c.FullPath()),
+				logx.Any("error", rec),
+			}
+
+			if err, ok := rec.(error); ok && isBrokenPipe(err) {
+				log.Debug("httpx: client connection closed", fields...)
+				_ = c.Error(err)
+				c.Abort()
+				return
+			}
+
+			var stack string
+			if cfg.StackDepth >= 0 {
+				// Skip runtime.Callers, panicStack and this function
+				stack = panicStack(3, cfg.StackDepth)
+				fields = append(fields, logx.String("stack", stack))
+			}
+			log.Error("http panic recovered", fields...)
+
+			if panics != nil {
+				panics.Inc(c.Request.Method, c.FullPath())
+			}
+			if span := tracingx.SpanFromContext(ctx); span != nil {
+				span.LogFields(
+					tracingx.Field{Key: "event", Value: "panic"},
+					tracingx.Field{Key: "panic.value", Value: fmt.Sprint(rec)},
+					tracingx.Field{Key: "panic.stack", Value: stack},
+				)
+				span.SetError(fmt.Errorf("panic: %v", rec))
+			}
+
+			if !c.Writer.Written() {
+				responsex.Error(c, http.StatusInternalServerError, "internal_error", "internal server error", nil)
+			}
+			c.Abort()
+		}()
+		c.Next()
+	}
+}
+
+// isBrokenPipe reports whether err means the client went away mid-response
+func isBrokenPipe(err error) bool {
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+}
+
+// panicStack formats up to depth frames of the panicking goroutine's stack,
+// starting at the frame that panicked
+func panicStack(skip, depth int) string {
+	pcs := make([]uintptr, depth+8)
+	n := runtime.Callers(skip, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var b strings.Builder
+	for i := 0; i < depth; {
+		frame, more := frames.Next()
+		// Frames of the runtime's panic machinery carry no information
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			b.WriteString(frame.Function)
+			b.WriteString("\n\t")
+			b.WriteString(frame.File)
+			b.WriteByte(':')
+			b.WriteString(strconv.Itoa(frame.Line))
+			b.WriteByte('\n')
+			i++
+		}
+		if !more {
+			break
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,124 @@
+package httpx
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/responsex"
+	"github.com/gostratum/tracingx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// erroredSpan records the error and events logged on it
+type erroredSpan struct {
+	stubSpan
+	err    error
+	events []tracingx.Field
+}
+
+func (s *erroredSpan) SetError(err error)                 { s.err = err }
+func (s *erroredSpan) LogFields(fields ...tracingx.Field) { s.events = append(s.events, fields...) }
+
+func TestPanicRecoveryMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(t *testing.T, cfg RecoveryConfig) (*gin.Engine, *observer.ObservedLogs, *recordingMetrics, *erroredSpan) {
+		core, logs := observer.New(zap.DebugLevel)
+		metrics := newRecordingMetrics()
+		span := &erroredSpan{}
+
+		engine := gin.New()
+		engine.Use(RequestIDMiddleware())
+		engine.Use(func(c *gin.Context) {
+			ctx := c.Request.Context()
+			span.ctx = ctx
+			c.Request = c.Request.WithContext(tracingx.ContextWithSpan(ctx, span))
+		})
+		engine.Use(PanicRecoveryMiddleware(logx.ProvideAdapter(zap.New(core)), cfg, metrics))
+		engine.GET("/panic", func(c *gin.Context) { panic("boom") })
+		engine.GET("/partial", func(c *gin.Context) {
+			c.String(http.StatusOK, "partial")
+			panic("late boom")
+		})
+		engine.GET("/gone", func(c *gin.Context) {
+			panic(&net.OpError{Op: "write", Net: "tcp", Err: os.NewSyscallError("write", syscall.EPIPE)})
+		})
+		engine.GET("/abort", func(c *gin.Context) { panic(http.ErrAbortHandler) })
+		return engine, logs, metrics, span
+	}
+
+	get := func(engine *gin.Engine, target string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		return w
+	}
+
+	t.Run("answers with an error envelope and records the panic", func(t *testing.T) {
+		engine, logs, metrics, span := newEngine(t, RecoveryConfig{StackDepth: defaultStackDepth})
+		w := get(engine, "/panic")
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		var env responsex.Envelope[any]
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+		assert.False(t, env.Ok)
+		require.NotNil(t, env.Error)
+		assert.Equal(t, "internal_error", env.Error.Code)
+		require.NotNil(t, env.Meta)
+		assert.Equal(t, w.Header().Get("X-Request-ID"), env.Meta.RequestID)
+
+		entries := logs.FilterMessage("http panic recovered").All()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "boom", fields["error"])
+		stack := fields["stack"].(string)
+		assert.True(t, strings.HasPrefix(stack, "github.com/gostratum/httpx.TestPanicRecoveryMiddleware"), stack)
+
+		assert.Equal(t, float64(1), metrics.counter("http_panics_total"))
+		require.Error(t, span.err)
+		assert.Contains(t, span.err.Error(), "boom")
+		assert.Contains(t, span.events, tracingx.Field{Key: "event", Value: "panic"})
+	})
+
+	t.Run("limits the stack depth", func(t *testing.T) {
+		engine, logs, _, _ := newEngine(t, RecoveryConfig{StackDepth: 2})
+		get(engine, "/panic")
+		stack := logs.All()[0].ContextMap()["stack"].(string)
+		assert.Equal(t, 2, strings.Count(stack, "\n\t"))
+
+		engine, logs, _, _ = newEngine(t, RecoveryConfig{StackDepth: -1})
+		get(engine, "/panic")
+		assert.NotContains(t, logs.All()[0].ContextMap(), "stack")
+	})
+
+	t.Run("keeps a response that was already written", func(t *testing.T) {
+		engine, _, metrics, _ := newEngine(t, RecoveryConfig{})
+		w := get(engine, "/partial")
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "partial", w.Body.String())
+		assert.Equal(t, float64(1), metrics.counter("http_panics_total"))
+	})
+
+	t.Run("handles broken pipes quietly", func(t *testing.T) {
+		engine, logs, metrics, _ := newEngine(t, RecoveryConfig{})
+		get(engine, "/gone")
+		assert.Zero(t, logs.FilterMessage("http panic recovered").Len())
+		assert.Equal(t, 1, logs.FilterMessage("httpx: client connection closed").Len())
+		assert.Zero(t, metrics.counter("http_panics_total"))
+	})
+
+	t.Run("re-raises http.ErrAbortHandler", func(t *testing.T) {
+		engine, _, _, _ := newEngine(t, RecoveryConfig{})
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() { get(engine, "/abort") })
+	})
+}
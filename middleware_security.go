@@ -0,0 +1,265 @@
+package httpx
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/metricsx"
+)
+
+// CSPNonceKey is the gin context key holding the per-request CSP nonce
+const CSPNonceKey = "httpx.csp_nonce"
+
+// cspNoncePlaceholder is replaced with the per-request nonce in header values
+const cspNoncePlaceholder = "{nonce}"
+
+// SecurityHeadersConfig contains security response header configuration
+type SecurityHeadersConfig struct {
+	// Enabled turns on the security headers middleware
+	Enabled bool `mapstructure:"enabled"`
+
+	// HSTSMaxAge is the Strict-Transport-Security max-age; zero omits the header
+	HSTSMaxAge time.Duration `mapstructure:"hsts_max_age" default:"8760h"`
+
+	// HSTSIncludeSubdomains adds includeSubDomains to Strict-Transport-Security
+	HSTSIncludeSubdomains bool `mapstructure:"hsts_include_subdomains"`
+
+	// HSTSPreload adds preload to Strict-Transport-Security
+	HSTSPreload bool `mapstructure:"hsts_preload"`
+
+	// ContentTypeOptions is the X-Content-Type-Options value
+	ContentTypeOptions string `mapstructure:"content_type_options" default:"nosniff"`
+
+	// FrameOptions is the X-Frame-Options value
+	FrameOptions string `mapstructure:"frame_options" default:"DENY"`
+
+	// ReferrerPolicy is the Referrer-Policy value
+	ReferrerPolicy string `mapstructure:"referrer_policy" default:"strict-origin-when-cross-origin"`
+
+	// PermissionsPolicy is the Permissions-Policy value; empty omits the header
+	PermissionsPolicy string `mapstructure:"permissions_policy"`
+
+	// ContentSecurityPolicy is the CSP value; "{nonce}" is replaced with a per-request nonce
+	ContentSecurityPolicy string `mapstructure:"content_security_policy"`
+
+	// CSPReportOnly sends the policy as Content-Security-Policy-Report-Only
+	CSPReportOnly bool `mapstructure:"csp_report_only"`
+
+	// CSPReportPath registers a built-in report collection endpoint and adds it as report-uri
+	CSPReportPath string `mapstructure:"csp_report_path"`
+
+	// Routes overrides headers for matching routes; the first match wins
+	Routes []RouteSecurityHeaders `mapstructure:"routes"`
+}
+
+// RouteSecurityHeaders overrides security headers for matching routes
+type RouteSecurityHeaders struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// Headers maps header names to values; an empty value removes the header
+	Headers map[string]string `mapstructure:"headers"`
+}
+
+// cspHeader returns the CSP header name for the configured mode
+func (c SecurityHeadersConfig) cspHeader() string {
+	if c.CSPReportOnly {
+		return "Content-Security-Policy-Report-Only"
+	}
+	return "Content-Security-Policy"
+}
+
+// headers builds the base header set from configuration
+func (c SecurityHeadersConfig) headers() map[string]string {
+	h := make(map[string]string)
+	if c.HSTSMaxAge > 0 {
+		v := "max-age=" + strconv.FormatInt(int64(c.HSTSMaxAge.Seconds()), 10)
+		if c.HSTSIncludeSubdomains {
+			v += "; includeSubDomains"
+		}
+		if c.HSTSPreload {
+			v += "; preload"
+		}
+		h["Strict-Transport-Security"] = v
+	}
+	if c.ContentTypeOptions != "" {
+		h["X-Content-Type-Options"] = c.ContentTypeOptions
+	}
+	if c.FrameOptions != "" {
+		h["X-Frame-Options"] = c.FrameOptions
+	}
+	if c.ReferrerPolicy != "" {
+		h["Referrer-Policy"] = c.ReferrerPolicy
+	}
+	if c.PermissionsPolicy != "" {
+		h["Permissions-Policy"] = c.PermissionsPolicy
+	}
+	if csp := c.ContentSecurityPolicy; csp != "" {
+		if c.CSPReportPath != "" && !strings.Contains(csp, "report-uri") {
+			csp = strings.TrimRight(strings.TrimSpace(csp), ";") + "; report-uri " + c.CSPReportPath
+		}
+		h[c.cspHeader()] = csp
+	}
+	return h
+}
+
+// CSPNonce returns the CSP nonce generated for the request, for use in templates
+func CSPNonce(c *gin.Context) string {
+	return c.GetString(CSPNonceKey)
+}
+
+// newNonce returns a random base64 nonce
+func newNonce() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return base64.StdEncoding.EncodeToString(b)
+}
+
+// SecurityHeadersMiddleware sets security response headers before the handler runs.
+// When a header value contains "{nonce}", a per-request nonce is generated,
+// substituted into the value and stored in the gin context under CSPNonceKey.
+func SecurityHeadersMiddleware(cfg SecurityHeadersConfig) (gin.HandlerFunc, error) {
+	base := cfg.headers()
+
+	routes := make([]routeMatcher, len(cfg.Routes))
+	overrides := make([]map[string]string, len(cfg.Routes))
+	for i, r := range cfg.Routes {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		routes[i] = m
+		overrides[i] = make(map[string]string, len(r.Headers))
+		for k, v := range r.Headers {
+			overrides[i][http.CanonicalHeaderKey(k)] = v
+		}
+	}
+
+	return func(c *gin.Context) {
+		headers := base
+		for i, m := range routes {
+			if m.match(c.Request.Method, c.FullPath()) {
+				headers = make(map[string]string, len(base)+len(overrides[i]))
+				for k, v := range base {
+					headers[k] = v
+				}
+				for k, v := range overrides[i] {
+					headers[k] = v
+				}
+				break
+			}
+		}
+
+		var nonce string
+		for k, v := range headers {
+			if v == "" {
+				continue
+			}
+			if strings.Contains(v, cspNoncePlaceholder) {
+				if nonce == "" {
+					nonce = newNonce()
+					c.Set(CSPNonceKey, nonce)
+				}
+				v = strings.ReplaceAll(v, cspNoncePlaceholder, nonce)
+			}
+			c.Header(k, v)
+		}
+
+		c.Next()
+	}, nil
+}
+
+// cspReport is the legacy report-uri payload
+type cspReport struct {
+	Body map[string]any `json:"csp-report"`
+}
+
+// registerCSPReportRoute registers the built-in CSP violation report endpoint.
+// Reports are logged at warn level and counted when metrics are available.
+func registerCSPReportRoute(e *gin.Engine, cfg SecurityHeadersConfig, log logx.Logger, metrics metricsx.Metrics) {
+	var reports metricsx.Counter
+	if metrics != nil {
+		reports = metrics.Counter(
+			"http_csp_reports_total",
+			metricsx.WithHelp("Total number of Content-Security-Policy violation reports received"),
+			metricsx.WithLabels("directive"),
+		)
+	}
+
+	e.POST(cfg.CSPReportPath, func(c *gin.Context) {
+		data, err := io.ReadAll(io.LimitReader(c.Request.Body, 64<<10))
+		if err != nil {
+			c.Status(http.StatusBadRequest)
+			return
+		}
+
+		// Accept both report-uri ({"csp-report": {...}}) and Reporting API ([{"body": {...}}]) payloads
+		var bodies []map[string]any
+		var legacy cspReport
+		var batch []struct {
+			Body map[string]any `json:"body"`
+		}
+		if json.Unmarshal(data, &legacy) == nil && legacy.Body != nil {
+			bodies = append(bodies, legacy.Body)
+		} else if json.Unmarshal(data, &batch) == nil {
+			for _, r := range batch {
+				bodies = append(bodies, r.Body)
+			}
+		}
+
+		for _, b := range bodies {
+			directive := firstString(b, "effectiveDirective", "effective-directive", "violated-directive")
+			log.Warn("csp violation",
+				logx.String("directive", directive),
+				logx.String("blocked_uri", firstString(b, "blocked-uri", "blockedURL")),
+				logx.String("document_uri", firstString(b, "document-uri", "documentURL")),
+				logx.Bool("report_only", cfg.CSPReportOnly),
+			)
+			if reports != nil {
+				reports.Inc(cspDirectiveLabel(directive))
+			}
+		}
+
+		c.Status(http.StatusNoContent)
+	})
+}
+
+// cspDirectives are the directive names used as http_csp_reports_total labels
+var cspDirectives = map[string]bool{
+	"base-uri": true, "child-src": true, "connect-src": true, "default-src": true,
+	"font-src": true, "form-action": true, "frame-ancestors": true, "frame-src": true,
+	"img-src": true, "manifest-src": true, "media-src": true, "object-src": true,
+	"script-src": true, "script-src-attr": true, "script-src-elem": true,
+	"style-src": true, "style-src-attr": true, "style-src-elem": true,
+	"worker-src": true, "require-trusted-types-for": true, "trusted-types": true,
+	"upgrade-insecure-requests": true, "sandbox": true,
+}
+
+// cspDirectiveLabel maps a reported directive to a known directive name, or
+// "other", so unauthenticated reports cannot grow the label set. Legacy reports
+// may carry the directive with its source list ("script-src 'self'").
+func cspDirectiveLabel(directive string) string {
+	name, _, _ := strings.Cut(strings.TrimSpace(directive), " ")
+	name = strings.ToLower(name)
+	if cspDirectives[name] {
+		return name
+	}
+	return "other"
+}
+
+// firstString returns the first string value found under the given keys
+func firstString(m map[string]any, keys ...string) string {
+	for _, k := range keys {
+		if s, ok := m[k].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
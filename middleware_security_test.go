@@ -0,0 +1,140 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testSecurityHeadersConfig() SecurityHeadersConfig {
+	return SecurityHeadersConfig{
+		Enabled:               true,
+		HSTSMaxAge:            365 * 24 * time.Hour,
+		HSTSIncludeSubdomains: true,
+		ContentTypeOptions:    "nosniff",
+		FrameOptions:          "DENY",
+		ReferrerPolicy:        "no-referrer",
+		PermissionsPolicy:     "geolocation=()",
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'",
+	}
+}
+
+func TestSecurityHeadersMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("sets configured headers and a per-request nonce", func(t *testing.T) {
+		mw, err := SecurityHeadersMiddleware(testSecurityHeadersConfig())
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(mw)
+		var nonce string
+		engine.GET("/page", func(c *gin.Context) {
+			nonce = CSPNonce(c)
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/page", nil)
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, "max-age=31536000; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"))
+		assert.Equal(t, "geolocation=()", w.Header().Get("Permissions-Policy"))
+		require.NotEmpty(t, nonce)
+		assert.Equal(t, "default-src 'self'; script-src 'self' 'nonce-"+nonce+"'", w.Header().Get("Content-Security-Policy"))
+
+		w2 := httptest.NewRecorder()
+		engine.ServeHTTP(w2, req)
+		assert.NotEqual(t, w.Header().Get("Content-Security-Policy"), w2.Header().Get("Content-Security-Policy"))
+	})
+
+	t.Run("applies route overrides", func(t *testing.T) {
+		cfg := testSecurityHeadersConfig()
+		cfg.Routes = []RouteSecurityHeaders{
+			{
+				RouteMatch: RouteMatch{URLPattern: "^/embed"},
+				Headers: map[string]string{
+					"x-frame-options":         "",
+					"content-security-policy": "frame-ancestors https://partner.example",
+				},
+			},
+		}
+		mw, err := SecurityHeadersMiddleware(cfg)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(mw)
+		engine.GET("/embed/widget", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/embed/widget", nil)
+		engine.ServeHTTP(w, req)
+
+		assert.Empty(t, w.Header().Get("X-Frame-Options"))
+		assert.Equal(t, "frame-ancestors https://partner.example", w.Header().Get("Content-Security-Policy"))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+	})
+
+	t.Run("report-only mode adds the report endpoint", func(t *testing.T) {
+		cfg := testSecurityHeadersConfig()
+		cfg.CSPReportOnly = true
+		cfg.CSPReportPath = "/csp-report"
+		mw, err := SecurityHeadersMiddleware(cfg)
+		require.NoError(t, err)
+
+		metrics := newRecordingMetrics()
+		engine := gin.New()
+		engine.Use(mw)
+		registerCSPReportRoute(engine, cfg, logx.NewNoopLogger(), metrics)
+		engine.GET("/page", func(c *gin.Context) {
+			c.Status(http.StatusOK)
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/page", nil)
+		engine.ServeHTTP(w, req)
+
+		assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+		assert.True(t, strings.HasSuffix(w.Header().Get("Content-Security-Policy-Report-Only"), "; report-uri /csp-report"))
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/csp-report", strings.NewReader(
+			`{"csp-report":{"document-uri":"https://app.example/","violated-directive":"script-src","blocked-uri":"inline"}}`))
+		req.Header.Set("Content-Type", "application/csp-report")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/csp-report", strings.NewReader(
+			`[{"type":"csp-violation","body":{"documentURL":"https://app.example/","effectiveDirective":"img-src"}}]`))
+		req.Header.Set("Content-Type", "application/reports+json")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/csp-report", strings.NewReader(
+			`{"csp-report":{"violated-directive":"Script-Src 'self'"}}`))
+		engine.ServeHTTP(w, req)
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("POST", "/csp-report", strings.NewReader(
+			`{"csp-report":{"violated-directive":"x-attacker-controlled-1234"}}`))
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, 4.0, metrics.counter("http_csp_reports_total"))
+		assert.Equal(t, 2.0, metrics.counterWith("http_csp_reports_total", "script-src"))
+		assert.Equal(t, 1.0, metrics.counterWith("http_csp_reports_total", "img-src"))
+		assert.Equal(t, 1.0, metrics.counterWith("http_csp_reports_total", "other"), "unknown directives share one label")
+	})
+}
@@ -0,0 +1,182 @@
+package httpx
+
+import (
+	"bytes"
+	"runtime"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
+	"github.com/gostratum/metricsx"
+	"github.com/gostratum/tracingx"
+)
+
+// SlowRequestConfig contains slow request detection configuration
+type SlowRequestConfig struct {
+	// Threshold marks requests taking at least this long as slow; zero disables the default
+	Threshold time.Duration `mapstructure:"threshold"`
+
+	// Routes overrides the threshold for matching routes; the first match wins
+	Routes []RouteSlowThreshold `mapstructure:"routes"`
+
+	// StackThreshold snapshots the handler goroutine's stack when a request is
+	// still running after this long; zero disables stack snapshots
+	StackThreshold time.Duration `mapstructure:"stack_threshold"`
+}
+
+// RouteSlowThreshold sets the slow request threshold for matching routes
+type RouteSlowThreshold struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// Threshold is the route threshold; zero disables detection for the route
+	Threshold time.Duration `mapstructure:"threshold"`
+}
+
+// enabled reports whether any threshold is configured
+func (c SlowRequestConfig) enabled() bool {
+	return c.Threshold > 0 || len(c.Routes) > 0
+}
+
+// maxStackBytes bounds the goroutine dump a stack snapshot is extracted from
+const maxStackBytes = 8 << 20
+
+// SlowRequestMiddleware detects requests exceeding the configured threshold. A
+// slow request is logged at warn with its request context, tagged http.slow on
+// the active span and counted in http_slow_requests_total by method and route.
+// It is also flagged on the context so that an AccessLogMiddleware registered
+// before this one logs it even when sampled out.
+//
+// With a StackThreshold, the handler goroutine's stack is captured once the
+// request has been running that long and added to the warning. Snapshots dump
+// all goroutines, so only one is taken at a time.
+func SlowRequestMiddleware(log logx.Logger, cfg SlowRequestConfig, metrics metricsx.Metrics) (gin.HandlerFunc, error) {
+	routes := make([]routeMatcher, len(cfg.Routes))
+	for i, r := range cfg.Routes {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		routes[i] = m
+	}
+
+	var slow metricsx.Counter
+	if metrics != nil {
+		slow = metrics.Counter(
+			"http_slow_requests_total",
+			metricsx.WithHelp("Total number of requests exceeding the slow request threshold"),
+			metricsx.WithLabels("method", "path"),
+		)
+	}
+
+	resolve := func(c *gin.Context) time.Duration {
+		for i, m := range routes {
+			if m.match(c.Request.Method, c.FullPath()) {
+				return cfg.Routes[i].Threshold
+			}
+		}
+		return cfg.Threshold
+	}
+
+	var snapshotting atomic.Bool
+
+	return func(c *gin.Context) {
+		threshold := resolve(c)
+		if threshold <= 0 {
+			c.Next()
+			return
+		}
+
+		var stack atomic.Pointer[string]
+		if cfg.StackThreshold > 0 {
+			gid := goroutineID()
+			timer := time.AfterFunc(cfg.StackThreshold, func() {
+				if !snapshotting.CompareAndSwap(false, true) {
+					return
+				}
+				defer snapshotting.Store(false)
+				if s := goroutineStack(gid); s != "" {
+					stack.Store(&s)
+				}
+			})
+			defer timer.Stop()
+		}
+
+		start := time.Now()
+		c.Next()
+		dur := time.Since(start)
+		if dur < threshold {
+			return
+		}
+
+		c.Set(slowRequestKey, true)
+		ctx := c.Request.Context()
+		if slow != nil {
+			slow.Inc(c.Request.Method, c.FullPath())
+		}
+
+		fields := []logx.Field{
+			logx.String("rid", RequestIDFromContext(ctx)),
+			logx.String("method", c.Request.Method),
+			logx.String("path", c.FullPath()),
+			logx.String("url", c.Request.URL.RequestURI()),
+			logx.Int("status", c.Writer.Status()),
+			logx.Duration("dur", dur),
+			logx.Duration("threshold", threshold),
+			logx.String("ip", c.ClientIP()),
+			logx.String("user_agent", c.Request.UserAgent()),
+		}
+		if p, ok := authx.PrincipalFromContext(ctx); ok {
+			fields = append(fields, logx.String("sub", p.Subject))
+		}
+		if span := tracingx.SpanFromContext(ctx); span != nil {
+			span.SetTag("http.slow", true)
+			span.SetTag("http.slow_threshold_ms", threshold.Milliseconds())
+			if span.TraceID() != "" {
+				fields = append(fields, logx.String("trace_id", span.TraceID()))
+			}
+		}
+		if s := stack.Load(); s != nil {
+			fields = append(fields, logx.String("stack", *s))
+		}
+		log.Warn("httpx: slow request", fields...)
+	}, nil
+}
+
+// goroutineID returns the header prefix identifying the calling goroutine in
+// stack dumps, e.g. "goroutine 42 ["
+func goroutineID() []byte {
+	buf := make([]byte, 64)
+	buf = buf[:runtime.Stack(buf, false)]
+	if i := bytes.IndexByte(buf, '['); i > 0 {
+		return buf[:i+1]
+	}
+	return nil
+}
+
+// goroutineStack returns the stack of the goroutine identified by gid, or
+// empty when it has exited or the dump is too large
+func goroutineStack(gid []byte) string {
+	if gid == nil {
+		return ""
+	}
+	buf := make([]byte, 64<<10)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			buf = buf[:n]
+			break
+		}
+		if len(buf) >= maxStackBytes {
+			return ""
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+	for _, g := range bytes.Split(buf, []byte("\n\n")) {
+		if bytes.HasPrefix(g, gid) {
+			return string(g)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,118 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/core/logx"
+	"github.com/gostratum/tracingx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+// taggedSpan records the tags set on it
+type taggedSpan struct {
+	stubSpan
+	mu   sync.Mutex
+	tags map[string]any
+}
+
+func (s *taggedSpan) SetTag(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tags[key] = value
+}
+
+func TestSlowRequestMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(t *testing.T, cfg SlowRequestConfig) (*gin.Engine, *observer.ObservedLogs, *recordingMetrics, *taggedSpan) {
+		core, logs := observer.New(zap.InfoLevel)
+		metrics := newRecordingMetrics()
+		mw, err := SlowRequestMiddleware(logx.ProvideAdapter(zap.New(core)), cfg, metrics)
+		require.NoError(t, err)
+
+		span := &taggedSpan{tags: map[string]any{}}
+		engine := gin.New()
+		engine.Use(func(c *gin.Context) {
+			ctx := c.Request.Context()
+			span.ctx = ctx
+			c.Request = c.Request.WithContext(tracingx.ContextWithSpan(ctx, span))
+		})
+		engine.Use(mw)
+		engine.GET("/reports/:id", func(c *gin.Context) {
+			time.Sleep(30 * time.Millisecond)
+			c.Status(http.StatusOK)
+		})
+		engine.GET("/fast", func(c *gin.Context) { c.Status(http.StatusOK) })
+		return engine, logs, metrics, span
+	}
+
+	get := func(engine *gin.Engine, target string) {
+		engine.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+
+	t.Run("logs, tags and counts slow requests", func(t *testing.T) {
+		engine, logs, metrics, span := newEngine(t, SlowRequestConfig{Threshold: 10 * time.Millisecond})
+		get(engine, "/fast")
+		get(engine, "/reports/7?format=pdf")
+
+		entries := logs.FilterMessage("httpx: slow request").All()
+		require.Len(t, entries, 1)
+		fields := entries[0].ContextMap()
+		assert.Equal(t, "/reports/:id", fields["path"])
+		assert.Equal(t, "/reports/7?format=pdf", fields["url"])
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", fields["trace_id"])
+		assert.NotContains(t, fields, "stack")
+		assert.Equal(t, true, span.tags["http.slow"])
+		assert.Equal(t, float64(1), metrics.counter("http_slow_requests_total"))
+	})
+
+	t.Run("uses route thresholds", func(t *testing.T) {
+		engine, logs, _, _ := newEngine(t, SlowRequestConfig{
+			Threshold: 10 * time.Millisecond,
+			Routes:    []RouteSlowThreshold{{RouteMatch: RouteMatch{URLPattern: "^/reports/"}, Threshold: time.Second}},
+		})
+		get(engine, "/reports/7")
+		assert.Zero(t, logs.Len())
+	})
+
+	t.Run("captures the handler stack for very slow requests", func(t *testing.T) {
+		engine, logs, _, _ := newEngine(t, SlowRequestConfig{Threshold: 10 * time.Millisecond, StackThreshold: 5 * time.Millisecond})
+		get(engine, "/reports/7")
+
+		entries := logs.FilterMessage("httpx: slow request").All()
+		require.Len(t, entries, 1)
+		stack, _ := entries[0].ContextMap()["stack"].(string)
+		assert.Contains(t, stack, "time.Sleep")
+		assert.Contains(t, stack, "TestSlowRequestMiddleware")
+	})
+
+	t.Run("rejects invalid route patterns", func(t *testing.T) {
+		_, err := SlowRequestMiddleware(logx.NewNoopLogger(), SlowRequestConfig{
+			Routes: []RouteSlowThreshold{{RouteMatch: RouteMatch{URLPattern: "("}}},
+		}, nil)
+		assert.Error(t, err)
+	})
+}
+
+func TestGoroutineStack(t *testing.T) {
+	gid := goroutineID()
+	require.NotNil(t, gid)
+
+	var stack string
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stack = goroutineStack(gid)
+	}()
+	<-done
+	assert.Contains(t, stack, "TestGoroutineStack")
+	assert.Empty(t, goroutineStack([]byte("goroutine 0 [")), "unknown goroutines have no stack")
+}
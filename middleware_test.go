@@ -8,7 +8,9 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/responsex"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestNewEngine(t *testing.T) {
@@ -127,6 +129,24 @@ func TestRequestIDMiddleware(t *testing.T) {
 		assert.Equal(t, expectedID, actualID)
 		assert.Equal(t, expectedID, w.Header().Get("X-Request-ID"))
 	})
+
+	t.Run("shares the ID with responsex envelopes", func(t *testing.T) {
+		engine := gin.New()
+		engine.Use(RequestIDMiddleware(), responsex.MetaMiddleware("v1"))
+
+		var fromContext string
+		engine.GET("/test", func(c *gin.Context) {
+			fromContext = RequestIDFromContext(c.Request.Context())
+			responsex.OK(c, "ok", nil)
+		})
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test", nil))
+
+		require.NotEmpty(t, fromContext)
+		assert.Equal(t, []string{fromContext}, w.Header().Values("X-Request-ID"))
+		assert.Contains(t, w.Body.String(), `"request_id":"`+fromContext+`"`)
+	})
 }
 
 func TestLoggingMiddleware(t *testing.T) {
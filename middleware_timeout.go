@@ -0,0 +1,276 @@
+package httpx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/responsex"
+)
+
+// TimeoutConfig contains request timeout configuration
+type TimeoutConfig struct {
+	// Default is the timeout applied to routes without a specific rule; zero disables it
+	Default time.Duration `mapstructure:"default"`
+
+	// Header is the incoming header clients use to shorten the deadline; empty disables it
+	Header string `mapstructure:"header" default:"X-Request-Timeout"`
+
+	// Routes overrides the default timeout for matching routes; the first match wins
+	Routes []RouteTimeout `mapstructure:"routes"`
+}
+
+// RouteTimeout sets the timeout for matching routes
+type RouteTimeout struct {
+	RouteMatch `mapstructure:",squash"`
+
+	// Timeout is the route timeout; zero disables the server-side timeout for the route
+	Timeout time.Duration `mapstructure:"timeout"`
+}
+
+// enabled reports whether any server-side timeout is configured
+func (c TimeoutConfig) enabled() bool {
+	return c.Default > 0 || len(c.Routes) > 0
+}
+
+// RemainingTimeout returns the time left before the request deadline.
+// Downstream calls can use it to propagate the budget, e.g. as an X-Request-Timeout header.
+func RemainingTimeout(ctx context.Context) (time.Duration, bool) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		return 0, false
+	}
+	return time.Until(deadline), true
+}
+
+// parseTimeoutHeader parses a Go duration ("1.5s") or a plain number of milliseconds
+func parseTimeoutHeader(v string) (time.Duration, bool) {
+	v = strings.TrimSpace(v)
+	if v == "" {
+		return 0, false
+	}
+	if d, err := time.ParseDuration(v); err == nil && d > 0 {
+		return d, true
+	}
+	if ms, err := strconv.ParseInt(v, 10, 64); err == nil && ms > 0 {
+		return time.Duration(ms) * time.Millisecond, true
+	}
+	return 0, false
+}
+
+// TimeoutMiddleware sets a deadline on the request context and answers with a
+// 504 error envelope when it expires. Writes from the handler after the deadline
+// are discarded. Clients may shorten, but never extend, the deadline via the
+// configured header.
+func TimeoutMiddleware(cfg TimeoutConfig) (gin.HandlerFunc, error) {
+	routes := make([]routeMatcher, len(cfg.Routes))
+	for i, r := range cfg.Routes {
+		m, err := r.compile()
+		if err != nil {
+			return nil, err
+		}
+		routes[i] = m
+	}
+
+	resolve := func(c *gin.Context) time.Duration {
+		d := cfg.Default
+		for i, m := range routes {
+			if m.match(c.Request.Method, c.FullPath()) {
+				d = cfg.Routes[i].Timeout
+				break
+			}
+		}
+		if cfg.Header != "" {
+			if hd, ok := parseTimeoutHeader(c.GetHeader(cfg.Header)); ok && (d <= 0 || hd < d) {
+				d = hd
+			}
+		}
+		return d
+	}
+
+	return func(c *gin.Context) {
+		d := resolve(c)
+		if d <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), d)
+		defer cancel()
+		c.Request = c.Request.WithContext(ctx)
+
+		// The timer builds the 504 body from a snapshot: the handler goroutine may
+		// reassign c.Request (e.g. auth middleware) while the timer runs
+		snapshot := c.Copy()
+		deadline, _ := ctx.Deadline()
+		tw := newTimeoutWriter(c.Writer, deadline, func() []byte {
+			return responsex.ErrorBody(snapshot, "timeout", "request timed out", nil)
+		})
+		c.Writer = tw
+		fired := make(chan struct{})
+		timer := time.AfterFunc(time.Until(deadline), func() {
+			defer close(fired)
+			tw.timeout()
+		})
+
+		// Stop the timer in a defer so a panicking handler cannot leave it
+		// firing after the gin context has been recycled
+		defer func() {
+			// Wait for a timeout already in progress so it never outlives the context
+			if !timer.Stop() {
+				<-fired
+			}
+			timedOut := tw.finish()
+			c.Writer = tw.ResponseWriter
+			if timedOut {
+				c.Abort()
+			}
+		}()
+
+		c.Next()
+	}, nil
+}
+
+// timeoutWriter guards the response so that either the handler or the timeout
+// writes it, never both. Handler headers are staged in a private map until the
+// first write so the timeout can safely use the underlying headers. Handler
+// writes after the deadline write the 504 themselves, so a handler woken by the
+// expired context cannot win the race against the timer.
+type timeoutWriter struct {
+	gin.ResponseWriter
+
+	deadline time.Time
+	body     func() []byte
+
+	mu        sync.Mutex
+	header    http.Header
+	committed bool
+	timedOut  bool
+	done      bool
+}
+
+func newTimeoutWriter(w gin.ResponseWriter, deadline time.Time, body func() []byte) *timeoutWriter {
+	return &timeoutWriter{ResponseWriter: w, header: w.Header().Clone(), deadline: deadline, body: body}
+}
+
+// expired times the response out once the deadline has passed and reports
+// whether it timed out; callers hold mu
+func (w *timeoutWriter) expired() bool {
+	if !w.timedOut && !w.done && !time.Now().Before(w.deadline) {
+		w.writeTimeout()
+	}
+	return w.timedOut
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+// commit copies staged headers to the underlying writer; callers hold mu
+func (w *timeoutWriter) commit() {
+	if w.committed {
+		return
+	}
+	w.committed = true
+	dst := w.ResponseWriter.Header()
+	for k := range dst {
+		delete(dst, k)
+	}
+	for k, v := range w.header {
+		dst[k] = v
+	}
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		return
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		return
+	}
+	w.commit()
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *timeoutWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.commit()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		return 0, http.ErrHandlerTimeout
+	}
+	w.commit()
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.expired() {
+		return
+	}
+	w.commit()
+	w.ResponseWriter.Flush()
+}
+
+// timeout marks the response as timed out and writes the 504 body if the
+// handler has not started writing yet
+func (w *timeoutWriter) timeout() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.done || w.timedOut {
+		return
+	}
+	w.writeTimeout()
+}
+
+// writeTimeout marks the response as timed out and writes the 504 body unless
+// the handler already committed a response; callers hold mu
+func (w *timeoutWriter) writeTimeout() {
+	w.timedOut = true
+	if w.committed {
+		return
+	}
+	w.committed = true
+
+	body := w.body()
+	h := w.ResponseWriter.Header()
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(body)))
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	_, _ = w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+}
+
+// finish stops the timeout from writing and commits staged headers.
+// It reports whether the request timed out.
+func (w *timeoutWriter) finish() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.expired() {
+		w.commit()
+	}
+	w.done = true
+	return w.timedOut
+}
@@ -0,0 +1,136 @@
+package httpx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(t *testing.T, cfg TimeoutConfig) *gin.Engine {
+		mw, err := TimeoutMiddleware(cfg)
+		require.NoError(t, err)
+
+		engine := gin.New()
+		engine.Use(RequestIDMiddleware())
+		engine.Use(mw)
+		engine.GET("/slow", func(c *gin.Context) {
+			<-c.Request.Context().Done()
+			c.Header("X-Late", "true")
+			c.JSON(http.StatusOK, gin.H{"late": true})
+		})
+		engine.GET("/rewrap", func(c *gin.Context) {
+			// Auth middleware replaces the request while the deadline may fire
+			for c.Request.Context().Err() == nil {
+				c.Request = c.Request.WithContext(c.Request.Context())
+				time.Sleep(time.Millisecond)
+			}
+		})
+		engine.GET("/panic", func(c *gin.Context) { panic("boom") })
+		engine.GET("/fast", func(c *gin.Context) {
+			remaining, ok := RemainingTimeout(c.Request.Context())
+			c.Header("X-Has-Deadline", map[bool]string{true: "yes", false: "no"}[ok])
+			c.Header("X-Within-Budget", map[bool]string{true: "yes", false: "no"}[remaining <= time.Second])
+			c.JSON(http.StatusOK, gin.H{"ok": true})
+		})
+		return engine
+	}
+
+	t.Run("writes 504 envelope and drops late writes", func(t *testing.T) {
+		engine := newEngine(t, TimeoutConfig{Default: 20 * time.Millisecond})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/slow", nil)
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"timeout"`)
+		assert.NotContains(t, w.Body.String(), "late")
+		assert.Empty(t, w.Header().Get("X-Late"))
+		assert.NotEmpty(t, w.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("builds the 504 body without racing the handler", func(t *testing.T) {
+		engine := newEngine(t, TimeoutConfig{Default: 10 * time.Millisecond})
+
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/rewrap", nil))
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+		assert.Contains(t, w.Body.String(), w.Header().Get("X-Request-ID"))
+	})
+
+	t.Run("stops the timer when the handler panics", func(t *testing.T) {
+		engine := newEngine(t, TimeoutConfig{Default: 10 * time.Millisecond})
+		w := httptest.NewRecorder()
+		assert.Panics(t, func() { engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/panic", nil)) })
+		time.Sleep(30 * time.Millisecond)
+		assert.NotEqual(t, http.StatusGatewayTimeout, w.Code, "the timer must not fire after the handler returned")
+	})
+
+	t.Run("keeps handler response when it finishes in time", func(t *testing.T) {
+		engine := newEngine(t, TimeoutConfig{Default: time.Second})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/fast", nil)
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "yes", w.Header().Get("X-Has-Deadline"))
+		assert.Equal(t, "yes", w.Header().Get("X-Within-Budget"))
+	})
+
+	t.Run("route rule overrides default", func(t *testing.T) {
+		engine := newEngine(t, TimeoutConfig{
+			Default: time.Minute,
+			Routes: []RouteTimeout{
+				{RouteMatch: RouteMatch{Method: "GET", URLPattern: "^/slow$"}, Timeout: 10 * time.Millisecond},
+			},
+		})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/slow", nil)
+		engine.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	})
+
+	t.Run("client header shortens but never extends the deadline", func(t *testing.T) {
+		engine := newEngine(t, TimeoutConfig{Default: time.Minute, Header: "X-Request-Timeout"})
+
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/slow", nil)
+		req.Header.Set("X-Request-Timeout", "10ms")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+		engine = newEngine(t, TimeoutConfig{Default: time.Second, Header: "X-Request-Timeout"})
+		w = httptest.NewRecorder()
+		req, _ = http.NewRequest("GET", "/fast", nil)
+		req.Header.Set("X-Request-Timeout", "1h")
+		engine.ServeHTTP(w, req)
+		assert.Equal(t, "yes", w.Header().Get("X-Within-Budget"))
+	})
+}
+
+func TestParseTimeoutHeader(t *testing.T) {
+	d, ok := parseTimeoutHeader("1.5s")
+	assert.True(t, ok)
+	assert.Equal(t, 1500*time.Millisecond, d)
+
+	d, ok = parseTimeoutHeader("250")
+	assert.True(t, ok)
+	assert.Equal(t, 250*time.Millisecond, d)
+
+	_, ok = parseTimeoutHeader("soon")
+	assert.False(t, ok)
+
+	_, ok = parseTimeoutHeader("-1s")
+	assert.False(t, ok)
+}
@@ -1,10 +1,14 @@
 package httpx
 
 import (
+	"context"
+	"slices"
+
 	"github.com/gin-gonic/gin"
 	"github.com/gostratum/core"
 	"github.com/gostratum/core/configx"
 	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/authx"
 	"github.com/gostratum/metricsx"
 	"github.com/gostratum/tracingx"
 	"go.uber.org/fx"
@@ -30,12 +34,78 @@ func Module(opts ...Option) fx.Option {
 			return NewConfig(loader)
 		}),
 
+		// Provide the JWT verifier; nil unless a key source is configured under http.auth.jwt
+		fx.Provide(func(cfg Config) (*authx.JWTVerifier, error) {
+			if !cfg.Auth.JWT.Enabled() {
+				return nil, nil
+			}
+			return authx.NewJWTVerifier(cfg.Auth.JWT)
+		}),
+
+		// Provide the API key store holding the hashed keys from http.auth.api_key
+		fx.Provide(func(cfg Config) (*authx.StaticAPIKeyStore, error) {
+			return authx.NewStaticAPIKeyStore(cfg.Auth.APIKey.Keys)
+		}),
+
+		// Provide the route authorizer with the policies from http.auth.policies,
+		// authenticating with the configured JWT verifier and API keys and the
+		// API key hooks from WithAPIKeyOptions
+		fx.Provide(func(log logx.Logger, cfg Config, v *authx.JWTVerifier, keys *authx.StaticAPIKeyStore) (*Authorizer, error) {
+			a, err := NewAuthorizer(log, cfg.Auth.Policies)
+			if err != nil {
+				return nil, err
+			}
+			var modCfg moduleConfig
+			for _, o := range opts {
+				o(&modCfg)
+			}
+			a.Authenticate(configAuthenticators(cfg.Auth.Config, v, keys, modCfg.apiKeyOpts...)...)
+			return a, nil
+		}),
+
+		// Provide the IP filter; nil unless http.ip_filter is enabled
+		fx.Provide(func(log logx.Logger, cfg Config, obs ObservabilityParams) (*IPFilter, error) {
+			if !cfg.IPFilter.Enabled {
+				return nil, nil
+			}
+			return NewIPFilter(log, cfg.IPFilter, obs.Metrics)
+		}),
+
+		// Reload the IP list file while the application runs
+		fx.Invoke(func(lc fx.Lifecycle, f *IPFilter) {
+			if f == nil {
+				return
+			}
+			ctx, cancel := context.WithCancel(context.Background())
+			lc.Append(fx.Hook{
+				OnStart: func(context.Context) error {
+					go f.Watch(ctx)
+					return nil
+				},
+				OnStop: func(context.Context) error {
+					cancel()
+					return nil
+				},
+			})
+		}),
+
 		// Provide the log skipper function
 		fx.Provide(NewSkipper),
 
 		// Provide the Gin engine with all dependencies and options
-		fx.Provide(func(log logx.Logger, cfg Config, skip func(string, string) bool, obs ObservabilityParams) *gin.Engine {
-			return NewEngineWithObservability(log, cfg, skip, obs, opts...)
+		fx.Provide(func(log logx.Logger, cfg Config, skip func(string, string) bool, obs ObservabilityParams, f *IPFilter, a *Authorizer) *gin.Engine {
+			engineOpts := append(slices.Clip(opts), WithAuthorizer(a))
+			if f != nil {
+				engineOpts = append(engineOpts, WithIPFilter(f))
+			}
+			return NewEngineWithObservability(log, cfg, skip, obs, engineOpts...)
+		}),
+
+		// Expose the route listing with the policy protecting each route
+		fx.Invoke(func(e *gin.Engine, cfg Config, log logx.Logger, a *Authorizer) {
+			if cfg.Health.RoutesPath != "" {
+				registerRoutesListing(e, cfg, log, a)
+			}
 		}),
 
 		// Start the HTTP server as part of the application lifecycle
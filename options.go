@@ -1,12 +1,23 @@
 package httpx
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/authx"
+)
 
 // moduleConfig holds programmatic configuration for the HTTP module
 // Simple configuration values (strings, bools, numbers) should be in config YAML instead
 type moduleConfig struct {
 	extraMW []gin.HandlerFunc // Go functions - cannot be in YAML
 	info    *BuildInfo        // Build metadata - could be programmatic or config
+
+	idempotencyStore IdempotencyStore // Custom store for Idempotency-Key responses
+	cacheStore       CacheStore       // Custom store for cached responses
+	ipFilter         *IPFilter        // Reloadable IP filter shared with the module lifecycle
+	bodyCapture      *BodyCapture     // Body capture whose debug switch the caller controls
+	authorizer       *Authorizer      // Route authorizer shared with code-declared policies
+
+	apiKeyOpts []authx.APIKeyOption // Rate limit and last-used hooks for configured API keys
 }
 
 // Option configures the HTTP module
@@ -28,6 +39,53 @@ func WithInfo(b BuildInfo) Option {
 	}
 }
 
+// WithIdempotencyStore replaces the in-memory Idempotency-Key store,
+// e.g. with a shared store when running several replicas
+func WithIdempotencyStore(store IdempotencyStore) Option {
+	return func(s *moduleConfig) {
+		s.idempotencyStore = store
+	}
+}
+
+// WithCacheStore replaces the in-memory LRU response cache store
+func WithCacheStore(store CacheStore) Option {
+	return func(s *moduleConfig) {
+		s.cacheStore = store
+	}
+}
+
+// WithIPFilter uses an existing IP filter instead of building one from
+// http.ip_filter, so callers can keep a handle for Reload
+func WithIPFilter(f *IPFilter) Option {
+	return func(s *moduleConfig) {
+		s.ipFilter = f
+	}
+}
+
+// WithBodyCapture uses an existing body capture instead of building one from
+// http.request.logging.capture, so callers can flip its debug switch at runtime
+func WithBodyCapture(bc *BodyCapture) Option {
+	return func(s *moduleConfig) {
+		s.bodyCapture = bc
+	}
+}
+
+// WithAuthorizer enforces an existing authorizer on the engine instead of one
+// built from http.auth.policies, so policies added with Protect are enforced too
+func WithAuthorizer(a *Authorizer) Option {
+	return func(s *moduleConfig) {
+		s.authorizer = a
+	}
+}
+
+// WithAPIKeyOptions sets the rate limit and last-used hooks applied when the
+// route authorizer authenticates requests with keys from http.auth.api_key
+func WithAPIKeyOptions(opts ...authx.APIKeyOption) Option {
+	return func(s *moduleConfig) {
+		s.apiKeyOpts = append(s.apiKeyOpts, opts...)
+	}
+}
+
 // BuildInfo contains build metadata for the /actuator/info endpoint
 type BuildInfo struct {
 	Version string `json:"version"`
@@ -0,0 +1,66 @@
+package requestidx
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Generator creates new request IDs
+type Generator func() string
+
+// Generator names accepted by NewGenerator
+const (
+	GeneratorUUIDv4 = "uuidv4"
+	GeneratorUUIDv7 = "uuidv7"
+	GeneratorULID   = "ulid"
+)
+
+// NewGenerator returns the generator registered under name. UUIDv7 and ULID
+// IDs sort by creation time, which keeps log and index lookups local.
+func NewGenerator(name string) (Generator, error) {
+	switch strings.ToLower(name) {
+	case "", GeneratorUUIDv4:
+		return uuid.NewString, nil
+	case GeneratorUUIDv7:
+		return newUUIDv7, nil
+	case GeneratorULID:
+		return NewULID, nil
+	default:
+		return nil, fmt.Errorf("requestidx: unknown generator %q", name)
+	}
+}
+
+func newUUIDv7() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// crockford is the Crockford base32 alphabet used by ULIDs
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// NewULID returns a ULID: a 48-bit millisecond timestamp followed by 80 random
+// bits, encoded as 26 Crockford base32 characters.
+func NewULID() string {
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:8], uint64(time.Now().UnixMilli())<<16)
+	_, _ = rand.Read(b[6:])
+
+	// 128 bits are encoded as 26 5-bit groups with 2 leading zero bits
+	hi := binary.BigEndian.Uint64(b[:8])
+	lo := binary.BigEndian.Uint64(b[8:])
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(out[:])
+}
@@ -0,0 +1,137 @@
+// Package requestidx assigns request IDs and exposes them to handlers, loggers
+// and tracers. The ID is stored on the request context, so packages that only
+// see a context.Context can read it.
+package requestidx
+
+import (
+	"context"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultHeader is the header used when no header is configured
+const DefaultHeader = "X-Request-ID"
+
+// GinKey is the gin context key holding the request ID for handlers that read gin keys
+const GinKey = "X-Request-Id"
+
+type ctxKey struct{}
+
+// requestID is the value stored on the request context
+type requestID struct {
+	id     string
+	header string
+}
+
+// Config contains request ID configuration
+type Config struct {
+	// Header carries incoming request IDs and echoes the assigned ID in responses
+	Header string `mapstructure:"header" default:"X-Request-ID"`
+
+	// Generator creates IDs for requests without a valid one: uuidv4, uuidv7 or ulid
+	Generator string `mapstructure:"generator" default:"uuidv4"`
+
+	// MaxLength bounds incoming IDs; longer ones are replaced with a generated ID
+	MaxLength int `mapstructure:"max_length" default:"128"`
+
+	// IgnoreIncoming always generates a new ID instead of reusing valid IDs sent
+	// by clients; enable at the edge of untrusted networks
+	IgnoreIncoming bool `mapstructure:"ignore_incoming"`
+}
+
+// DefaultConfig returns the configuration used by Default
+func DefaultConfig() Config {
+	return Config{Header: DefaultHeader, Generator: GeneratorUUIDv4, MaxLength: 128}
+}
+
+// Assigner assigns request IDs according to a Config
+type Assigner struct {
+	cfg      Config
+	generate Generator
+}
+
+var defaultAssigner, _ = New(DefaultConfig())
+
+// Default returns the assigner used when none is configured
+func Default() *Assigner {
+	return defaultAssigner
+}
+
+// New creates an assigner, validating the generator name
+func New(cfg Config) (*Assigner, error) {
+	gen, err := NewGenerator(cfg.Generator)
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Header == "" {
+		cfg.Header = DefaultHeader
+	}
+	return &Assigner{cfg: cfg, generate: gen}, nil
+}
+
+// Middleware assigns a request ID to every request
+func (a *Assigner) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		a.Assign(c)
+		c.Next()
+	}
+}
+
+// Assign returns the request's ID, assigning one if no earlier middleware did.
+// A valid incoming header value is reused unless IgnoreIncoming is set; otherwise
+// a new ID is generated. The ID is stored on the request context and gin keys
+// and echoed in the response header.
+func (a *Assigner) Assign(c *gin.Context) string {
+	if id := FromContext(c.Request.Context()); id != "" {
+		return id
+	}
+
+	id := ""
+	if !a.cfg.IgnoreIncoming {
+		if in := c.GetHeader(a.cfg.Header); Valid(in, a.cfg.MaxLength) {
+			id = in
+		}
+	}
+	if id == "" {
+		id = a.generate()
+	}
+
+	c.Request = c.Request.WithContext(NewContext(c.Request.Context(), id, a.cfg.Header))
+	c.Set(GinKey, id)
+	c.Writer.Header().Set(a.cfg.Header, id)
+	return id
+}
+
+// Valid reports whether an incoming ID is non-empty, at most maxLength bytes
+// (when positive) and made of visible ASCII characters only, so it is safe to
+// echo in headers and logs.
+func Valid(id string, maxLength int) bool {
+	if id == "" || (maxLength > 0 && len(id) > maxLength) {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// NewContext returns a context carrying the request ID and the header it is sent in
+func NewContext(ctx context.Context, id, header string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, requestID{id: id, header: header})
+}
+
+// FromContext returns the request ID stored on ctx, or "" when there is none
+func FromContext(ctx context.Context) string {
+	v, _ := ctx.Value(ctxKey{}).(requestID)
+	return v.id
+}
+
+// HeaderFromContext returns the header name the request ID is sent in, or DefaultHeader
+func HeaderFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(ctxKey{}).(requestID); ok && v.header != "" {
+		return v.header
+	}
+	return DefaultHeader
+}
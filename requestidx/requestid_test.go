@@ -0,0 +1,95 @@
+package requestidx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerators(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+	}{
+		{GeneratorUUIDv4, `^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{GeneratorUUIDv7, `^[0-9a-f]{8}-[0-9a-f]{4}-7[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`},
+		{GeneratorULID, `^[0-7][0-9A-HJKMNP-TV-Z]{25}$`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gen, err := NewGenerator(tt.name)
+			require.NoError(t, err)
+			a, b := gen(), gen()
+			assert.Regexp(t, regexp.MustCompile(tt.pattern), a)
+			assert.NotEqual(t, a, b)
+		})
+	}
+
+	_, err := NewGenerator("snowflake")
+	assert.Error(t, err)
+}
+
+func TestULIDSortsByTime(t *testing.T) {
+	prev := NewULID()
+	for range 5 {
+		next := NewULID()
+		// IDs generated in the same millisecond share the timestamp prefix only
+		assert.LessOrEqual(t, prev[:10], next[:10])
+		prev = next
+	}
+}
+
+func TestAssigner(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	serve := func(t *testing.T, cfg Config, incoming string) (*httptest.ResponseRecorder, string) {
+		a, err := New(cfg)
+		require.NoError(t, err)
+
+		var seen string
+		engine := gin.New()
+		engine.Use(a.Middleware())
+		engine.GET("/", func(c *gin.Context) {
+			seen = FromContext(c.Request.Context())
+			assert.Equal(t, seen, c.GetString(GinKey))
+			assert.Equal(t, http.CanonicalHeaderKey(cfg.Header), http.CanonicalHeaderKey(HeaderFromContext(c.Request.Context())))
+			assert.Equal(t, seen, a.Assign(c), "assigning again keeps the ID")
+		})
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		if incoming != "" {
+			req.Header.Set(cfg.Header, incoming)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w, seen
+	}
+
+	t.Run("reuses valid incoming IDs", func(t *testing.T) {
+		w, id := serve(t, DefaultConfig(), "abc-123")
+		assert.Equal(t, "abc-123", id)
+		assert.Equal(t, "abc-123", w.Header().Get(DefaultHeader))
+	})
+
+	t.Run("replaces invalid or overlong IDs", func(t *testing.T) {
+		for _, incoming := range []string{"has space", "new\nline", strings.Repeat("a", 129)} {
+			_, id := serve(t, DefaultConfig(), incoming)
+			assert.NotEqual(t, incoming, id)
+			assert.Len(t, id, 36)
+		}
+	})
+
+	t.Run("uses the configured header and generator", func(t *testing.T) {
+		cfg := Config{Header: "X-Correlation-ID", Generator: GeneratorULID, MaxLength: 64, IgnoreIncoming: true}
+		w, id := serve(t, cfg, "client-chosen")
+		assert.Len(t, id, 26)
+		assert.Equal(t, id, w.Header().Get("X-Correlation-ID"))
+		assert.Empty(t, w.Header().Get(DefaultHeader))
+	})
+}
@@ -0,0 +1,138 @@
+package responsex
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const ctxETagModeKey = "responsex.etag_mode"
+
+// ETagMode selects how envelopes are tagged automatically
+type ETagMode int
+
+const (
+	// ETagNone disables automatic ETags; handlers may still call WithETag
+	ETagNone ETagMode = iota
+
+	// ETagStrong emits strong validators ("...")
+	ETagStrong
+
+	// ETagWeak emits weak validators (W/"..."), which survive response compression
+	ETagWeak
+)
+
+// ETagMiddleware enables automatic ETags for envelopes sent with OK and Created.
+// The tag is computed from the encoded envelope without its meta block, so it
+// only changes when data or pagination change. Matching If-None-Match on GET
+// and HEAD requests is answered with 304.
+func ETagMiddleware(mode ETagMode) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(ctxETagModeKey, mode)
+		c.Next()
+	}
+}
+
+// WithLastModified sets the Last-Modified header used for If-Modified-Since checks.
+func WithLastModified(c *gin.Context, t time.Time) {
+	if t.IsZero() {
+		return
+	}
+	c.Header("Last-Modified", t.UTC().Format(http.TimeFormat))
+}
+
+// CheckPreconditions evaluates conditional request headers against the current
+// state of a resource, before a handler reads or modifies it. On unsafe methods a
+// failed If-Match, If-None-Match or If-Unmodified-Since check is answered with
+// 412; on GET and HEAD a matching If-None-Match or If-Modified-Since is answered
+// with 304. It returns false when a response was written and the handler should stop.
+func CheckPreconditions(c *gin.Context, etag string, lastModified time.Time) bool {
+	h := c.Request.Header
+	lastModified = lastModified.Truncate(time.Second)
+
+	if isSafeMethod(c.Request.Method) {
+		WithETag(c, etag)
+		WithLastModified(c, lastModified)
+		if notModified(h, etag, lastModified) {
+			c.AbortWithStatus(http.StatusNotModified)
+			return false
+		}
+		return true
+	}
+
+	failed := false
+	if im := h.Get("If-Match"); im != "" {
+		failed = etag == "" || !etagMatches(im, etag, false)
+	} else if ius, err := http.ParseTime(h.Get("If-Unmodified-Since")); err == nil && !lastModified.IsZero() {
+		failed = lastModified.After(ius)
+	}
+	if inm := h.Get("If-None-Match"); !failed && inm != "" {
+		failed = (inm == "*" && etag != "") || (etag != "" && etagMatches(inm, etag, true))
+	}
+	if failed {
+		Error(c, http.StatusPreconditionFailed, "precondition_failed", "resource has been modified", nil)
+		c.Abort()
+		return false
+	}
+	return true
+}
+
+// notModified reports whether a safe request's validators match the representation
+func notModified(h http.Header, etag string, lastModified time.Time) bool {
+	if inm := h.Get("If-None-Match"); inm != "" {
+		return etag != "" && etagMatches(inm, etag, true)
+	}
+	if ims, err := http.ParseTime(h.Get("If-Modified-Since")); err == nil && !lastModified.IsZero() {
+		return !lastModified.After(ims)
+	}
+	return false
+}
+
+// etagMatches checks a comma-separated If-Match/If-None-Match list against etag.
+// Weak comparison ignores the W/ prefix; strong comparison never matches weak tags.
+func etagMatches(list, etag string, weak bool) bool {
+	for _, candidate := range strings.Split(list, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if weak {
+			if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		} else if candidate == etag && !strings.HasPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead
+}
+
+// autoETag computes the automatic ETag for an envelope, or "" when disabled
+func autoETag(c *gin.Context, env Envelope[any]) string {
+	v, ok := c.Get(ctxETagModeKey)
+	mode, _ := v.(ETagMode)
+	if !ok || mode == ETagNone {
+		return ""
+	}
+
+	env.Meta = nil
+	var buf bytes.Buffer
+	if err := encodeEnvelope(&buf, env); err != nil {
+		return ""
+	}
+	sum := sha256.Sum256(buf.Bytes())
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if mode == ETagWeak {
+		tag = "W/" + tag
+	}
+	return tag
+}
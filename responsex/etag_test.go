@@ -0,0 +1,136 @@
+package responsex
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutomaticETag(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	newEngine := func(mode ETagMode) *gin.Engine {
+		engine := gin.New()
+		engine.Use(MetaMiddleware("v1"), ETagMiddleware(mode))
+		engine.GET("/items/:id", func(c *gin.Context) {
+			OK(c, gin.H{"id": c.Param("id")}, nil)
+		})
+		engine.GET("/missing", func(c *gin.Context) {
+			Error(c, http.StatusNotFound, "not_found", "missing", nil)
+		})
+		return engine
+	}
+
+	get := func(engine *gin.Engine, path, ifNoneMatch string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", ifNoneMatch)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("strong tags are stable across requests and ignore meta", func(t *testing.T) {
+		engine := newEngine(ETagStrong)
+		first := get(engine, "/items/1", "")
+		time.Sleep(2 * time.Millisecond)
+		second := get(engine, "/items/1", "")
+
+		etag := first.Header().Get("ETag")
+		require.NotEmpty(t, etag)
+		assert.True(t, strings.HasPrefix(etag, `"`))
+		assert.Equal(t, etag, second.Header().Get("ETag"))
+		assert.NotEqual(t, etag, get(engine, "/items/2", "").Header().Get("ETag"))
+	})
+
+	t.Run("matching If-None-Match gets 304 without a body", func(t *testing.T) {
+		engine := newEngine(ETagWeak)
+		etag := get(engine, "/items/1", "").Header().Get("ETag")
+		require.True(t, strings.HasPrefix(etag, `W/"`))
+
+		w := get(engine, "/items/1", `"other", `+strings.TrimPrefix(etag, "W/"))
+		assert.Equal(t, http.StatusNotModified, w.Code)
+		assert.Empty(t, w.Body.String())
+		assert.Equal(t, etag, w.Header().Get("ETag"))
+
+		assert.Equal(t, http.StatusOK, get(engine, "/items/1", `"other"`).Code)
+	})
+
+	t.Run("errors and disabled mode are not tagged", func(t *testing.T) {
+		assert.Empty(t, get(newEngine(ETagStrong), "/missing", "").Header().Get("ETag"))
+		assert.Empty(t, get(newEngine(ETagNone), "/items/1", "").Header().Get("ETag"))
+	})
+
+	t.Run("handler tags take precedence", func(t *testing.T) {
+		engine := gin.New()
+		engine.Use(ETagMiddleware(ETagStrong))
+		engine.GET("/v", func(c *gin.Context) {
+			WithETag(c, `"v7"`)
+			OK(c, "data", nil)
+		})
+
+		assert.Equal(t, `"v7"`, get(engine, "/v", "").Header().Get("ETag"))
+		assert.Equal(t, http.StatusNotModified, get(engine, "/v", `"v7"`).Code)
+	})
+}
+
+func TestCheckPreconditions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	modified := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	engine := gin.New()
+	handler := func(c *gin.Context) {
+		if !CheckPreconditions(c, `"v2"`, modified) {
+			return
+		}
+		OK(c, "updated", nil)
+	}
+	engine.GET("/doc", handler)
+	engine.PUT("/doc", handler)
+
+	do := func(method string, headers map[string]string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/doc", nil)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		status  int
+	}{
+		{"write without preconditions", http.MethodPut, nil, http.StatusOK},
+		{"matching If-Match", http.MethodPut, map[string]string{"If-Match": `"v1", "v2"`}, http.StatusOK},
+		{"stale If-Match", http.MethodPut, map[string]string{"If-Match": `"v1"`}, http.StatusPreconditionFailed},
+		{"weak If-Match never matches", http.MethodPut, map[string]string{"If-Match": `W/"v2"`}, http.StatusPreconditionFailed},
+		{"If-Match any", http.MethodPut, map[string]string{"If-Match": "*"}, http.StatusOK},
+		{"create-only on existing resource", http.MethodPut, map[string]string{"If-None-Match": "*"}, http.StatusPreconditionFailed},
+		{"unmodified since", http.MethodPut, map[string]string{"If-Unmodified-Since": modified.Format(http.TimeFormat)}, http.StatusOK},
+		{"modified since", http.MethodPut, map[string]string{"If-Unmodified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusPreconditionFailed},
+		{"conditional GET by tag", http.MethodGet, map[string]string{"If-None-Match": `W/"v2"`}, http.StatusNotModified},
+		{"conditional GET by date", http.MethodGet, map[string]string{"If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusNotModified},
+		{"GET of a newer version", http.MethodGet, map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := do(tt.method, tt.headers)
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusPreconditionFailed {
+				assert.Contains(t, w.Body.String(), `"code":"precondition_failed"`)
+			}
+		})
+	}
+}
@@ -1,12 +1,11 @@
 package responsex
 
 import (
-	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/requestidx"
 )
 
 const (
@@ -22,14 +21,8 @@ func MetaMiddleware(version string) gin.HandlerFunc {
 		// Date header
 		c.Header("Date", time.Now().UTC().Format(time.RFC1123))
 
-		// X-Request-Id: prefer incoming header, otherwise generate
-		rid := c.Request.Header.Get("X-Request-Id")
-		if strings.TrimSpace(rid) == "" {
-			rid = uuid.NewString()
-		}
-		c.Writer.Header().Set("X-Request-Id", rid)
-		// Store the request id on the context so handlers can access it
-		c.Set("X-Request-Id", rid)
+		// Reuse the request ID assigned by httpx, or assign one with the default settings
+		requestidx.Default().Assign(c)
 
 		// Optional traceparent: propagate if present
 		if tp := c.Request.Header.Get("traceparent"); tp != "" {
@@ -47,13 +40,13 @@ func MetaMiddleware(version string) gin.HandlerFunc {
 
 		// proceed
 		c.Next()
-
-		// After request: ensure request id header available in context too
-		c.Set("X-Request-Id", c.Writer.Header().Get("X-Request-Id"))
 	}
 }
 
-// WithLogger is an optional helper to attach a logx.Logger into context for middleware
+// WithLogger attaches logger to the request context.
+//
+// Deprecated: httpx attaches a request-scoped logger with correlation fields
+// automatically; read it with httpx.LoggerFromContext.
 func WithLogger(c *gin.Context, logger logx.Logger) {
-	c.Set("responsex.logger", logger)
+	c.Request = c.Request.WithContext(logx.WithContext(c.Request.Context(), logger))
 }
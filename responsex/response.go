@@ -1,12 +1,15 @@
 package responsex
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/requestidx"
 )
 
 // Envelope is the standard API response wrapper.
@@ -88,8 +91,45 @@ func Error(c *gin.Context, status int, code, message string, details []ErrDetail
 	sendEnvelope(c, status, false, nil, e, nil)
 }
 
+// ErrorBody returns an encoded error envelope including request metadata.
+// It is meant for middleware that must write a response outside the normal handler flow.
+func ErrorBody(c *gin.Context, code, message string, details []ErrDetail) []byte {
+	e := &APIError{Code: code, Message: message, Details: details}
+	var buf bytes.Buffer
+	_ = encodeEnvelope(&buf, buildEnvelope(c, false, nil, e, nil))
+	return buf.Bytes()
+}
+
 // sendEnvelope builds and writes the JSON response envelope.
+// Successful responses get an automatic ETag when ETagMiddleware is active, and
+// GET/HEAD requests whose validators match are answered with 304.
 func sendEnvelope(c *gin.Context, status int, ok bool, data any, err *APIError, pg *Pagination) {
+	env := buildEnvelope(c, ok, data, err, pg)
+
+	if status >= 200 && status < 300 {
+		etag := c.Writer.Header().Get("ETag")
+		if etag == "" {
+			etag = autoETag(c, env)
+			WithETag(c, etag)
+		}
+		if isSafeMethod(c.Request.Method) && status == http.StatusOK {
+			lastModified, _ := http.ParseTime(c.Writer.Header().Get("Last-Modified"))
+			if notModified(c.Request.Header, etag, lastModified) {
+				c.Status(http.StatusNotModified)
+				c.Writer.WriteHeaderNow()
+				return
+			}
+		}
+	}
+
+	c.Status(status)
+	c.Header("Content-Type", "application/json; charset=utf-8")
+
+	_ = encodeEnvelope(c.Writer, env)
+}
+
+// buildEnvelope assembles the envelope and attaches meta if available in context.
+func buildEnvelope(c *gin.Context, ok bool, data any, err *APIError, pg *Pagination) Envelope[any] {
 	env := Envelope[any]{
 		Ok:         ok,
 		Data:       data,
@@ -101,11 +141,7 @@ func sendEnvelope(c *gin.Context, status int, ok bool, data any, err *APIError,
 	if v, exists := c.Get("responsex.start_time"); exists {
 		if start, ok := v.(time.Time); ok {
 			meta := &Meta{Timestamp: time.Now()}
-			if rid, ok := c.Get("X-Request-Id"); ok {
-				if rs, ok := rid.(string); ok {
-					meta.RequestID = rs
-				}
-			}
+			meta.RequestID = requestidx.FromContext(c.Request.Context())
 			meta.DurationMS = time.Since(start).Milliseconds()
 			if sv, ok := c.Get("responsex.server_version"); ok {
 				if ss, ok := sv.(string); ok {
@@ -121,13 +157,21 @@ func sendEnvelope(c *gin.Context, status int, ok bool, data any, err *APIError,
 		}
 	}
 
-	c.Status(status)
-	c.Header("Content-Type", "application/json; charset=utf-8")
+	// Error envelopes always carry the request ID so clients can report it
+	if env.Meta == nil && err != nil {
+		if rid := requestidx.FromContext(c.Request.Context()); rid != "" {
+			env.Meta = &Meta{RequestID: rid}
+		}
+	}
+	return env
+}
 
+// encodeEnvelope writes the envelope as JSON.
+func encodeEnvelope(w io.Writer, env Envelope[any]) error {
 	// Use json.Encoder to avoid html escaping
-	enc := json.NewEncoder(c.Writer)
+	enc := json.NewEncoder(w)
 	enc.SetEscapeHTML(false)
-	_ = enc.Encode(env)
+	return enc.Encode(env)
 }
 
 func intToStr(i int) string {
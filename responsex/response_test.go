@@ -0,0 +1,67 @@
+package responsex
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gostratum/httpx/requestidx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorBodyIncludesMeta(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(MetaMiddleware("v1"))
+
+	var body []byte
+	engine.GET("/err", func(c *gin.Context) {
+		body = ErrorBody(c, "timeout", "request timed out", nil)
+		c.Status(http.StatusNoContent)
+	})
+
+	req, err := http.NewRequest(http.MethodGet, "/err", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-Id", "rid-1")
+	engine.ServeHTTP(httptest.NewRecorder(), req)
+
+	var env Envelope[any]
+	require.NoError(t, json.Unmarshal(body, &env))
+	assert.False(t, env.Ok)
+	require.NotNil(t, env.Error)
+	assert.Equal(t, "timeout", env.Error.Code)
+	require.NotNil(t, env.Meta)
+	assert.Equal(t, "rid-1", env.Meta.RequestID)
+}
+
+func TestErrorIncludesRequestIDWithoutMeta(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	engine := gin.New()
+	engine.Use(requestidx.Default().Middleware())
+	engine.GET("/err", func(c *gin.Context) {
+		Error(c, http.StatusBadRequest, "invalid", "invalid request", nil)
+	})
+	engine.GET("/ok", func(c *gin.Context) { OK(c, "fine", nil) })
+
+	req := httptest.NewRequest(http.MethodGet, "/err", nil)
+	req.Header.Set("X-Request-ID", "rid-2")
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+
+	var env Envelope[any]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &env))
+	require.NotNil(t, env.Meta)
+	assert.Equal(t, "rid-2", env.Meta.RequestID)
+	assert.True(t, env.Meta.Timestamp.IsZero(), "only the request ID is added without MetaMiddleware")
+
+	w = httptest.NewRecorder()
+	engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+	var okEnv Envelope[any]
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &okEnv))
+	assert.Nil(t, okEnv.Meta, "success envelopes are unchanged")
+}
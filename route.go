@@ -0,0 +1,36 @@
+package httpx
+
+import (
+	"regexp"
+	"strings"
+)
+
+// RouteMatch selects requests by method and route pattern.
+// It uses the same fields as DisabledURL so per-route settings read alike in config.
+type RouteMatch struct {
+	// Method is the HTTP method to match; empty matches all methods
+	Method string `mapstructure:"method"`
+
+	// URLPattern is a regular expression matched against the route template
+	URLPattern string `mapstructure:"urlPattern"`
+}
+
+// routeMatcher is the compiled form of a RouteMatch
+type routeMatcher struct {
+	method string
+	re     *regexp.Regexp
+}
+
+// compile compiles the route pattern
+func (r RouteMatch) compile() (routeMatcher, error) {
+	re, err := regexp.Compile(r.URLPattern)
+	if err != nil {
+		return routeMatcher{}, err
+	}
+	return routeMatcher{method: strings.ToUpper(r.Method), re: re}, nil
+}
+
+// match reports whether the method and path are selected by the rule
+func (m routeMatcher) match(method, path string) bool {
+	return (m.method == "" || m.method == strings.ToUpper(method)) && m.re.MatchString(path)
+}
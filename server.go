@@ -8,6 +8,8 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/gostratum/core"
 	"github.com/gostratum/core/logx"
+	"github.com/gostratum/httpx/requestidx"
+	"github.com/gostratum/httpx/responsex"
 	"go.uber.org/fx"
 )
 
@@ -27,23 +29,203 @@ func NewEngineWithObservability(log logx.Logger, cfg Config, skip func(string, s
 	// Create new Gin engine
 	e := gin.New()
 
-	// Add core middleware in order. RequestIDMiddleware must run before
-	// RecoveryMiddleware because the recovery handler reads the X-Request-ID
-	// header to include the request id in panic logs.
-	e.Use(RequestIDMiddleware())
+	// Resolve client IPs only from trusted proxies; invalid settings fall back to
+	// the connection's remote address rather than trusting every forwarding header
+	if err := configureClientIP(e, cfg.ClientIPConfig); err != nil {
+		log.Error("httpx: trusted proxies disabled due to invalid config", logx.Err(err))
+		_ = e.SetTrustedProxies(nil)
+	}
+
+	// Add core middleware in order. The request ID is assigned before
+	// RecoveryMiddleware because the recovery handler includes it in panic logs.
+	ids, err := requestidx.New(cfg.Request.ID)
+	if err != nil {
+		log.Error("httpx: request id settings ignored due to invalid config", logx.Err(err))
+		ids = requestidx.Default()
+	}
+	e.Use(ids.Middleware())
+	e.Use(ClientIPMiddleware())
+
+	// Skip rules targeting metrics and tracing; the logging skipper is passed in.
+	// Decisions are precomputed for the registered routes on the first request.
+	skippers, err := NewSkippers(cfg)
+	if err != nil {
+		log.Error("httpx: metrics and tracing skip rules ignored due to invalid config", logx.Err(err))
+	}
+	skip = routeSkips(e, skip)
+	skippers.Metrics = routeSkips(e, skippers.Metrics)
+	skippers.Tracing = routeSkips(e, skippers.Tracing)
 
 	// Add observability middleware if available (after RequestID, before Recovery)
 	if obs.Tracer != nil {
 		log.Info("httpx: enabling distributed tracing middleware")
-		e.Use(TracingMiddleware(obs.Tracer))
+		e.Use(skipping(skippers.Tracing, TracingMiddleware(obs.Tracer)))
 	}
 	if obs.Metrics != nil {
 		log.Info("httpx: enabling metrics middleware")
-		e.Use(MetricsMiddleware(obs.Metrics))
+		e.Use(skipping(skippers.Metrics, MetricsMiddleware(obs.Metrics)))
+	}
+
+	// Attach the request-scoped logger once request and span IDs are known
+	e.Use(RequestLoggerMiddleware(log))
+
+	e.Use(PanicRecoveryMiddleware(log, cfg.Recovery, obs.Metrics))
+	accessLog, err := AccessLogMiddleware(log, cfg.Request.Logging, skip, obs.Metrics)
+	if err != nil {
+		log.Error("httpx: access log settings ignored due to invalid config", logx.Err(err))
+		accessLog = LoggingMiddleware(log, skip)
+	}
+	e.Use(accessLog)
+
+	// Flag slow requests with a warning, span tag and metric
+	if cfg.Request.Slow.enabled() {
+		mw, err := SlowRequestMiddleware(log, cfg.Request.Slow, obs.Metrics)
+		if err != nil {
+			log.Error("httpx: slow request detection disabled due to invalid config", logx.Err(err))
+		} else {
+			e.Use(mw)
+		}
+	}
+
+	// Security headers go first so every response, including rejections, carries them
+	if cfg.SecurityHeaders.Enabled {
+		mw, err := SecurityHeadersMiddleware(cfg.SecurityHeaders)
+		if err != nil {
+			log.Error("httpx: security headers disabled due to invalid config", logx.Err(err))
+		} else {
+			e.Use(mw)
+			if cfg.SecurityHeaders.CSPReportPath != "" {
+				registerCSPReportRoute(e, cfg.SecurityHeaders, log, obs.Metrics)
+			}
+		}
+	}
+
+	// Reject filtered client addresses before any other work is done for them;
+	// invalid lists reject every client rather than disabling the filter
+	if cfg.IPFilter.Enabled {
+		f := modCfg.ipFilter
+		if f == nil {
+			var err error
+			if f, err = NewIPFilter(log, cfg.IPFilter, obs.Metrics); err != nil {
+				log.Error("httpx: rejecting all requests due to invalid ip filter config", logx.Err(err))
+				f = denyAllIPFilter(log, obs.Metrics)
+			}
+		}
+		e.Use(f.Middleware())
+	}
+
+	// Shed load before it reaches handlers; runs after logging and metrics so
+	// rejected requests are still observed
+	if cfg.Concurrency.Enabled {
+		mw, err := ConcurrencyLimitMiddleware(cfg.Concurrency, obs.Metrics)
+		if err != nil {
+			log.Error("httpx: concurrency limiter disabled due to invalid config", logx.Err(err))
+		} else {
+			log.Info("httpx: enabling adaptive concurrency limiter", logx.String("algorithm", cfg.Concurrency.Algorithm))
+			e.Use(mw)
+		}
+	}
+
+	// Enforce route policies before bodies are read and before replay and caching
+	// middleware, which key stored responses on the authenticated principal.
+	// Invalid auth settings fail closed rather than leaving protected routes open.
+	a := modCfg.authorizer
+	if a == nil && len(cfg.Auth.Policies) > 0 {
+		var err error
+		if a, err = newConfigAuthorizer(log, cfg, modCfg.apiKeyOpts...); err != nil {
+			log.Error("httpx: rejecting requests to protected routes due to invalid auth config", logx.Err(err))
+			e.Use(denyProtectedRoutes(cfg.Auth.Policies))
+		}
+	}
+	if a != nil {
+		e.Use(a.Middleware())
+	}
+
+	// Reject oversized request bodies before they are buffered by binding
+	if cfg.Request.BodyLimitConfig.enabled() {
+		mw, err := BodyLimitMiddleware(cfg.Request.BodyLimitConfig, obs.Metrics)
+		if err != nil {
+			log.Error("httpx: body size limits disabled due to invalid config", logx.Err(err))
+		} else {
+			e.Use(mw)
+		}
+	}
+
+	// Decode compressed request bodies after the wire-size limit has been applied
+	if cfg.Request.Decompression.Enabled {
+		e.Use(DecompressionMiddleware(cfg.Request.Decompression, obs.Metrics))
+	}
+
+	// Compress responses; registered before the timeout so 504 bodies are compressed too
+	if cfg.Compression.Enabled {
+		e.Use(CompressionMiddleware(cfg.Compression))
+	}
+
+	// Capture payloads for the access log after decoding and below the compressor,
+	// so both bodies are logged as plain text
+	if cfg.Request.Logging.Capture.Enabled {
+		bc := modCfg.bodyCapture
+		if bc == nil {
+			var err error
+			if bc, err = NewBodyCapture(cfg.Request.Logging.Capture); err != nil {
+				log.Error("httpx: body capture disabled due to invalid config", logx.Err(err))
+			}
+		}
+		if bc != nil {
+			e.Use(bc.Middleware())
+			if cfg.Request.Logging.Capture.DebugPath != "" {
+				registerBodyCaptureSwitch(e, cfg, log, a, bc)
+			}
+		}
 	}
 
-	e.Use(RecoveryMiddleware(log))
-	e.Use(LoggingMiddleware(log, skip))
+	// Tag envelopes automatically; conditional GETs are answered with 304 in responsex
+	if cfg.ETag.Enabled {
+		e.Use(responsex.ETagMiddleware(cfg.ETag.mode()))
+	}
+
+	// Replay responses for retried unsafe requests; captures the uncompressed body
+	// and wraps the timeout so a timed-out request frees its key
+	if cfg.Idempotency.Enabled {
+		store := modCfg.idempotencyStore
+		if store == nil {
+			store = NewMemoryIdempotencyStore()
+		}
+		e.Use(IdempotencyMiddleware(cfg.Idempotency, store))
+	}
+
+	// Serve cached GET responses; stores uncompressed bodies since compression wraps it
+	if cfg.Cache.Enabled {
+		rc, err := NewResponseCache(cfg.Cache, modCfg.cacheStore, obs.Metrics)
+		if err != nil {
+			log.Error("httpx: response cache disabled due to invalid config", logx.Err(err))
+		} else {
+			e.Use(rc.Middleware(e))
+			if cfg.Cache.PurgePath != "" {
+				registerCachePurgeRoute(e, cfg, log, a, rc)
+			}
+		}
+	}
+
+	// Collapse concurrent identical GETs behind cache misses into one handler run
+	if cfg.Coalesce.Enabled {
+		co, err := NewCoalescer(cfg.Coalesce, obs.Metrics)
+		if err != nil {
+			log.Error("httpx: request coalescing disabled due to invalid config", logx.Err(err))
+		} else {
+			e.Use(co.Middleware())
+		}
+	}
+
+	// Bound handler execution time with a deadline on the request context
+	if cfg.Request.Timeout.enabled() {
+		mw, err := TimeoutMiddleware(cfg.Request.Timeout)
+		if err != nil {
+			log.Error("httpx: request timeouts disabled due to invalid config", logx.Err(err))
+		} else {
+			e.Use(mw)
+		}
+	}
 
 	// Add any extra middleware provided via options
 	for _, mw := range modCfg.extraMW {
@@ -1,65 +1,290 @@
 package httpx
 
 import (
+	"fmt"
 	"regexp"
+	"regexp/syntax"
 	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Skip targets select which middleware a DisabledURL rule applies to
+const (
+	// SkipLogging skips access logging; rules without a target use it
+	SkipLogging = "logging"
+
+	// SkipMetrics skips request metrics
+	SkipMetrics = "metrics"
+
+	// SkipTracing skips server spans
+	SkipTracing = "tracing"
+
+	// SkipAll skips logging, metrics and tracing
+	SkipAll = "all"
 )
 
-// DisabledURL represents a URL pattern to skip in request logging
+// DisabledURL represents a URL pattern to skip in request logging, metrics or tracing
 type DisabledURL struct {
 	Method     string `mapstructure:"method"`
 	URLPattern string `mapstructure:"urlPattern"`
+
+	// Glob is an alternative to URLPattern matched against the whole path:
+	// * matches within a segment, ** across segments and ? one character
+	Glob string `mapstructure:"glob"`
+
+	// Target is logging (default), metrics, tracing or all
+	Target string `mapstructure:"target"`
+}
+
+// targets returns the targets the rule applies to
+func (d DisabledURL) targets() ([]string, error) {
+	switch strings.ToLower(d.Target) {
+	case "", SkipLogging:
+		return []string{SkipLogging}, nil
+	case SkipMetrics:
+		return []string{SkipMetrics}, nil
+	case SkipTracing:
+		return []string{SkipTracing}, nil
+	case SkipAll:
+		return []string{SkipLogging, SkipMetrics, SkipTracing}, nil
+	default:
+		return nil, fmt.Errorf("skip rule %q: unknown target %q", d.pattern(), d.Target)
+	}
 }
 
-// compiledRule represents a compiled regex rule for URL matching
-type compiledRule struct {
+// pattern returns the rule as a regular expression
+func (d DisabledURL) pattern() string {
+	if d.Glob != "" {
+		return globToRegexp(d.Glob)
+	}
+	return d.URLPattern
+}
+
+// globToRegexp translates a path glob into an anchored regular expression
+func globToRegexp(glob string) string {
+	var b strings.Builder
+	b.WriteByte('^')
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				b.WriteString(".*")
+				i++
+			} else {
+				b.WriteString("[^/]*")
+			}
+		case '?':
+			b.WriteString("[^/]")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	b.WriteByte('$')
+	return b.String()
+}
+
+// skipKey identifies a precomputed skip decision
+type skipKey struct {
 	method string
-	re     *regexp.Regexp
+	path   string
 }
 
-// NewSkipper creates a function that determines whether to skip logging for a given request
-// It loads patterns from config and ensures actuator/health endpoints are skipped by default
-func NewSkipper(cfg Config) (func(method, path string) bool, error) {
-	// Get user-defined rules from config
-	userRules := cfg.Request.Logging.DisabledURLs
+// prefixNode indexes rules anchored to the start of the path by their literal
+// prefix, so a lookup only evaluates rules whose prefix the path begins with
+type prefixNode struct {
+	children map[byte]*prefixNode
+	rules    []*regexp.Regexp
+}
+
+// insert indexes re under prefix
+func (n *prefixNode) insert(prefix string, re *regexp.Regexp) {
+	for i := 0; i < len(prefix); i++ {
+		child, ok := n.children[prefix[i]]
+		if !ok {
+			if n.children == nil {
+				n.children = map[byte]*prefixNode{}
+			}
+			child = &prefixNode{}
+			n.children[prefix[i]] = child
+		}
+		n = child
+	}
+	n.rules = append(n.rules, re)
+}
+
+// match walks path through the trie, evaluating the rules met on the way
+func (n *prefixNode) match(path string) bool {
+	for i := 0; n != nil; i++ {
+		for _, re := range n.rules {
+			if re.MatchString(path) {
+				return true
+			}
+		}
+		if i == len(path) {
+			break
+		}
+		n = n.children[path[i]]
+	}
+	return false
+}
+
+// anchoredPrefix returns the literal every match of an anchored pattern starts
+// with, or "" when the pattern can match elsewhere in the path.
+// Regexp.LiteralPrefix cannot be used: it is empty for any pattern starting with ^.
+func anchoredPrefix(re *regexp.Regexp) string {
+	parsed, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil || parsed.Op != syntax.OpConcat || parsed.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+	var prefix strings.Builder
+	for _, sub := range parsed.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+		prefix.WriteString(string(sub.Rune))
+	}
+	return prefix.String()
+}
+
+// Skipper decides whether requests are skipped. Each lookup walks a prefix
+// trie per method that only evaluates rules whose anchored literal prefix the
+// path starts with. The engine precomputes decisions for its registered routes
+// with routeSkips, so per-request lookups do not depend on the rule count.
+type Skipper struct {
+	anyMethod *prefixNode
+	byMethod  map[string]*prefixNode
+}
 
+// newSkipper compiles rules into a Skipper
+func newSkipper(rules []DisabledURL) (*Skipper, error) {
+	s := &Skipper{anyMethod: &prefixNode{}, byMethod: map[string]*prefixNode{}}
+	for _, rule := range rules {
+		re, err := regexp.Compile(rule.pattern())
+		if err != nil {
+			return nil, err
+		}
+		root := s.anyMethod
+		if method := strings.ToUpper(rule.Method); method != "" {
+			if root = s.byMethod[method]; root == nil {
+				root = &prefixNode{}
+				s.byMethod[method] = root
+			}
+		}
+		root.insert(anchoredPrefix(re), re)
+	}
+	return s, nil
+}
+
+// Skip reports whether the request to the route template path is skipped
+func (s *Skipper) Skip(method, path string) bool {
+	return s.anyMethod.match(path) || s.byMethod[strings.ToUpper(method)].match(path)
+}
+
+// routeSkips precomputes skip for every route registered on e. The table is
+// built from e.Routes() on the first request, once route registration is done,
+// and never grows afterwards; requests to other methods or paths call skip.
+func routeSkips(e *gin.Engine, skip func(method, path string) bool) func(method, path string) bool {
+	if skip == nil {
+		return nil
+	}
+	var once sync.Once
+	var table map[skipKey]bool
+	return func(method, path string) bool {
+		once.Do(func() {
+			routes := e.Routes()
+			table = make(map[skipKey]bool, len(routes))
+			for _, r := range routes {
+				table[skipKey{method: r.Method, path: r.Path}] = skip(r.Method, r.Path)
+			}
+		})
+		if skipped, ok := table[skipKey{method: method, path: path}]; ok {
+			return skipped
+		}
+		return skip(method, path)
+	}
+}
+
+// Skippers decide per target whether a request is left out of logging,
+// metrics or tracing. Each function takes the method and route template.
+type Skippers struct {
+	Logging func(method, path string) bool
+	Metrics func(method, path string) bool
+	Tracing func(method, path string) bool
+}
+
+// NewSkippers builds the skippers for each target from the configured rules.
+// Health and actuator endpoints are excluded from logging and tracing by default.
+func NewSkippers(cfg Config) (Skippers, error) {
 	// Get configurable health endpoint paths
 	healthzPath := cfg.Health.ReadinessPath
 	livezPath := cfg.Health.LivenessPath
 	infoPath := cfg.Health.InfoPath
 
 	// Always ensure health endpoints are skipped by default (using configured paths)
-	defaultRules := []DisabledURL{
-		{Method: "GET", URLPattern: "^" + healthzPath + "$"},
-		{Method: "GET", URLPattern: "^" + livezPath + "$"},
-		{Method: "GET", URLPattern: "^" + strings.Replace(infoPath, "/info", "/.*", 1)}, // Skip all actuator endpoints
+	defaultPatterns := []string{
+		"^" + healthzPath + "$",
+		"^" + livezPath + "$",
+		"^" + strings.Replace(infoPath, "/info", "/.*", 1), // Skip all actuator endpoints
+	}
+	var defaultRules []DisabledURL
+	for _, target := range []string{SkipLogging, SkipTracing} {
+		for _, p := range defaultPatterns {
+			defaultRules = append(defaultRules, DisabledURL{Method: "GET", URLPattern: p, Target: target})
+		}
 	}
 
 	// Combine default rules with user rules
-	allRules := append(defaultRules, userRules...)
+	allRules := append(defaultRules, cfg.Request.Logging.DisabledURLs...)
 
-	// Compile all rules
-	var compiledRules []compiledRule
+	// Group rules by target
+	byTarget := map[string][]DisabledURL{}
 	for _, rule := range allRules {
-		re, err := regexp.Compile(rule.URLPattern)
+		targets, err := rule.targets()
 		if err != nil {
-			return nil, err
+			return Skippers{}, err
+		}
+		for _, t := range targets {
+			byTarget[t] = append(byTarget[t], rule)
 		}
-		compiledRules = append(compiledRules, compiledRule{
-			method: strings.ToUpper(rule.Method),
-			re:     re,
-		})
 	}
 
-	// Return the skipper function
-	return func(method, path string) bool {
-		normalizedMethod := strings.ToUpper(method)
-		for _, rule := range compiledRules {
-			// If method is empty or matches, check the URL pattern
-			if (rule.method == "" || rule.method == normalizedMethod) && rule.re.MatchString(path) {
-				return true
-			}
+	var skippers [3]*Skipper
+	for i, t := range []string{SkipLogging, SkipMetrics, SkipTracing} {
+		s, err := newSkipper(byTarget[t])
+		if err != nil {
+			return Skippers{}, err
 		}
-		return false
+		skippers[i] = s
+	}
+	return Skippers{
+		Logging: skippers[0].Skip,
+		Metrics: skippers[1].Skip,
+		Tracing: skippers[2].Skip,
 	}, nil
 }
+
+// NewSkipper creates a function that determines whether to skip logging for a given request
+// It loads patterns from config and ensures actuator/health endpoints are skipped by default
+func NewSkipper(cfg Config) (func(method, path string) bool, error) {
+	s, err := NewSkippers(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return s.Logging, nil
+}
+
+// skipping runs mw unless skip selects the request
+func skipping(skip func(method, path string) bool, mw gin.HandlerFunc) gin.HandlerFunc {
+	if skip == nil {
+		return mw
+	}
+	return func(c *gin.Context) {
+		if skip(c.Request.Method, c.FullPath()) {
+			c.Next()
+			return
+		}
+		mw(c)
+	}
+}
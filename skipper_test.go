@@ -1,6 +1,10 @@
 package httpx
 
 import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
 	"testing"
 	"time"
 
@@ -181,3 +185,177 @@ func TestOptions(t *testing.T) {
 		assert.Len(t, modCfg.extraMW, 2)
 	})
 }
+
+func TestNewSkippers(t *testing.T) {
+	cfg := Config{
+		Health: HealthConfig{ReadinessPath: "/healthz", LivenessPath: "/livez", InfoPath: "/actuator/info"},
+		Request: RequestConfig{Logging: LoggingConfig{DisabledURLs: []DisabledURL{
+			{Method: "GET", URLPattern: "^/metrics$", Target: SkipAll},
+			{URLPattern: "^/api/poll$", Target: SkipMetrics},
+			{URLPattern: "^/api/stream$", Target: "Tracing"},
+			{URLPattern: "^/api/noisy$"},
+		}}},
+	}
+	s, err := NewSkippers(cfg)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path                      string
+		logging, metrics, tracing bool
+	}{
+		{path: "/healthz", logging: true, tracing: true},
+		{path: "/actuator/env", logging: true, tracing: true},
+		{path: "/metrics", logging: true, metrics: true, tracing: true},
+		{path: "/api/poll", metrics: true},
+		{path: "/api/stream", tracing: true},
+		{path: "/api/noisy", logging: true},
+		{path: "/api/users"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.logging, s.Logging(http.MethodGet, tt.path), "logging")
+			assert.Equal(t, tt.metrics, s.Metrics(http.MethodGet, tt.path), "metrics")
+			assert.Equal(t, tt.tracing, s.Tracing(http.MethodGet, tt.path), "tracing")
+		})
+	}
+
+	t.Run("rejects unknown targets", func(t *testing.T) {
+		cfg.Request.Logging.DisabledURLs = []DisabledURL{{URLPattern: "^/x$", Target: "audit"}}
+		_, err := NewSkippers(cfg)
+		assert.Error(t, err)
+	})
+}
+
+func TestSkippingMiddleware(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	metrics := newRecordingMetrics()
+	skip := func(_, path string) bool { return path == "/healthz" }
+	engine := gin.New()
+	engine.Use(skipping(skip, MetricsMiddleware(metrics)))
+	engine.GET("/healthz", func(c *gin.Context) { c.Status(http.StatusOK) })
+	engine.GET("/users", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, target := range []string{"/healthz", "/users", "/healthz"} {
+		w := httptest.NewRecorder()
+		engine.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		assert.Equal(t, http.StatusOK, w.Code)
+	}
+	assert.Equal(t, float64(1), metrics.counter("http_requests_total"))
+}
+
+func TestSkipperGlobs(t *testing.T) {
+	s, err := newSkipper([]DisabledURL{
+		{Method: "GET", Glob: "/static/**"},
+		{Glob: "/api/*/status"},
+		{Glob: "/v?/ping"},
+		{Method: "POST", URLPattern: "^/hooks/"},
+		{URLPattern: "^/legacy/|/old$"},
+	})
+	require.NoError(t, err)
+
+	tests := []struct {
+		method, path string
+		expected     bool
+	}{
+		{"GET", "/static/css/app.css", true},
+		{"POST", "/static/css/app.css", false},
+		{"GET", "/api/orders/status", true},
+		{"GET", "/api/orders/7/status", false},
+		{"GET", "/api/:id/status", true},
+		{"GET", "/v1/ping", true},
+		{"GET", "/v10/ping", false},
+		{"post", "/hooks/github", true},
+		{"GET", "/hooks/github", false},
+		{"GET", "/legacy/users", true},
+		{"GET", "/api/old", true},
+		{"GET", "/api/older", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			assert.Equal(t, tt.expected, s.Skip(tt.method, tt.path))
+		})
+	}
+}
+
+func TestAnchoredPrefix(t *testing.T) {
+	for pattern, expected := range map[string]string{
+		"^/api/.*":                 "/api/",
+		"^/healthz$":               "/healthz",
+		"^/static/[^/]*$":          "/static/",
+		"^/ab*":                    "/a",
+		"/api/.*":                  "",
+		"^(?i)/api/":               "",
+		"^/legacy/|/old$":          "",
+		globToRegexp("/static/**"): "/static/",
+	} {
+		assert.Equal(t, expected, anchoredPrefix(regexp.MustCompile(pattern)), pattern)
+	}
+}
+
+func TestRouteSkips(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var calls int
+	skip := func(method, path string) bool {
+		calls++
+		return path == "/healthz"
+	}
+	engine := gin.New()
+	engine.GET("/healthz", func(c *gin.Context) {})
+	engine.GET("/users/:id", func(c *gin.Context) {})
+	lookup := routeSkips(engine, skip)
+
+	assert.True(t, lookup("GET", "/healthz"))
+	assert.False(t, lookup("GET", "/users/:id"))
+	assert.Equal(t, 2, calls, "registered routes are resolved once")
+
+	for i := range 100 {
+		lookup(fmt.Sprintf("JUNK%d", i), "/healthz")
+	}
+	assert.Equal(t, 102, calls, "other methods are evaluated without being stored")
+	assert.True(t, lookup("GET", "/healthz"))
+	assert.Equal(t, 102, calls)
+
+	assert.Nil(t, routeSkips(engine, nil))
+}
+
+// benchmarkRules returns n distinct skip rules for benchmarks
+func benchmarkRules(n int) []DisabledURL {
+	rules := make([]DisabledURL, n)
+	for i := range rules {
+		if i%2 == 0 {
+			rules[i] = DisabledURL{Method: "GET", URLPattern: fmt.Sprintf("^/internal/%d/.*$", i)}
+		} else {
+			rules[i] = DisabledURL{Glob: fmt.Sprintf("/static/%d/**", i)}
+		}
+	}
+	return rules
+}
+
+func BenchmarkSkipper(b *testing.B) {
+	gin.SetMode(gin.TestMode)
+
+	for _, n := range []int{1, 10, 100} {
+		s, err := newSkipper(benchmarkRules(n))
+		require.NoError(b, err)
+
+		b.Run(fmt.Sprintf("precomputed/rules=%d", n), func(b *testing.B) {
+			engine := gin.New()
+			engine.GET("/api/v1/users/:id", func(c *gin.Context) {})
+			skip := routeSkips(engine, s.Skip)
+			skip("GET", "/api/v1/users/:id")
+			b.ReportAllocs()
+			for b.Loop() {
+				skip("GET", "/api/v1/users/:id")
+			}
+		})
+
+		b.Run(fmt.Sprintf("trie/rules=%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				s.Skip("GET", "/api/v1/users/:id")
+			}
+		})
+	}
+}